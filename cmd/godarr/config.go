@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/component"
+	"github.com/KnutZuidema/godarr/pkg/config"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/notification"
+	"github.com/KnutZuidema/godarr/pkg/organizer"
+	"github.com/KnutZuidema/godarr/pkg/scheduler"
+)
+
+// componentNamespace derives stable IDs for the components of the
+// configuration file, so they keep their instances across reloads.
+var componentNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/KnutZuidema/godarr/component")
+
+// reload loads the configuration again and applies the changes which do not
+// require a restart. The previous configuration is kept if the new one is
+// invalid.
+func reload(
+	path string,
+	old *config.Config,
+	o *organizer.FileSystemOrganizer,
+	notifications *notification.Dispatcher,
+	components *component.Manager,
+	tasks *scheduler.Scheduler,
+) *config.Config {
+	logrus.Info("reloading configuration")
+	cfg, err := config.Load(path)
+	if err != nil {
+		logrus.Error("reload configuration: ", err)
+		return old
+	}
+	level, _ := logrus.ParseLevel(cfg.Log.Level)
+	logrus.SetLevel(level)
+	options, err := fileSystemOrganizerOptions(cfg)
+	if err != nil {
+		logrus.Error("reload naming: ", err)
+		return old
+	}
+	o.SetOptions(options)
+	templates, err := notification.NewTemplates(cfg.Notifications.TitleTemplate, cfg.Notifications.BodyTemplate)
+	if err != nil {
+		logrus.Error("reload notification templates: ", err)
+		return old
+	}
+	notifications.SetTemplates(templates, cfg.Notifications.ImageBaseURL)
+	components.SetProviderOrder(cfg.Providers.Order)
+	if err := components.SetStatic(configComponents(cfg)); err != nil {
+		logrus.Error("reload components: ", err)
+	}
+	for name, options := range taskOptions(cfg) {
+		if err := tasks.SetOptions(name, options); err != nil {
+			logrus.WithField("task", name).Error("reload task: ", err)
+		}
+	}
+	for name, changed := range map[string]bool{
+		"server":                 !reflect.DeepEqual(old.Server, cfg.Server),
+		"postgres":               !reflect.DeepEqual(old.Postgres, cfg.Postgres),
+		"events":                 !reflect.DeepEqual(old.Events, cfg.Events),
+		"providers.refreshAfter": old.Providers.RefreshAfter != cfg.Providers.RefreshAfter,
+		"providers.cache":        old.Providers.Cache != cfg.Providers.Cache,
+		"cleanup":                !reflect.DeepEqual(old.Cleanup, cfg.Cleanup),
+		"backup":                 !reflect.DeepEqual(old.Backup, cfg.Backup),
+	} {
+		if changed {
+			logrus.Warnf("changes of %s take effect after a restart", name)
+		}
+	}
+	logrus.Info("reloaded configuration")
+	return cfg
+}
+
+// taskOptions returns the schedule of each task by its name.
+func taskOptions(cfg *config.Config) map[string]scheduler.Options {
+	options := func(task config.TaskConfig) scheduler.Options {
+		return scheduler.Options{
+			Interval: task.Interval,
+			Jitter:   task.Jitter,
+		}
+	}
+	return map[string]scheduler.Options{
+		scheduler.TaskMetadataRefresh: options(cfg.Tasks.MetadataRefresh),
+		scheduler.TaskRSSSync:         options(cfg.Tasks.RSSSync),
+		scheduler.TaskMissingSearch:   options(cfg.Tasks.MissingSearch),
+		scheduler.TaskLibraryRescan:   options(cfg.Tasks.LibraryRescan),
+		scheduler.TaskCleanup:         options(cfg.Tasks.Cleanup),
+		scheduler.TaskBackup:          options(cfg.Tasks.Backup),
+	}
+}
+
+func fileSystemOrganizerOptions(cfg *config.Config) (organizer.FileSystemOrganizerOptions, error) {
+	naming, err := organizer.NewNaming(organizer.NamingOptions{
+		MovieFolder:  cfg.Naming.MovieFolder,
+		MovieFile:    cfg.Naming.MovieFile,
+		SeriesFolder: cfg.Naming.SeriesFolder,
+		SeasonFolder: cfg.Naming.SeasonFolder,
+		EpisodeFile:  cfg.Naming.EpisodeFile,
+	})
+	if err != nil {
+		return organizer.FileSystemOrganizerOptions{}, err
+	}
+	roots := map[model.ItemKind]string{}
+	if cfg.Library.Movies != "" {
+		roots[model.ItemKindMovie] = cfg.Library.Movies
+	}
+	if cfg.Library.TV != "" {
+		roots[model.ItemKindTVSeries] = cfg.Library.TV
+	}
+	return organizer.FileSystemOrganizerOptions{
+		Roots:      roots,
+		Hardlink:   cfg.Library.Hardlink,
+		RecycleBin: cfg.Library.RecycleBin,
+		Naming:     naming,
+	}, nil
+}
+
+// rateLimit returns the rate limit settings of a component, nil uses the
+// default of its type.
+func rateLimit(limit config.RateLimitConfig) *component.RateLimit {
+	if limit.Requests == 0 {
+		return nil
+	}
+	return &component.RateLimit{
+		Requests: limit.Requests,
+		Per:      component.Duration(limit.Per),
+	}
+}
+
+// configComponents returns the indexers, download clients, providers and
+// notifications of the configuration file as components.
+func configComponents(cfg *config.Config) []*model.Component {
+	var components []*model.Component
+	add := func(category model.ComponentCategory, componentType, name string, settings interface{}) {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			logrus.WithField("name", name).Error("encode component settings: ", err)
+			return
+		}
+		components = append(components, &model.Component{
+			ID:       uuid.NewV5(componentNamespace, string(category)+"/"+name).String(),
+			Category: category,
+			Type:     componentType,
+			Name:     name,
+			Enabled:  true,
+			Settings: model.JSON(data),
+		})
+	}
+	for _, indexer := range cfg.Indexers {
+		switch indexer.Type {
+		case config.IndexerTypeBroadcasTheNet:
+			add(model.ComponentCategoryIndexer, indexer.Type, indexer.Name, component.BroadcasTheNetSettings{
+				APIKey:    indexer.APIKey,
+				RateLimit: rateLimit(indexer.RateLimit),
+			})
+		case config.IndexerTypeRSS:
+			add(model.ComponentCategoryIndexer, indexer.Type, indexer.Name, component.RSSSettings{
+				URL:       indexer.URL,
+				RateLimit: rateLimit(indexer.RateLimit),
+			})
+		}
+	}
+	for _, client := range cfg.DownloadClients {
+		settings := component.QBitTorrentSettings{
+			Address:            client.Address,
+			Username:           client.Username,
+			Password:           client.Password,
+			Interval:           component.Duration(client.Interval),
+			Paused:             client.Paused,
+			SequentialDownload: client.SequentialDownload,
+			FirstLastPiecePrio: client.FirstLastPiecePrio,
+		}
+		if len(client.Categories) > 0 {
+			settings.Categories = map[string]component.QBitTorrentCategory{}
+			for kind, category := range client.Categories {
+				settings.Categories[kind] = component.QBitTorrentCategory{
+					Name:     category.Name,
+					SavePath: category.SavePath,
+				}
+			}
+		}
+		for _, mapping := range client.PathMappings {
+			settings.PathMappings = append(settings.PathMappings, component.PathMapping{
+				Remote: mapping.Remote,
+				Local:  mapping.Local,
+			})
+		}
+		add(model.ComponentCategoryDownloadClient, client.Type, client.Name, settings)
+	}
+	if cfg.Providers.TMDB.APIKey != "" {
+		add(model.ComponentCategoryProvider, component.TypeTMDB, component.TypeTMDB, component.TMDBSettings{
+			APIKey:    cfg.Providers.TMDB.APIKey,
+			RateLimit: rateLimit(cfg.Providers.TMDB.RateLimit),
+		})
+	}
+	if cfg.Providers.TVDB.APIKey != "" {
+		add(model.ComponentCategoryProvider, component.TypeTVDB, component.TypeTVDB, component.TVDBSettings{
+			APIKey:    cfg.Providers.TVDB.APIKey,
+			PIN:       cfg.Providers.TVDB.PIN,
+			URL:       cfg.Providers.TVDB.URL,
+			RateLimit: rateLimit(cfg.Providers.TVDB.RateLimit),
+		})
+	}
+	if cfg.Providers.OMDb.APIKey != "" {
+		add(model.ComponentCategoryProvider, component.TypeOMDb, component.TypeOMDb, component.OMDbSettings{
+			APIKey:    cfg.Providers.OMDb.APIKey,
+			URL:       cfg.Providers.OMDb.URL,
+			RateLimit: rateLimit(cfg.Providers.OMDb.RateLimit),
+		})
+	}
+	n := cfg.Notifications
+	if n.Discord.URL != "" {
+		add(model.ComponentCategoryNotification, component.TypeDiscord, component.TypeDiscord, component.WebhookSettings{URL: n.Discord.URL})
+	}
+	if n.Slack.URL != "" {
+		add(model.ComponentCategoryNotification, component.TypeSlack, component.TypeSlack, component.WebhookSettings{URL: n.Slack.URL})
+	}
+	if n.Telegram.Token != "" {
+		add(model.ComponentCategoryNotification, component.TypeTelegram, component.TypeTelegram, component.TelegramSettings{
+			URL:    n.Telegram.URL,
+			Token:  n.Telegram.Token,
+			ChatID: n.Telegram.ChatID,
+		})
+	}
+	if n.Gotify.URL != "" {
+		add(model.ComponentCategoryNotification, component.TypeGotify, component.TypeGotify, component.GotifySettings{
+			URL:      n.Gotify.URL,
+			Token:    n.Gotify.Token,
+			Priority: n.Gotify.Priority,
+		})
+	}
+	if n.Ntfy.Topic != "" {
+		add(model.ComponentCategoryNotification, component.TypeNtfy, component.TypeNtfy, component.NtfySettings{
+			URL:   n.Ntfy.URL,
+			Topic: n.Ntfy.Topic,
+			Token: n.Ntfy.Token,
+		})
+	}
+	if n.SMTP.Address != "" {
+		add(model.ComponentCategoryNotification, component.TypeSMTP, component.TypeSMTP, component.SMTPSettings{
+			Address:  n.SMTP.Address,
+			Username: n.SMTP.Username,
+			Password: n.SMTP.Password,
+			From:     n.SMTP.From,
+			To:       n.SMTP.To,
+		})
+	}
+	return components
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -11,22 +16,43 @@ import (
 	"github.com/rubenv/sql-migrate"
 
 	"github.com/KnutZuidema/godarr/pkg/api"
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/component"
+	"github.com/KnutZuidema/godarr/pkg/config"
 	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/library"
+	"github.com/KnutZuidema/godarr/pkg/lifecycle"
+	"github.com/KnutZuidema/godarr/pkg/maintenance"
 	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/monitorer"
+	"github.com/KnutZuidema/godarr/pkg/notification"
+	"github.com/KnutZuidema/godarr/pkg/organizer"
+	"github.com/KnutZuidema/godarr/pkg/pipeline"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+	"github.com/KnutZuidema/godarr/pkg/scheduler"
+	"github.com/KnutZuidema/godarr/pkg/webhook"
 )
 
 func main() {
 	var (
-		serverAddress   = flag.String("server.address", "localhost:5000", "address the server should listen on")
-		postgresAddress = flag.String("postgres.address", "postgres://postgres@localhost/postgres?sslmode=disable", "address for the postgres database")
-		postgresMigrate = flag.Bool("postgres.migrate", true, "whether to execute migrations, default true")
+		configPath     = flag.String("config", "", "path of the YAML configuration file, only defaults and environment variables are used if empty")
+		generateAPIKey = flag.String("apikey.generate", "", "generate an API key with the given name, print it and exit")
+		rotateAPIKey   = flag.String("apikey.rotate", "", "replace the API keys with the given name with a new one, print it and exit")
 	)
 	flag.Parse()
-	sqlxDB, err := sqlx.Open("postgres", *postgresAddress)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logrus.Fatal("load configuration: ", err)
+	}
+	level, _ := logrus.ParseLevel(cfg.Log.Level)
+	logrus.SetLevel(level)
+	sqlxDB, err := sqlx.Open("postgres", cfg.Postgres.Address)
 	if err != nil {
 		logrus.Fatal("database open: ", err)
 	}
-	if *postgresMigrate {
+	if cfg.Postgres.Migrate {
 		migrations := migrate.FileMigrationSource{
 			Dir: "migrations",
 		}
@@ -36,19 +62,134 @@ func main() {
 		}
 		logrus.Infof("executed %d migrations", n)
 	}
-	db, err := database.New(sqlxDB)
+	sqlDB, err := database.New(sqlxDB)
 	if err != nil {
 		logrus.Fatal("initialize database: ", err)
 	}
-	defer func() {
+	bus := event.NewBus(cfg.Events.BufferSize, nil)
+	db := event.NewDatabase(sqlDB, bus)
+	if *generateAPIKey != "" || *rotateAPIKey != "" {
+		var key string
+		if *generateAPIKey != "" {
+			key, err = auth.CreateAPIKey(db, *generateAPIKey)
+		} else {
+			key, err = auth.RotateAPIKey(db, *rotateAPIKey)
+		}
+		if closeErr := db.Close(); closeErr != nil {
+			logrus.Error("database close: ", closeErr)
+		}
+		if err != nil {
+			logrus.Fatal("API key: ", err)
+		}
+		fmt.Println(key)
+		return
+	}
+	if keys, err := db.ListAPIKeys(); err != nil {
+		logrus.Fatal("list API keys: ", err)
+	} else if len(keys) == 0 {
+		key, err := auth.CreateAPIKey(db, "default")
+		if err != nil {
+			logrus.Fatal("generate API key: ", err)
+		}
+		logrus.Warnf("no API key found, generated API key %q, it will not be shown again", key)
+	}
+	lifecycleManager := lifecycle.NewManager(nil)
+	recorder := history.NewRecorder(db, bus, nil)
+	organizerOptions, err := fileSystemOrganizerOptions(cfg)
+	if err != nil {
+		logrus.Fatal("naming: ", err)
+	}
+	fileSystemOrganizer := organizer.NewFileSystemOrganizer(db, recorder, organizerOptions, nil)
+	templates, err := notification.NewTemplates(cfg.Notifications.TitleTemplate, cfg.Notifications.BodyTemplate)
+	if err != nil {
+		logrus.Fatal("notification templates: ", err)
+	}
+	notifications := notification.NewDispatcher(db, nil, nil, nil)
+	notifications.SetTemplates(templates, cfg.Notifications.ImageBaseURL)
+	lifecycleManager.Go("notifications", func(stop <-chan struct{}) {
+		notifications.Run(bus, stop)
+	})
+	var (
+		addedItems = make(chan model.Item)
+		releases   = make(chan model.Release)
+		downloads  = make(chan model.CompletedDownload)
+	)
+	components := component.NewManager(db, bus, releases, downloads, notifications, nil)
+	components.SetProviderOrder(cfg.Providers.Order)
+	if cache := cfg.Providers.Cache; cache.TTL > 0 {
+		options := provider.CacheOptions{
+			TTL:         cache.TTL,
+			NegativeTTL: cache.NegativeTTL,
+			Size:        cache.Size,
+		}
+		if cache.Persist {
+			options.Store = db
+		}
+		components.SetProviderCache(provider.NewCache(options, nil))
+	}
+	if err := components.SetStatic(configComponents(cfg)); err != nil {
+		logrus.Fatal("initialize components: ", err)
+	}
+	itemPipeline := pipeline.New(
+		db,
+		components.Monitorer(),
+		components.Downloader(),
+		fileSystemOrganizer,
+		recorder,
+		addedItems,
+		releases,
+		downloads,
+		nil,
+	)
+	lifecycleManager.Go("pipeline", itemPipeline.Run)
+	if err := itemPipeline.Resume(); err != nil {
+		logrus.Error("resume jobs: ", err)
+	}
+	providers := components.Providers()
+	rescanner := library.NewRescanner(db, recorder, nil)
+	tasks := scheduler.New(db, nil)
+	schedule := taskOptions(cfg)
+	tasks.Register(scheduler.TaskMetadataRefresh, schedule[scheduler.TaskMetadataRefresh], library.NewRefresher(db, providers, cfg.Providers.RefreshAfter, nil).RefreshChanged)
+	tasks.Register(scheduler.TaskRSSSync, schedule[scheduler.TaskRSSSync], monitorer.NewRSSSync(db, components.Feeds, releases, nil).Sync)
+	tasks.Register(scheduler.TaskMissingSearch, schedule[scheduler.TaskMissingSearch], itemPipeline.SearchMissing)
+	tasks.Register(scheduler.TaskLibraryRescan, schedule[scheduler.TaskLibraryRescan], func(context.Context) error {
+		return rescanner.Rescan()
+	})
+	tasks.Register(scheduler.TaskCleanup, schedule[scheduler.TaskCleanup], maintenance.NewCleaner(db, cfg.Cleanup.Retention, nil).Clean)
+	tasks.Register(scheduler.TaskBackup, schedule[scheduler.TaskBackup], maintenance.NewBackuper(db, cfg.Backup.Path, cfg.Backup.Keep, nil).Backup)
+	lifecycleManager.Go("scheduler", tasks.Run)
+	server := api.NewServer(db, addedItems, nil)
+	server.Events = bus
+	server.Webhooks = webhook.NewDispatcher(db, nil, nil)
+	lifecycleManager.Go("webhooks", func(stop <-chan struct{}) {
+		server.Webhooks.Run(bus, stop)
+	})
+	server.Components = components
+	server.Providers = providers
+	server.Tasks = tasks
+	server.Library = library.NewScanner(server.Providers, nil)
+	httpServer := &http.Server{
+		Addr:    cfg.Server.Address,
+		Handler: server.Router,
+	}
+	httpServer.RegisterOnShutdown(server.CloseStreams)
+	lifecycleManager.Serve(httpServer)
+	lifecycleManager.OnShutdown("pipeline", itemPipeline.Shutdown)
+	lifecycleManager.OnShutdown("database", func(context.Context) error {
 		if err := db.Close(); err != nil {
-			logrus.Error("database close: ", err)
+			return err
+		}
+		return sqlxDB.Close()
+	})
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			cfg = reload(*configPath, cfg, fileSystemOrganizer, notifications, components, tasks)
 		}
 	}()
-	addedItems := make(chan model.Item)
-	server := api.NewServer(db, addedItems, nil)
-	logrus.Infof("Listening on %s", *serverAddress)
-	if err := http.ListenAndServe(*serverAddress, server.Router); err != nil {
-		logrus.Fatal("")
+	if err := lifecycleManager.Run(shutdownTimeout, syscall.SIGINT, syscall.SIGTERM); err != nil {
+		logrus.Fatal(err)
 	}
 }
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+create table component
+(
+    id         uuid primary key,
+    category   text      not null,
+    type       text      not null,
+    name       text      not null,
+    enabled    boolean   not null default true,
+    settings   jsonb     not null default '{}',
+    created_at timestamp not null,
+    unique (category, name)
+);
+
+-- +migrate Down
+
+drop table component;
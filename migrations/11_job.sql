@@ -0,0 +1,19 @@
+-- +migrate Up
+
+create table job
+(
+    id         uuid primary key,
+    kind       text      not null,
+    item_id    uuid      not null references item (id) on delete cascade,
+    title      text      not null default '',
+    indexer    text      not null default '',
+    size       bigint    not null default 0,
+    quality    text      not null default '',
+    torrent    bytea,
+    path       text      not null default '',
+    created_at timestamp not null
+);
+
+-- +migrate Down
+
+drop table job;
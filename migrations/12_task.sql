@@ -0,0 +1,14 @@
+-- +migrate Up
+
+create table task
+(
+    name          text primary key,
+    last_run_at   timestamp,
+    last_duration double precision not null default 0,
+    last_error    text             not null default '',
+    next_run_at   timestamp
+);
+
+-- +migrate Down
+
+drop table task;
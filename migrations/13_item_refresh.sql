@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table item add column tmdb_id integer;
+alter table item add column refreshed_at timestamp;
+
+-- +migrate Down
+
+alter table item drop column refreshed_at;
+alter table item drop column tmdb_id;
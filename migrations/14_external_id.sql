@@ -0,0 +1,30 @@
+-- +migrate Up
+
+create table external_id
+(
+    item_id uuid not null references item on delete cascade,
+    source  text not null,
+    value   text not null,
+    primary key (item_id, source)
+);
+
+create index external_id_source_value on external_id (source, value);
+
+insert into external_id
+select id, 'tmdb', cast(tmdb_id as text) from item where tmdb_id is not null;
+insert into external_id
+select id, 'imdb', external_id from item where kind = 'movie' and external_id like 'tt%';
+insert into external_id
+select id, 'tvdb', external_id from item where kind = 'tv-series' and external_id ~ '^[0-9]+$';
+
+alter table item drop column tmdb_id;
+
+-- +migrate Down
+
+alter table item add column tmdb_id integer;
+
+update item set tmdb_id = cast(external_id.value as integer)
+from external_id
+where external_id.item_id = item.id and external_id.source = 'tmdb';
+
+drop table external_id;
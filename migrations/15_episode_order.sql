@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table item add column episode_order text not null default 'aired';
+
+-- +migrate Down
+
+alter table item drop column episode_order;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table item add column metadata_sources jsonb not null default '{}';
+
+-- +migrate Down
+
+alter table item drop column metadata_sources;
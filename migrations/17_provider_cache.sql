@@ -0,0 +1,15 @@
+-- +migrate Up
+
+create table provider_cache
+(
+    key        text primary key,
+    value      jsonb     not null default 'null',
+    not_found  boolean   not null default false,
+    expires_at timestamp not null
+);
+
+create index provider_cache_expires_at on provider_cache (expires_at);
+
+-- +migrate Down
+
+drop table provider_cache;
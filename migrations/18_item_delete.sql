@@ -0,0 +1,43 @@
+-- +migrate Up
+
+alter table item_status drop constraint item_status_item_id_fkey;
+alter table item_status add constraint item_status_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+
+alter table movie drop constraint movies_item_id_fkey;
+alter table movie add constraint movies_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+
+alter table tv_series drop constraint tv_series_item_id_fkey;
+alter table tv_series add constraint tv_series_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+
+alter table tv_season drop constraint tv_seasons_item_id_fkey;
+alter table tv_season add constraint tv_seasons_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+
+alter table tv_episode drop constraint tv_episodes_item_id_fkey;
+alter table tv_episode add constraint tv_episodes_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+
+-- +migrate Down
+
+alter table tv_episode drop constraint tv_episodes_item_id_fkey;
+alter table tv_episode add constraint tv_episodes_item_id_fkey
+    foreign key (item_id) references item;
+
+alter table tv_season drop constraint tv_seasons_item_id_fkey;
+alter table tv_season add constraint tv_seasons_item_id_fkey
+    foreign key (item_id) references item;
+
+alter table tv_series drop constraint tv_series_item_id_fkey;
+alter table tv_series add constraint tv_series_item_id_fkey
+    foreign key (item_id) references item;
+
+alter table movie drop constraint movies_item_id_fkey;
+alter table movie add constraint movies_item_id_fkey
+    foreign key (item_id) references item;
+
+alter table item_status drop constraint item_status_item_id_fkey;
+alter table item_status add constraint item_status_item_id_fkey
+    foreign key (item_id) references item;
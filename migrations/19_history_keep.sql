@@ -0,0 +1,20 @@
+-- +migrate Up
+
+alter table history add column title text not null default '';
+update history set title = coalesce(item.title, '') from item where item.id = history.item_id;
+
+alter table history alter column item_id drop not null;
+alter table history drop constraint history_item_id_fkey;
+alter table history add constraint history_item_id_fkey
+    foreign key (item_id) references item on delete set null;
+
+-- +migrate Down
+
+delete from history where item_id is null;
+
+alter table history drop constraint history_item_id_fkey;
+alter table history add constraint history_item_id_fkey
+    foreign key (item_id) references item on delete cascade;
+alter table history alter column item_id set not null;
+
+alter table history drop column title;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table item add column path text not null default '';
+
+-- +migrate Down
+
+alter table item drop column path;
@@ -0,0 +1,21 @@
+-- +migrate Up
+
+create table media_file
+(
+    id             uuid primary key,
+    item_id        uuid      not null references item on delete cascade,
+    season_number  integer,
+    episode_number integer,
+    path           text      not null unique,
+    size           bigint    not null,
+    quality        text      not null default '',
+    release_group  text      not null default '',
+    imported_at    timestamp not null,
+    modified_at    timestamp not null
+);
+
+create index media_file_item_id on media_file (item_id);
+
+-- +migrate Down
+
+drop table media_file;
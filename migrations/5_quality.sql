@@ -0,0 +1,32 @@
+-- +migrate Up
+
+create table quality_profile
+(
+    id      serial primary key,
+    name    text   not null unique,
+    allowed text[] not null default '{}',
+    cutoff  text   not null
+);
+
+insert into quality_profile (name, cutoff) values ('Any', 'Bluray-1080p');
+
+alter table item add column quality_profile_id integer not null default 1 references quality_profile;
+
+create table history
+(
+    id      bigserial primary key,
+    item_id uuid      not null references item on delete cascade,
+    event   text      not null,
+    date    timestamp not null,
+    data    jsonb     not null default 'null'
+);
+
+create index history_item_id on history (item_id);
+
+-- +migrate Down
+
+drop table history;
+
+alter table item drop column quality_profile_id;
+
+drop table quality_profile;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+create table api_key
+(
+    id         uuid primary key,
+    name       text      not null,
+    hash       text      not null unique,
+    created_at timestamp not null
+);
+
+-- +migrate Down
+
+drop table api_key;
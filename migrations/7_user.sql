@@ -0,0 +1,30 @@
+-- +migrate Up
+
+create table user_account
+(
+    id            uuid primary key,
+    name          text      not null unique,
+    password_hash text      not null,
+    role          text      not null,
+    request_quota integer   not null default 0,
+    quota_days    integer   not null default 7,
+    created_at    timestamp not null
+);
+
+create table session
+(
+    token_hash text primary key,
+    user_id    uuid      not null references user_account on delete cascade,
+    expires_at timestamp not null
+);
+
+alter table item add column requested_by uuid references user_account on delete set null;
+alter table item add column added_at timestamp not null default (now() at time zone 'utc');
+
+-- +migrate Down
+
+alter table item drop column added_at;
+alter table item drop column requested_by;
+
+drop table session;
+drop table user_account;
@@ -0,0 +1,15 @@
+-- +migrate Up
+
+create extension if not exists pg_trgm;
+
+alter table item add column release_year integer not null default 0;
+alter table item add column genres text[] not null default '{}';
+
+create index item_title_trgm_idx on item using gin (title gin_trgm_ops);
+
+-- +migrate Down
+
+drop index item_title_trgm_idx;
+
+alter table item drop column genres;
+alter table item drop column release_year;
@@ -0,0 +1,33 @@
+-- +migrate Up
+
+create table webhook
+(
+    id         uuid primary key,
+    name       text      not null unique,
+    url        text      not null,
+    secret     text      not null default '',
+    events     text[]    not null default '{}',
+    enabled    boolean   not null default true,
+    created_at timestamp not null
+);
+
+create table webhook_delivery
+(
+    id          bigserial primary key,
+    webhook_id  uuid      not null references webhook on delete cascade,
+    event_id    bigint    not null,
+    event_type  text      not null,
+    payload     jsonb     not null,
+    attempt     integer   not null,
+    status_code integer   not null default 0,
+    error       text      not null default '',
+    success     boolean   not null,
+    date        timestamp not null
+);
+
+create index webhook_delivery_webhook_id_idx on webhook_delivery (webhook_id, date desc);
+
+-- +migrate Down
+
+drop table webhook_delivery;
+drop table webhook;
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	apiKeyHeader         = "X-API-Key"
+	apiKeyQueryParameter = "apikey"
+	bearerPrefix         = "Bearer "
+)
+
+var (
+	ErrUnauthorized = &Error{
+		Message:    "Missing or invalid API key or session token",
+		StatusCode: http.StatusUnauthorized,
+	}
+	ErrForbidden = &Error{
+		Message:    "Insufficient permissions",
+		StatusCode: http.StatusForbidden,
+	}
+)
+
+type principalContextKey struct{}
+
+// principal is the authenticated caller of a request. Requests authenticated
+// by an API key have no user and the admin role.
+type principal struct {
+	User *model.User
+	Role model.Role
+}
+
+func principalFromContext(ctx context.Context) principal {
+	p, _ := ctx.Value(principalContextKey{}).(principal)
+	return p
+}
+
+// authenticate rejects requests without either a valid session token in the
+// Authorization header or a valid API key in the X-API-Key header or, for
+// webhook callers, the apikey query parameter.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.principal(r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p)))
+	})
+}
+
+func (s *Server) principal(r *http.Request) (principal, *Error) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, bearerPrefix) {
+		user, err := s.db.GetSessionUser(auth.HashSessionToken(strings.TrimPrefix(header, bearerPrefix)))
+		if err == sql.ErrNoRows {
+			return principal{}, ErrUnauthorized
+		}
+		if err != nil {
+			s.logger.Error("get session: ", err)
+			return principal{}, &Error{
+				Message:    "Could not verify session token",
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+		return principal{User: user, Role: user.Role}, nil
+	}
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(apiKeyQueryParameter)
+	}
+	if key == "" {
+		return principal{}, ErrUnauthorized
+	}
+	keys, err := s.db.ListAPIKeys()
+	if err != nil {
+		s.logger.Error("list API keys: ", err)
+		return principal{}, &Error{
+			Message:    "Could not verify API key",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if !auth.MatchAPIKey(key, keys) {
+		return principal{}, ErrUnauthorized
+	}
+	return principal{Role: model.RoleAdmin}, nil
+}
+
+// authorize rejects requests whose caller does not have at least the given
+// role.
+func (s *Server) authorize(role model.Role, f func(http.ResponseWriter, *http.Request) *Error) func(http.ResponseWriter, *http.Request) *Error {
+	return func(w http.ResponseWriter, r *http.Request) *Error {
+		if !principalFromContext(r.Context()).Role.Includes(role) {
+			return ErrForbidden
+		}
+		return f(w, r)
+	}
+}
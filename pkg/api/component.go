@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/satori/go.uuid"
+
+	"github.com/KnutZuidema/godarr/pkg/component"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+type componentRequest struct {
+	Category model.ComponentCategory `json:"category"`
+	Type     string                  `json:"type"`
+	Name     string                  `json:"name"`
+	Enabled  *bool                   `json:"enabled"`
+	Settings model.JSON              `json:"settings"`
+}
+
+// componentResponse is a component together with the error which occurred
+// while instantiating it and the health of indexers and providers. Secret
+// settings are redacted.
+type componentResponse struct {
+	*model.Component
+	Error  string        `json:"error,omitempty"`
+	Health *model.Health `json:"health,omitempty"`
+}
+
+type componentTestResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+var errReadOnlyComponent = &Error{
+	Message:    "Components of the configuration file are read only",
+	StatusCode: http.StatusBadRequest,
+}
+
+func (s *Server) componentResponse(c *model.Component) componentResponse {
+	res := componentResponse{Component: component.Redact(c)}
+	if err := s.Components.Error(c.ID); err != nil {
+		res.Error = err.Error()
+	}
+	res.Health = s.Components.Health(c.ID)
+	return res
+}
+
+func (s *Server) listComponents(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Components == nil {
+		return notConfigured("Components")
+	}
+	components, err := s.Components.List()
+	if err != nil {
+		return &Error{
+			Message:    "Could not list components",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	category := model.ComponentCategory(r.URL.Query().Get("category"))
+	res := []componentResponse{}
+	for _, c := range components {
+		if category == "" || c.Category == category {
+			res = append(res, s.componentResponse(c))
+		}
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) getComponent(w http.ResponseWriter, r *http.Request) *Error {
+	c, err1 := s.componentFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	if err := json.NewEncoder(w).Encode(s.componentResponse(c)); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) addComponent(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Components == nil {
+		return notConfigured("Components")
+	}
+	var request componentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	c := &model.Component{
+		ID:        uuid.NewV4().String(),
+		Category:  request.Category,
+		Type:      request.Type,
+		Name:      request.Name,
+		Enabled:   request.Enabled == nil || *request.Enabled,
+		Settings:  request.Settings,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.validateComponent(c); err != nil {
+		return err
+	}
+	c, err := s.db.CreateComponent(c)
+	if err != nil {
+		return &Error{
+			Message:    "Could not add component",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	s.reloadComponents()
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(s.componentResponse(c)); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) updateComponent(w http.ResponseWriter, r *http.Request) *Error {
+	c, err1 := s.componentFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	if c.ReadOnly {
+		return errReadOnlyComponent
+	}
+	var request componentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Category != "" && request.Category != c.Category {
+		return &Error{
+			Message:    "Category can not be changed",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if request.Settings != nil {
+		componentType := c.Type
+		if request.Type != "" {
+			componentType = request.Type
+		}
+		settings, err := component.KeepSecrets(c, componentType, request.Settings)
+		if err != nil {
+			return &Error{
+				Message:    "Could not read stored settings",
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+		c.Settings = settings
+	}
+	if request.Type != "" {
+		c.Type = request.Type
+	}
+	if request.Name != "" {
+		c.Name = request.Name
+	}
+	if request.Enabled != nil {
+		c.Enabled = *request.Enabled
+	}
+	if err := s.validateComponent(c); err != nil {
+		return err
+	}
+	c, err := s.db.UpdateComponent(c)
+	if err != nil {
+		return &Error{
+			Message:    "Could not update component",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	s.reloadComponents()
+	if err := json.NewEncoder(w).Encode(s.componentResponse(c)); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) deleteComponent(w http.ResponseWriter, r *http.Request) *Error {
+	c, err1 := s.componentFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	if c.ReadOnly {
+		return errReadOnlyComponent
+	}
+	if err := s.db.DeleteComponent(c.ID); err != nil {
+		return &Error{
+			Message:    "Could not delete component",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	s.reloadComponents()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// testComponent tests the connection of an existing component.
+func (s *Server) testComponent(w http.ResponseWriter, r *http.Request) *Error {
+	c, err1 := s.componentFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	return s.writeComponentTest(w, c)
+}
+
+// testComponentDefinition tests the connection of a component which is not
+// stored yet.
+func (s *Server) testComponentDefinition(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Components == nil {
+		return notConfigured("Components")
+	}
+	var request componentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	return s.writeComponentTest(w, &model.Component{
+		Category: request.Category,
+		Type:     request.Type,
+		Name:     request.Name,
+		Settings: request.Settings,
+	})
+}
+
+func (s *Server) writeComponentTest(w http.ResponseWriter, c *model.Component) *Error {
+	var result componentTestResult
+	if message, err := s.Components.Test(c); err != nil {
+		result.Message = err.Error()
+	} else {
+		result.Success = true
+		result.Message = message
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) validateComponent(c *model.Component) *Error {
+	if c.Name == "" {
+		return &Error{
+			Message:    "Name has to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err := component.Validate(c); err != nil {
+		return &Error{
+			Message:    err.Error(),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	components, err := s.Components.List()
+	if err != nil {
+		return &Error{
+			Message:    "Could not verify uniqueness of name",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	for _, existing := range components {
+		if existing.ID != c.ID && existing.Category == c.Category && existing.Name == c.Name {
+			return &Error{
+				Message:    "Component with this name already exists",
+				Link:       "/component/" + existing.ID,
+				StatusCode: http.StatusConflict,
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) reloadComponents() {
+	if err := s.Components.Reload(); err != nil {
+		s.logger.Error("reload components: ", err)
+	}
+}
+
+func (s *Server) componentFromPath(r *http.Request) (*model.Component, *Error) {
+	if s.Components == nil {
+		return nil, notConfigured("Components")
+	}
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return nil, &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	c, err := s.Components.Get(id)
+	if err != nil {
+		return nil, &Error{
+			Message:    "Could not find component",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,567 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+const (
+	contractAPIKey = "contract-test-key"
+	contractItemID = "5c7a1a4e-2b8f-4d3e-9a61-0f0c7f3b2d11"
+	contractUserID = "0b6ab9a2-8d7c-4f51-a7c4-3f0e2c1d9e42"
+)
+
+// contractDB serves the data of the contract test from memory, other methods
+// of the database are not used by the requests of the test.
+type contractDB struct {
+	database.Database
+	items []*model.Item
+}
+
+func newContractDB() *contractDB {
+	refreshed := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	requestedBy := contractUserID
+	return &contractDB{
+		items: []*model.Item{
+			{
+				ID:               contractItemID,
+				ExternalID:       "tt0113277",
+				Kind:             model.ItemKindMovie,
+				Title:            "Heat",
+				Description:      "A group of professional bank robbers.",
+				ImagePath:        "/heat.jpg",
+				ReleaseYear:      1995,
+				Genres:           []string{"Crime", "Thriller"},
+				Rating:           7.9,
+				Status:           model.ItemStatusDownloaded,
+				Path:             "/movies/Heat (1995)",
+				QualityProfileID: 1,
+				RequestedBy:      &requestedBy,
+				AddedAt:          time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC),
+				RefreshedAt:      &refreshed,
+				MetadataSources:  model.MetadataSources{model.MetadataFieldTitle: "tmdb"},
+				ExternalIDs:      model.ExternalIDs{TMDB: "949", IMDb: "tt0113277"},
+			},
+			{
+				ID:               "9d1e7c3a-5f2b-4e8d-b6a4-1c2d3e4f5a6b",
+				ExternalID:       "81189",
+				Kind:             model.ItemKindTVSeries,
+				Title:            "Breaking Bad",
+				Status:           model.ItemStatusAdded,
+				QualityProfileID: 1,
+				AddedAt:          time.Date(2026, 9, 2, 12, 0, 0, 0, time.UTC),
+				EpisodeOrder:     model.EpisodeOrderAired,
+				ExternalIDs:      model.ExternalIDs{TVDb: "81189", TMDB: "1396"},
+			},
+		},
+	}
+}
+
+func (d *contractDB) ListAPIKeys() ([]*model.APIKey, error) {
+	return []*model.APIKey{{ID: "1", Name: "test", Hash: auth.HashAPIKey(contractAPIKey)}}, nil
+}
+
+func (d *contractDB) GetItem(id string) (*model.Item, error) {
+	for _, item := range d.items {
+		if item.ID == id {
+			copied := *item
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *contractDB) GetItemBySourceID(source model.ExternalIDSource, id string) (*model.Item, error) {
+	for _, item := range d.items {
+		if item.ExternalIDs.Get(source) == id {
+			return item, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *contractDB) GetItemByExternalID(id string) (*model.Item, error) {
+	for _, item := range d.items {
+		if item.ExternalID == id {
+			return item, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *contractDB) CreateItem(item *model.Item) (*model.Item, error) {
+	created := *item
+	created.AddedAt = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	return &created, nil
+}
+
+func (d *contractDB) DeleteItem(id string) error {
+	return nil
+}
+
+func (d *contractDB) ListItems(filter model.ItemFilter, offset, count int) ([]*model.Item, error) {
+	if offset >= len(d.items) {
+		return []*model.Item{}, nil
+	}
+	items := d.items[offset:]
+	if len(items) > count {
+		items = items[:count]
+	}
+	return items, nil
+}
+
+func (d *contractDB) CountItems(filter model.ItemFilter) (int, error) {
+	return len(d.items), nil
+}
+
+func (d *contractDB) CountItemsRequestedSince(userID string, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (d *contractDB) ListMediaFiles(itemID string) ([]*model.MediaFile, error) {
+	season, episode := 1, 2
+	return []*model.MediaFile{
+		{
+			ID:           "3f2e1d0c-9b8a-4765-8432-10fedcba9876",
+			ItemID:       itemID,
+			Path:         "/movies/Heat (1995)/Heat (1995).mkv",
+			Size:         8 << 30,
+			Quality:      "Bluray-1080p",
+			ReleaseGroup: "GROUP",
+			ImportedAt:   time.Date(2026, 9, 3, 12, 0, 0, 0, time.UTC),
+			ModifiedAt:   time.Date(2026, 9, 3, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:            "4a3b2c1d-0e9f-4876-9543-21fedcba9876",
+			ItemID:        itemID,
+			SeasonNumber:  &season,
+			EpisodeNumber: &episode,
+			Path:          "/tv/Show/Season 01/Show - S01E02.mkv",
+			Size:          1 << 30,
+			Quality:       "WEB-1080p",
+			ImportedAt:    time.Date(2026, 9, 3, 12, 0, 0, 0, time.UTC),
+			ModifiedAt:    time.Date(2026, 9, 3, 12, 0, 0, 0, time.UTC),
+		},
+	}, nil
+}
+
+func (d *contractDB) ListItemHistory(itemID string) ([]*model.HistoryEntry, error) {
+	return []*model.HistoryEntry{
+		{
+			ID:     1,
+			ItemID: itemID,
+			Event:  model.HistoryEventGrabbed,
+			Date:   time.Date(2026, 9, 3, 11, 0, 0, 0, time.UTC),
+			Data:   model.JSON(`{"indexer":"rss","release":"Heat.1995.1080p.BluRay-GROUP","size":1,"quality":"Bluray-1080p"}`),
+		},
+	}, nil
+}
+
+func (d *contractDB) ListHistory(offset, count int) ([]*model.HistoryEntry, error) {
+	return d.ListItemHistory(contractItemID)
+}
+
+func (d *contractDB) CountHistory() (int, error) {
+	return 1, nil
+}
+
+func (d *contractDB) GetQualityProfile(id int) (*model.QualityProfile, error) {
+	if id != model.DefaultQualityProfileID {
+		return nil, sql.ErrNoRows
+	}
+	return &model.QualityProfile{ID: id, Name: "Any", Allowed: []model.Quality{}, Cutoff: "Bluray-1080p"}, nil
+}
+
+func (d *contractDB) ListQualityProfiles() ([]*model.QualityProfile, error) {
+	profile, _ := d.GetQualityProfile(model.DefaultQualityProfileID)
+	return []*model.QualityProfile{profile}, nil
+}
+
+func (d *contractDB) ListUsers() ([]*model.User, error) {
+	return []*model.User{
+		{
+			ID:           contractUserID,
+			Name:         "admin",
+			PasswordHash: "secret",
+			Role:         model.RoleAdmin,
+			QuotaDays:    7,
+			CreatedAt:    time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}, nil
+}
+
+// contractProvider discovers the items of the provider without a request.
+type contractProvider struct{}
+
+func (contractProvider) ListBySearch(search string) ([]*model.Item, error) {
+	return nil, nil
+}
+
+func (contractProvider) GetByID(id string) (*model.Item, error) {
+	return nil, provider.NotFoundError{Message: "unknown"}
+}
+
+func (contractProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	return []*model.Item{
+		{
+			ExternalID:  "tt0113277",
+			Kind:        model.ItemKindMovie,
+			Title:       "Heat",
+			ReleaseYear: 1995,
+			Genres:      []string{"Crime"},
+			Status:      model.ItemStatusAdded,
+			ExternalIDs: model.ExternalIDs{TMDB: "949"},
+		},
+		{
+			ExternalID:  "tt0110912",
+			Kind:        model.ItemKindMovie,
+			Title:       "Pulp Fiction",
+			Status:      model.ItemStatusAdded,
+			ExternalIDs: model.ExternalIDs{TMDB: "680"},
+		},
+	}, nil
+}
+
+func (p contractProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	return p.Discover(model.DiscoverListPopular, page)
+}
+
+// contractCase is a request whose response is validated against the
+// operation of the path and method in openapi.yml.
+type contractCase struct {
+	method string
+	// path template as in openapi.yml
+	path string
+	// request URL, defaults to path
+	url    string
+	body   string
+	noAuth bool
+	status int
+}
+
+func TestContract(t *testing.T) {
+	spec := loadSpec(t)
+	server := NewServer(newContractDB(), make(chan model.Item, 10), quietLogger())
+	server.Providers = map[model.ItemKind]provider.Provider{
+		model.ItemKindMovie: contractProvider{},
+	}
+	item := "/item/" + contractItemID
+	for _, c := range []contractCase{
+		{method: http.MethodGet, path: "/item/{id}", url: item, status: http.StatusOK},
+		{method: http.MethodGet, path: "/item/{id}", url: "/item/unknown", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/item/{id}", url: item, noAuth: true, status: http.StatusUnauthorized},
+		{method: http.MethodDelete, path: "/item/{id}", url: item, status: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/item/{id}", url: "/item/unknown", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/item/{id}/files", url: item + "/files", status: http.StatusOK},
+		{method: http.MethodGet, path: "/item/{id}/history", url: item + "/history", status: http.StatusOK},
+		{method: http.MethodGet, path: "/item/{id}/recommendations", url: item + "/recommendations", status: http.StatusOK},
+		{method: http.MethodGet, path: "/item/{id}/recommendations", url: item + "/recommendations?relation=liked", status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/item", status: http.StatusOK},
+		{method: http.MethodGet, path: "/item", url: "/item?offset=1&count=100&sort=-title", status: http.StatusOK},
+		{method: http.MethodGet, path: "/item", url: "/item?count=101", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/item", body: `{"externalId":"tt0110912","externalIdSource":"imdb","kind":"movie"}`, status: http.StatusCreated},
+		{method: http.MethodPost, path: "/item", body: `{"externalId":"949","externalIdSource":"tmdb","kind":"movie"}`, status: http.StatusConflict},
+		{method: http.MethodPost, path: "/item", body: `{}`, status: http.StatusBadRequest},
+		// without a source the ID is the primary external ID of the kind
+		{method: http.MethodPost, path: "/item", body: `{"externalId":"tt0113277"}`, status: http.StatusConflict},
+		{method: http.MethodPost, path: "/item", body: `{"externalId":"81189","kind":"tv-series"}`, status: http.StatusConflict},
+		{method: http.MethodPost, path: "/item", body: `{"externalId":"tt0109830","kind":"movie"}`, status: http.StatusCreated},
+		{method: http.MethodGet, path: "/history", status: http.StatusOK},
+		{method: http.MethodGet, path: "/discover/{list}", url: "/discover/popular", status: http.StatusOK},
+		{method: http.MethodGet, path: "/discover/{list}", url: "/discover/popular?kind=tv-series", status: http.StatusServiceUnavailable},
+		{method: http.MethodGet, path: "/discover/{list}", url: "/discover/latest", status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/qualityprofile", status: http.StatusOK},
+		{method: http.MethodGet, path: "/user", status: http.StatusOK},
+	} {
+		url := c.url
+		if url == "" {
+			url = c.path
+		}
+		name := c.method + " " + url
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, url, strings.NewReader(c.body))
+			if !c.noAuth {
+				r.Header.Set(apiKeyHeader, contractAPIKey)
+			}
+			w := httptest.NewRecorder()
+			server.Router.ServeHTTP(w, r)
+			if w.Code != c.status {
+				t.Fatalf("status %d, expected %d: %s", w.Code, c.status, w.Body)
+			}
+			response, ok := spec.response(c.path, c.method, w.Code)
+			if !ok {
+				t.Fatalf("status %d is not documented", w.Code)
+			}
+			schema := response.lookup("content", "application/json", "schema")
+			if schema == nil {
+				if w.Body.Len() > 0 {
+					t.Fatalf("undocumented body %s", w.Body)
+				}
+				return
+			}
+			var body interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			for _, err := range spec.validate(schema, body, "body") {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestContractRoutes checks that every route of the router is documented.
+func TestContractRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	server := NewServer(newContractDB(), nil, quietLogger())
+	err := server.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if spec.lookup("paths", path, strings.ToLower(method)) == nil {
+				t.Errorf("%s %s is not documented", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func quietLogger() log.FieldLogger {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return logger
+}
+
+// spec is a decoded part of openapi.yml.
+type spec map[string]interface{}
+
+func loadSpec(t *testing.T) spec {
+	data, err := ioutil.ReadFile("../../openapi.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	return normalize(raw).(map[string]interface{})
+}
+
+// normalize converts the maps of YAML into maps with string keys, like the
+// status codes of responses.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalize(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+	return v
+}
+
+// lookup returns the value at the keys, or nil if any of them is missing.
+func (s spec) lookup(keys ...string) spec {
+	var current interface{} = map[string]interface{}(s)
+	for _, key := range keys {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[key]; !ok {
+			return nil
+		}
+	}
+	m, _ := current.(map[string]interface{})
+	return m
+}
+
+// resolve follows the reference of a part of the spec.
+func (s spec) resolve(part spec) spec {
+	for {
+		ref, ok := part["$ref"].(string)
+		if !ok {
+			return part
+		}
+		part = s.lookup(strings.Split(strings.TrimPrefix(ref, "#/"), "/")...)
+	}
+}
+
+func (s spec) response(path, method string, status int) (spec, bool) {
+	response := s.lookup("paths", path, strings.ToLower(method), "responses", strconv.Itoa(status))
+	if response == nil {
+		return nil, false
+	}
+	return s.resolve(response), true
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// validate returns the differences between a decoded JSON value and a
+// schema. Objects must not have properties which are not documented.
+func (s spec) validate(schema spec, value interface{}, at string) []string {
+	schema = s.resolve(schema)
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + " is null"}
+	}
+	var errs []string
+	if parts, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, part := range parts {
+			if len(s.validate(part.(map[string]interface{}), value, at)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, fmt.Sprintf("%s matches %d schemas of oneOf", at, matches))
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s %v is not one of %v", at, value, enum))
+		}
+	}
+	typ, _ := schema["type"].(string)
+	if typ == "" && (schema["properties"] != nil || schema["allOf"] != nil) {
+		typ = "object"
+	}
+	switch typ {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s %v is not a string", at, value))
+		}
+		switch schema["format"] {
+		case "uuid":
+			if !uuidPattern.MatchString(str) {
+				errs = append(errs, fmt.Sprintf("%s %q is not a UUID", at, str))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				errs = append(errs, fmt.Sprintf("%s %q is not a date-time", at, str))
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s %v is not a number", at, value))
+		}
+		if typ == "integer" && n != float64(int64(n)) {
+			errs = append(errs, fmt.Sprintf("%s %v is not an integer", at, value))
+		}
+		if max, ok := schema["maximum"].(int); ok && n > float64(max) {
+			errs = append(errs, fmt.Sprintf("%s %v exceeds %d", at, value, max))
+		}
+		if min, ok := schema["minimum"].(int); ok && n < float64(min) {
+			errs = append(errs, fmt.Sprintf("%s %v is below %d", at, value, min))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s %v is not a boolean", at, value))
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s %v is not an array", at, value))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, element := range list {
+				errs = append(errs, s.validate(items, element, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s %v is not an object", at, value))
+		}
+		properties, required, additional := s.objectSchema(schema)
+		for _, name := range required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s is missing", at, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name]; ok {
+				errs = append(errs, s.validate(property, object[name], at+"."+name)...)
+			} else if additional != nil {
+				errs = append(errs, s.validate(additional, object[name], at+"."+name)...)
+			} else if len(properties) > 0 {
+				errs = append(errs, fmt.Sprintf("%s.%s is not documented", at, name))
+			}
+		}
+	}
+	return errs
+}
+
+// objectSchema merges the properties and required properties of an object
+// schema and the schemas of its allOf.
+func (s spec) objectSchema(schema spec) (map[string]spec, []string, spec) {
+	properties := map[string]spec{}
+	var required []string
+	additional, _ := schema["additionalProperties"].(map[string]interface{})
+	for name, property := range schema.lookup("properties") {
+		properties[name] = property.(map[string]interface{})
+	}
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, name := range list {
+			required = append(required, name.(string))
+		}
+	}
+	if parts, ok := schema["allOf"].([]interface{}); ok {
+		for _, part := range parts {
+			p, r, _ := s.objectSchema(s.resolve(part.(map[string]interface{})))
+			for name, property := range p {
+				properties[name] = property
+			}
+			required = append(required, r...)
+		}
+	}
+	return properties, required, additional
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+const (
+	listPathParameter = "list"
+	// TMDb does not return pages after 500
+	maxDiscoverPage = 500
+)
+
+// discoveredItem is an item of a provider, items which are in the library
+// have the ID of the library item.
+type discoveredItem struct {
+	*model.Item
+	InLibrary bool `json:"inLibrary"`
+}
+
+// listDiscover lists popular, top rated, upcoming or now playing movies or TV
+// series of the provider.
+func (s *Server) listDiscover(w http.ResponseWriter, r *http.Request) *Error {
+	list := model.DiscoverList(mux.Vars(r)[listPathParameter])
+	if !list.Valid() {
+		return &Error{
+			Message:    "Unknown list " + string(list),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	kind := model.ItemKind(r.URL.Query().Get("kind"))
+	switch kind {
+	case "":
+		kind = model.ItemKindMovie
+	case model.ItemKindMovie, model.ItemKindTVSeries:
+	default:
+		return &Error{
+			Message:    "Invalid kind",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	page, err1 := pageFromQuery(r)
+	if err1 != nil {
+		return err1
+	}
+	discoverer, ok := s.Providers[kind].(provider.Discoverer)
+	if !ok {
+		return notConfigured("Provider")
+	}
+	items, err := discoverer.Discover(list, page)
+	if err != nil {
+		s.logger.WithField("list", list).Error("discover: ", err)
+		return &Error{
+			Message:    "Could not list items",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return s.writeDiscovered(w, items)
+}
+
+// listItemRecommendations lists the recommendations for an item or, with the
+// relation similar, the items which are similar to it.
+func (s *Server) listItemRecommendations(w http.ResponseWriter, r *http.Request) *Error {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	relation := model.Relation(r.URL.Query().Get("relation"))
+	if relation == "" {
+		relation = model.RelationRecommendations
+	}
+	if !relation.Valid() {
+		return &Error{
+			Message:    "Invalid relation",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	page, err1 := pageFromQuery(r)
+	if err1 != nil {
+		return err1
+	}
+	item, err := s.db.GetItem(id)
+	if err != nil {
+		return &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	discoverer, ok := s.Providers[item.Kind].(provider.Discoverer)
+	if !ok {
+		return notConfigured("Provider")
+	}
+	items, err := discoverer.Related(item, relation, page)
+	if err != nil {
+		s.logger.WithField("item", item.ID).Error("list related items: ", err)
+		return &Error{
+			Message:    "Could not list items",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return s.writeDiscovered(w, items)
+}
+
+// pageFromQuery returns the page of the query, which defaults to the first.
+func pageFromQuery(r *http.Request) (int, *Error) {
+	value := r.URL.Query().Get("page")
+	if value == "" {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 1 || page > maxDiscoverPage {
+		return 0, &Error{
+			Message:    "Page has to be between 1 and " + strconv.Itoa(maxDiscoverPage),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return page, nil
+}
+
+// writeDiscovered marks the items which are in the library by their TMDb ID
+// and writes them.
+func (s *Server) writeDiscovered(w http.ResponseWriter, items []*model.Item) *Error {
+	res := make([]discoveredItem, 0, len(items))
+	for _, item := range items {
+		discovered := discoveredItem{Item: item}
+		if item.ExternalIDs.TMDB != "" {
+			existing, err := s.db.GetItemBySourceID(model.ExternalIDSourceTMDB, item.ExternalIDs.TMDB)
+			switch err {
+			case nil:
+				discovered.InLibrary = true
+				discovered.ID = existing.ID
+			case sql.ErrNoRows:
+			default:
+				return &Error{
+					Message:    "Could not verify existence of items",
+					StatusCode: http.StatusInternalServerError,
+				}
+			}
+		}
+		res = append(res, discovered)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
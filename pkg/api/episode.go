@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+func (s *Server) tvSeriesFromPath(r *http.Request) (*model.Item, *Error) {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return nil, &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	item, err := s.db.GetItem(id)
+	if err != nil {
+		return nil, &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	if item.Kind != model.ItemKindTVSeries {
+		return nil, &Error{
+			Message:    "Item is not a TV series",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return item, nil
+}
+
+// listItemEpisodes lists the episodes of a TV series in its episode order.
+func (s *Server) listItemEpisodes(w http.ResponseWriter, r *http.Request) *Error {
+	item, err1 := s.tvSeriesFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	lister, ok := s.Providers[model.ItemKindTVSeries].(provider.EpisodeLister)
+	if !ok {
+		return notConfigured("Episode provider")
+	}
+	episodes, err := lister.Episodes(item)
+	if err != nil {
+		s.logger.WithField("item", item.ID).Error("list episodes: ", err)
+		return &Error{
+			Message:    "Could not list episodes",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if episodes == nil {
+		episodes = []model.Episode{}
+	}
+	if err := json.NewEncoder(w).Encode(episodes); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+type episodeOrderRequest struct {
+	EpisodeOrder model.EpisodeOrder `json:"episodeOrder"`
+}
+
+// setItemEpisodeOrder switches a TV series between aired and DVD order.
+func (s *Server) setItemEpisodeOrder(w http.ResponseWriter, r *http.Request) *Error {
+	item, err1 := s.tvSeriesFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	var request episodeOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if !request.EpisodeOrder.Valid() {
+		return &Error{
+			Message:    "Invalid episode order",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err := s.db.SetItemEpisodeOrder(item.ID, request.EpisodeOrder); err != nil {
+		return &Error{
+			Message:    "Could not update episode order",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	item.EpisodeOrder = request.EpisodeOrder
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	heartbeatInterval = 30 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{}
+
+// subscribe subscribes to the event bus with the filter of the request. The
+// types query parameter is a comma separated list of event types and the item
+// query parameter an item ID. Missed events are replayed if the request
+// contains the Last-Event-ID header or query parameter.
+func (s *Server) subscribe(r *http.Request) (*event.Subscription, []model.Event, *Error) {
+	if s.Events == nil {
+		return nil, nil, notConfigured("Event bus")
+	}
+	query := r.URL.Query()
+	filter := event.Filter{
+		ItemID: query.Get("item"),
+	}
+	if types := query.Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, model.EventType(strings.TrimSpace(t)))
+		}
+	}
+	raw := r.Header.Get(lastEventIDHeader)
+	if raw == "" {
+		raw = query.Get("lastEventId")
+	}
+	var lastID int64
+	if raw != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(raw, 10, 64); err != nil || lastID < 0 {
+			return nil, nil, &Error{
+				Message:    "Last event ID has to be a non-negative integer",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	subscription, replay := s.Events.Subscribe(filter, lastID)
+	return subscription, replay, nil
+}
+
+// streamEvents streams events as server-sent events until the client
+// disconnects.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) *Error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return &Error{
+			Message:    "Streaming is not supported",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	subscription, replay, err1 := s.subscribe(r)
+	if err1 != nil {
+		return err1
+	}
+	defer subscription.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range replay {
+		if err := writeServerSentEvent(w, e); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-subscription.C:
+			if !ok {
+				return nil
+			}
+			if err := writeServerSentEvent(w, e); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case <-r.Context().Done():
+			return nil
+		case <-s.closing:
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, e model.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// streamEventsWebSocket streams events as JSON messages over a WebSocket until
+// the client disconnects.
+func (s *Server) streamEventsWebSocket(w http.ResponseWriter, r *http.Request) *Error {
+	subscription, replay, err1 := s.subscribe(r)
+	if err1 != nil {
+		return err1
+	}
+	defer subscription.Close()
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		return nil
+	}
+	defer conn.Close()
+	closed := make(chan struct{})
+	go func() {
+		// read until the client closes the connection, messages are ignored
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	write := func(v interface{}) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+			return err
+		}
+		return conn.WriteJSON(v)
+	}
+	for _, e := range replay {
+		if err := write(e); err != nil {
+			return nil
+		}
+	}
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-subscription.C:
+			if !ok {
+				return nil
+			}
+			if err := write(e); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return nil
+			}
+		case <-closed:
+			return nil
+		case <-s.closing:
+			_ = conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+				time.Now().Add(writeTimeout),
+			)
+			return nil
+		}
+	}
+}
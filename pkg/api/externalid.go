@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+// resolveItem builds a new item from its ID at the given source. Without a
+// source the ID is the primary external ID of the kind, IMDb for movies and
+// TheTVDB for TV series, as before IDs of other sources could be given. The
+// metadata and the IDs of the other sources are fetched from the provider of
+// its kind. TMDb IDs require the kind, since movies and TV series share them,
+// IDs of other sources are looked up with every provider. Without a provider
+// only the given ID is known.
+func (s *Server) resolveItem(kind model.ItemKind, source model.ExternalIDSource, id string) (*model.Item, *Error) {
+	if source != "" && !source.Valid() {
+		return nil, &Error{
+			Message:    "Unknown external ID source " + string(source),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	kinds := []model.ItemKind{kind}
+	if kind == "" {
+		if source == model.ExternalIDSourceTMDB && len(s.Providers) > 0 {
+			return nil, &Error{
+				Message:    "Kind has to be specified for TMDb IDs",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		kinds = []model.ItemKind{model.ItemKindMovie, model.ItemKindTVSeries}
+	}
+	for _, kind := range kinds {
+		if err := s.checkExisting(sourceOf(kind, source), id); err != nil {
+			return nil, err
+		}
+	}
+	for _, kind := range kinds {
+		source := sourceOf(kind, source)
+		finder, ok := s.Providers[kind].(provider.Finder)
+		if !ok {
+			continue
+		}
+		item, err := finder.Find(source, id)
+		if err != nil {
+			s.logger.WithFields(log.Fields{
+				"kind":   kind,
+				"source": source,
+				"id":     id,
+			}).Warn("find item with provider: ", err)
+			continue
+		}
+		if item.ExternalID == "" {
+			item.ExternalID = id
+		}
+		if err := s.checkExisting(model.PrimaryExternalIDSource(kind), item.ExternalID); err != nil {
+			return nil, err
+		}
+		for _, source := range model.ExternalIDSources {
+			if id := item.ExternalIDs.Get(source); id != "" {
+				if err := s.checkExisting(source, id); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return item, nil
+	}
+	item := &model.Item{
+		Kind:       kind,
+		ExternalID: id,
+	}
+	if kind != "" || source != "" {
+		item.ExternalIDs.Set(sourceOf(kind, source), id)
+	}
+	return item, nil
+}
+
+// sourceOf returns the source of an ID of an item of the kind, which defaults
+// to the primary external ID source of the kind.
+func sourceOf(kind model.ItemKind, source model.ExternalIDSource) model.ExternalIDSource {
+	if source == "" {
+		return model.PrimaryExternalIDSource(kind)
+	}
+	return source
+}
+
+// checkExisting returns a conflict if an item with the ID at the source
+// exists. Primary IDs are also compared with the external ID of items which
+// were added before their IDs were stored per source.
+func (s *Server) checkExisting(source model.ExternalIDSource, id string) *Error {
+	item, err := s.db.GetItemBySourceID(source, id)
+	if err == sql.ErrNoRows && source != model.ExternalIDSourceTMDB && source != model.ExternalIDSourceTVRage {
+		item, err = s.db.GetItemByExternalID(id)
+	}
+	switch err {
+	case nil:
+		return &Error{
+			Message:    "Item already exists",
+			Link:       "/item/" + item.ID,
+			StatusCode: http.StatusConflict,
+		}
+	case sql.ErrNoRows:
+		return nil
+	}
+	return &Error{
+		Message:    "Could not verify existence of item",
+		StatusCode: http.StatusInternalServerError,
+	}
+}
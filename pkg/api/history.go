@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+func (s *Server) getItemHistory(w http.ResponseWriter, r *http.Request) *Error {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if _, err := s.db.GetItem(id); err != nil {
+		return &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	entries, err := s.db.ListItemHistory(id)
+	if err != nil {
+		return &Error{
+			Message:    "Could not list history",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) *Error {
+	paging, err1 := pagingFromQuery(r)
+	if err1 != nil {
+		return err1
+	}
+	entries, err := s.db.ListHistory(paging.Offset, paging.Count)
+	if err != nil {
+		return &Error{
+			Message:    "Could not list history",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	total, err := s.db.CountHistory()
+	if err != nil {
+		return &Error{
+			Message:    "Could not count history",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(model.HistoryPaging{
+		Entries: entries,
+		Offset:  paging.Offset,
+		Count:   len(entries),
+		Total:   total,
+	}); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// itemFilterFromQuery reads the filter, search and sort query parameters of a
+// request listing items. The sort parameter may be prefixed with "-" to sort
+// in descending order.
+func itemFilterFromQuery(r *http.Request) (model.ItemFilter, *Error) {
+	query := r.URL.Query()
+	filter := model.ItemFilter{
+		Kind:   model.ItemKind(query.Get("kind")),
+		Status: model.ItemStatus(query.Get("status")),
+		Genre:  query.Get("genre"),
+		Search: strings.TrimSpace(query.Get("search")),
+	}
+	switch filter.Kind {
+	case "", model.ItemKindMovie, model.ItemKindTVSeries:
+	default:
+		return filter, invalidQueryParameter("kind")
+	}
+	switch filter.Status {
+	case "", model.ItemStatusAdded, model.ItemStatusMonitored, model.ItemStatusDownloaded:
+	default:
+		return filter, invalidQueryParameter("status")
+	}
+	for name, value := range map[string]*int{"minYear": &filter.MinYear, "maxYear": &filter.MaxYear} {
+		if raw := query.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return filter, invalidQueryParameter(name)
+			}
+			*value = n
+		}
+	}
+	for name, value := range map[string]*float64{"minRating": &filter.MinRating, "maxRating": &filter.MaxRating} {
+		if raw := query.Get(name); raw != "" {
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil || n < 0 {
+				return filter, invalidQueryParameter(name)
+			}
+			*value = n
+		}
+	}
+	if sort := query.Get("sort"); sort != "" {
+		if strings.HasPrefix(sort, "-") {
+			filter.Descending = true
+			sort = sort[1:]
+		}
+		filter.Sort = model.ItemSort(sort)
+		if !filter.Sort.Valid() {
+			return filter, invalidQueryParameter("sort")
+		}
+	}
+	return filter, nil
+}
+
+func invalidQueryParameter(name string) *Error {
+	return &Error{
+		Message:    "Invalid value for query parameter " + name,
+		StatusCode: http.StatusBadRequest,
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/KnutZuidema/godarr/pkg/library"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+type scanLibraryRequest struct {
+	Root string         `json:"root"`
+	Kind model.ItemKind `json:"kind"`
+}
+
+type importLibraryRequest struct {
+	Path             string                 `json:"path"`
+	Kind             model.ItemKind         `json:"kind"`
+	ExternalID       string                 `json:"externalId"`
+	ExternalIDSource model.ExternalIDSource `json:"externalIdSource"`
+	Files            []library.File         `json:"files"`
+}
+
+type importLibraryResult struct {
+	Path  string      `json:"path"`
+	Item  *model.Item `json:"item,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+func (s *Server) scanLibrary(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Library == nil {
+		return notConfigured("Library")
+	}
+	var request scanLibraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Root == "" {
+		return &Error{
+			Message:    "Root has to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	candidates, err := s.Library.Scan(request.Root, request.Kind)
+	if err != nil {
+		s.logger.WithField("root", request.Root).Error("scan library: ", err)
+		return &Error{
+			Message:    "Could not scan library",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if candidates == nil {
+		candidates = []*library.Candidate{}
+	}
+	if err := json.NewEncoder(w).Encode(candidates); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) importLibrary(w http.ResponseWriter, r *http.Request) *Error {
+	var requests []importLibraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		return ErrInvalidRequestBody
+	}
+	results := make([]importLibraryResult, 0, len(requests))
+	for _, request := range requests {
+		item, err := s.importLibraryItem(request)
+		results = append(results, importLibraryResult{
+			Path:  request.Path,
+			Item:  item,
+			Error: err,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+// importLibraryItem adds an item found in the library together with its
+// media files. The files are checked before the item is added, which is
+// stored with them in a single transaction, so no half imported item is left
+// behind.
+func (s *Server) importLibraryItem(request importLibraryRequest) (*model.Item, *Error) {
+	if request.ExternalID == "" || request.Path == "" {
+		return nil, &Error{
+			Message:    "External ID and path have to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	item, err1 := s.resolveItem(request.Kind, request.ExternalIDSource, request.ExternalID)
+	if err1 != nil {
+		return nil, err1
+	}
+	files := make([]*model.MediaFile, 0, len(request.Files))
+	for _, file := range request.Files {
+		mediaFile, err := libraryMediaFile(item.Kind, file)
+		if err != nil {
+			s.logger.WithField("path", file.Path).Error("import media file: ", err)
+			return nil, &Error{
+				Message:    "Could not read media file " + file.Path,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		files = append(files, mediaFile)
+	}
+	item.ID = uuid.NewV4().String()
+	item.Path = request.Path
+	item.QualityProfileID = model.DefaultQualityProfileID
+	item, err := s.db.ImportItem(item, files)
+	if err != nil {
+		s.logger.WithField("path", request.Path).Error("import library item: ", err)
+		return nil, &Error{
+			Message:    "Could not import item",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return item, nil
+}
+
+// libraryMediaFile reads a file found in the library, the item ID of the
+// returned media file is not set.
+func libraryMediaFile(kind model.ItemKind, file library.File) (*model.MediaFile, error) {
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	mediaFile := &model.MediaFile{
+		ID:           uuid.NewV4().String(),
+		Path:         file.Path,
+		Size:         info.Size(),
+		Quality:      library.ParseQuality(info.Name()),
+		ReleaseGroup: library.ParseReleaseGroup(info.Name()),
+		ImportedAt:   time.Now().UTC(),
+		ModifiedAt:   info.ModTime().UTC().Truncate(time.Microsecond),
+	}
+	if kind == model.ItemKindTVSeries {
+		season, episode := file.SeasonNumber, file.EpisodeNumber
+		mediaFile.SeasonNumber = &season
+		mediaFile.EpisodeNumber = &episode
+	}
+	return mediaFile, nil
+}
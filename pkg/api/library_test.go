@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// importDB records the imported items and media files, other methods of the
+// database are not used by the import. Items with a media file whose name
+// contains "unrecordable" fail to be imported and nothing is recorded.
+type importDB struct {
+	database.Database
+	items    map[string]*model.Item
+	files    []*model.MediaFile
+	statuses map[string]model.ItemStatus
+}
+
+func (d *importDB) ListAPIKeys() ([]*model.APIKey, error) {
+	return []*model.APIKey{{ID: "1", Name: "test", Hash: auth.HashAPIKey(contractAPIKey)}}, nil
+}
+
+func (d *importDB) GetItemBySourceID(source model.ExternalIDSource, id string) (*model.Item, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (d *importDB) GetItemByExternalID(id string) (*model.Item, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (d *importDB) ImportItem(item *model.Item, files []*model.MediaFile) (*model.Item, error) {
+	for _, file := range files {
+		if strings.Contains(file.Path, "unrecordable") {
+			return nil, errors.New("unrecordable")
+		}
+	}
+	created := *item
+	created.Status = model.ItemStatusDownloaded
+	d.items[item.ID] = &created
+	for _, file := range files {
+		file.ItemID = item.ID
+		d.files = append(d.files, file)
+	}
+	d.statuses[item.ID] = model.ItemStatusDownloaded
+	return &created, nil
+}
+
+func TestImportLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godarr-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"Heat.1995.1080p.BluRay-GROUP.mkv", "unrecordable.mkv"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, c := range map[string]struct {
+		files []string
+		// prefix of the error message, the status is not encoded
+		err   string
+		items int
+	}{
+		"imported":           {files: []string{"Heat.1995.1080p.BluRay-GROUP.mkv"}, items: 1},
+		"missing file":       {files: []string{"Heat.1995.1080p.BluRay-GROUP.mkv", "missing.mkv"}, err: "Could not read media file"},
+		"unrecordable file":  {files: []string{"Heat.1995.1080p.BluRay-GROUP.mkv", "unrecordable.mkv"}, err: "Could not import item"},
+		"item without files": {items: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := &importDB{items: map[string]*model.Item{}, statuses: map[string]model.ItemStatus{}}
+			server := NewServer(db, nil, quietLogger())
+			files := make([]map[string]interface{}, 0, len(c.files))
+			for _, file := range c.files {
+				files = append(files, map[string]interface{}{"path": filepath.Join(dir, file)})
+			}
+			body, err := json.Marshal([]map[string]interface{}{{
+				"path":             dir,
+				"kind":             model.ItemKindMovie,
+				"externalId":       "tt0113277",
+				"externalIdSource": model.ExternalIDSourceIMDb,
+				"files":            files,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/library/import", strings.NewReader(string(body)))
+			r.Header.Set(apiKeyHeader, contractAPIKey)
+			w := httptest.NewRecorder()
+			server.Router.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var results []struct {
+				Item  *model.Item `json:"item"`
+				Error *Error      `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 {
+				t.Fatalf("results %s: %v", w.Body, err)
+			}
+			result := results[0]
+			if c.err != "" {
+				if result.Error == nil || !strings.HasPrefix(result.Error.Message, c.err) || result.Item != nil {
+					t.Errorf("result %+v, %+v, expected error %q", result.Item, result.Error, c.err)
+				}
+			} else if result.Error != nil || result.Item == nil || result.Item.Status != model.ItemStatusDownloaded {
+				t.Errorf("unexpected result %+v, %+v", result.Item, result.Error)
+			}
+			if len(db.items) != c.items {
+				t.Errorf("%d items left, expected %d", len(db.items), c.items)
+			}
+			if c.items == 0 && len(db.files) > 0 {
+				t.Errorf("media files %+v left without item", db.files)
+			}
+			if c.items == 1 {
+				if len(db.files) != len(c.files) {
+					t.Errorf("%d media files, expected %d", len(db.files), len(c.files))
+				}
+				if status := db.statuses[result.Item.ID]; status != model.ItemStatusDownloaded {
+					t.Errorf("status %s, expected downloaded", status)
+				}
+			}
+		})
+	}
+}
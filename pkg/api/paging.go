@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const maxPagingCount = 100
+
+// pagingFromQuery reads the offset and count query parameters of a request.
+func pagingFromQuery(r *http.Request) (model.Paging, *Error) {
+	paging := model.Paging{
+		Count: defaultPagingCount,
+	}
+	query := r.URL.Query()
+	for name, value := range map[string]*int{"offset": &paging.Offset, "count": &paging.Count} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return paging, &Error{
+				Message:    "Query parameter " + name + " has to be a non-negative integer",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		*value = n
+	}
+	if paging.Count == 0 {
+		paging.Count = defaultPagingCount
+	}
+	if paging.Count > maxPagingCount {
+		return paging, &Error{
+			Message:    "Query parameter count must not exceed " + strconv.Itoa(maxPagingCount),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return paging, nil
+}
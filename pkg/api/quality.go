@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+func (s *Server) listQualityProfiles(w http.ResponseWriter, r *http.Request) *Error {
+	profiles, err := s.db.ListQualityProfiles()
+	if err != nil {
+		return &Error{
+			Message:    "Could not list quality profiles",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(profiles); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) addQualityProfile(w http.ResponseWriter, r *http.Request) *Error {
+	var request model.QualityProfile
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Name == "" || request.Cutoff == "" {
+		return &Error{
+			Message:    "Name and cutoff have to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	profile, err := s.db.CreateQualityProfile(&request)
+	if err != nil {
+		return &Error{
+			Message:    "Could not add quality profile",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(profile); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
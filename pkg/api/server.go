@@ -1,17 +1,23 @@
 package api
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/KnutZuidema/godarr/pkg/component"
 	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/library"
 	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+	"github.com/KnutZuidema/godarr/pkg/scheduler"
+	"github.com/KnutZuidema/godarr/pkg/webhook"
 )
 
 const (
@@ -21,7 +27,7 @@ const (
 
 type Error struct {
 	Message    string `json:"message"`
-	Link       string `json:"link,omitempty"`
+	Link       string `json:"url,omitempty"`
 	StatusCode int    `json:"-"`
 }
 
@@ -36,12 +42,28 @@ var (
 	}
 )
 
+func notConfigured(component string) *Error {
+	return &Error{
+		Message:    component + " is not configured",
+		StatusCode: http.StatusServiceUnavailable,
+	}
+}
+
 type Server struct {
 	Router     *mux.Router
 	db         database.Database
 	logger     log.FieldLogger
 	addedItems chan<- model.Item
 	AddTimeout time.Duration
+	Providers  map[model.ItemKind]provider.Provider
+	Library    *library.Scanner
+	Events     *event.Bus
+	Webhooks   *webhook.Dispatcher
+	Components *component.Manager
+	Tasks      *scheduler.Scheduler
+
+	closeStreams sync.Once
+	closing      chan struct{}
 }
 
 func NewServer(db database.Database, addedItems chan<- model.Item, logger log.FieldLogger) *Server {
@@ -53,12 +75,21 @@ func NewServer(db database.Database, addedItems chan<- model.Item, logger log.Fi
 		logger:     logger.WithField("component", "api server"),
 		addedItems: addedItems,
 		AddTimeout: 10 * time.Second,
+		closing:    make(chan struct{}),
 	}
 	s.Router = s.setupRouter()
 	return s
 }
 
-func (s Server) setupRouter() *mux.Router {
+// CloseStreams ends all event streams, so a graceful shutdown of the HTTP
+// server does not wait for them.
+func (s *Server) CloseStreams() {
+	s.closeStreams.Do(func() {
+		close(s.closing)
+	})
+}
+
+func (s *Server) setupRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -73,28 +104,70 @@ func (s Server) setupRouter() *mux.Router {
 			}).Info("completed request")
 		})
 	})
-	router.HandleFunc("/item/{id}", s.errorHandler(s.getItem)).Methods(http.MethodGet)
-	router.HandleFunc("/item", s.errorHandler(s.addItem)).Methods(http.MethodPost)
-	router.HandleFunc("/item", s.errorHandler(s.listItems)).Methods(http.MethodGet)
+	router.HandleFunc("/login", s.errorHandler(s.login)).Methods(http.MethodPost)
+	protected := router.PathPrefix("/").Subrouter()
+	protected.Use(s.authenticate)
+	protected.HandleFunc("/logout", s.errorHandler(s.logout)).Methods(http.MethodPost)
+	protected.HandleFunc("/item/{id}", s.errorHandler(s.authorize(model.RoleViewer, s.getItem))).Methods(http.MethodGet)
+	protected.HandleFunc("/item/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.deleteItem))).Methods(http.MethodDelete)
+	protected.HandleFunc("/item/{id}/files", s.errorHandler(s.authorize(model.RoleViewer, s.listItemFiles))).Methods(http.MethodGet)
+	protected.HandleFunc("/item/{id}/search", s.errorHandler(s.authorize(model.RoleMember, s.searchItem))).Methods(http.MethodPost)
+	protected.HandleFunc("/item/{id}/episodes", s.errorHandler(s.authorize(model.RoleViewer, s.listItemEpisodes))).Methods(http.MethodGet)
+	protected.HandleFunc("/item/{id}/episodeOrder", s.errorHandler(s.authorize(model.RoleMember, s.setItemEpisodeOrder))).Methods(http.MethodPut)
+	protected.HandleFunc("/item/{id}/recommendations", s.errorHandler(s.authorize(model.RoleViewer, s.listItemRecommendations))).Methods(http.MethodGet)
+	protected.HandleFunc("/item/{id}/history", s.errorHandler(s.authorize(model.RoleViewer, s.getItemHistory))).Methods(http.MethodGet)
+	protected.HandleFunc("/history", s.errorHandler(s.authorize(model.RoleViewer, s.listHistory))).Methods(http.MethodGet)
+	protected.HandleFunc("/events", s.errorHandler(s.authorize(model.RoleViewer, s.streamEvents))).Methods(http.MethodGet)
+	protected.HandleFunc("/events/ws", s.errorHandler(s.authorize(model.RoleViewer, s.streamEventsWebSocket))).Methods(http.MethodGet)
+	protected.HandleFunc("/item", s.errorHandler(s.authorize(model.RoleMember, s.addItem))).Methods(http.MethodPost)
+	protected.HandleFunc("/item", s.errorHandler(s.authorize(model.RoleViewer, s.listItems))).Methods(http.MethodGet)
+	protected.HandleFunc("/discover/{list}", s.errorHandler(s.authorize(model.RoleViewer, s.listDiscover))).Methods(http.MethodGet)
+	protected.HandleFunc("/qualityprofile", s.errorHandler(s.authorize(model.RoleViewer, s.listQualityProfiles))).Methods(http.MethodGet)
+	protected.HandleFunc("/qualityprofile", s.errorHandler(s.authorize(model.RoleAdmin, s.addQualityProfile))).Methods(http.MethodPost)
+	protected.HandleFunc("/library/scan", s.errorHandler(s.authorize(model.RoleAdmin, s.scanLibrary))).Methods(http.MethodPost)
+	protected.HandleFunc("/library/import", s.errorHandler(s.authorize(model.RoleAdmin, s.importLibrary))).Methods(http.MethodPost)
+	protected.HandleFunc("/user", s.errorHandler(s.authorize(model.RoleAdmin, s.listUsers))).Methods(http.MethodGet)
+	protected.HandleFunc("/user", s.errorHandler(s.authorize(model.RoleAdmin, s.addUser))).Methods(http.MethodPost)
+	protected.HandleFunc("/user/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.updateUser))).Methods(http.MethodPut)
+	protected.HandleFunc("/user/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.deleteUser))).Methods(http.MethodDelete)
+	protected.HandleFunc("/webhook", s.errorHandler(s.authorize(model.RoleAdmin, s.listWebhooks))).Methods(http.MethodGet)
+	protected.HandleFunc("/webhook", s.errorHandler(s.authorize(model.RoleAdmin, s.addWebhook))).Methods(http.MethodPost)
+	protected.HandleFunc("/webhook/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.updateWebhook))).Methods(http.MethodPut)
+	protected.HandleFunc("/webhook/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.deleteWebhook))).Methods(http.MethodDelete)
+	protected.HandleFunc("/webhook/{id}/deliveries", s.errorHandler(s.authorize(model.RoleAdmin, s.listWebhookDeliveries))).Methods(http.MethodGet)
+	protected.HandleFunc("/webhook/{id}/test", s.errorHandler(s.authorize(model.RoleAdmin, s.testWebhook))).Methods(http.MethodPost)
+	protected.HandleFunc("/component", s.errorHandler(s.authorize(model.RoleAdmin, s.listComponents))).Methods(http.MethodGet)
+	protected.HandleFunc("/component", s.errorHandler(s.authorize(model.RoleAdmin, s.addComponent))).Methods(http.MethodPost)
+	protected.HandleFunc("/component/test", s.errorHandler(s.authorize(model.RoleAdmin, s.testComponentDefinition))).Methods(http.MethodPost)
+	protected.HandleFunc("/component/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.getComponent))).Methods(http.MethodGet)
+	protected.HandleFunc("/component/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.updateComponent))).Methods(http.MethodPut)
+	protected.HandleFunc("/component/{id}", s.errorHandler(s.authorize(model.RoleAdmin, s.deleteComponent))).Methods(http.MethodDelete)
+	protected.HandleFunc("/component/{id}/test", s.errorHandler(s.authorize(model.RoleAdmin, s.testComponent))).Methods(http.MethodPost)
+	protected.HandleFunc("/system/tasks", s.errorHandler(s.authorize(model.RoleAdmin, s.listTasks))).Methods(http.MethodGet)
+	protected.HandleFunc("/system/tasks/{name}/run", s.errorHandler(s.authorize(model.RoleAdmin, s.runTask))).Methods(http.MethodPost)
 	return router
 }
 
-func (s Server) errorHandler(f func(http.ResponseWriter, *http.Request) *Error) func(http.ResponseWriter, *http.Request) {
+func (s *Server) errorHandler(f func(http.ResponseWriter, *http.Request) *Error) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err1 := f(w, r); err1 != nil {
-			w.WriteHeader(err1.StatusCode)
-			if err2 := json.NewEncoder(w).Encode(err1); err2 != nil {
-				s.logger.WithFields(log.Fields{
-					"path":   r.URL.Path,
-					"status": err1.StatusCode,
-					"error":  err1.Message,
-				}).Error(err2)
-			}
+		if err := f(w, r); err != nil {
+			s.writeError(w, r, err)
 		}
 	}
 }
 
-func (s Server) getItem(w http.ResponseWriter, r *http.Request) *Error {
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err1 *Error) {
+	w.WriteHeader(err1.StatusCode)
+	if err2 := json.NewEncoder(w).Encode(err1); err2 != nil {
+		s.logger.WithFields(log.Fields{
+			"path":   r.URL.Path,
+			"status": err1.StatusCode,
+			"error":  err1.Message,
+		}).Error(err2)
+	}
+}
+
+func (s *Server) getItem(w http.ResponseWriter, r *http.Request) *Error {
 	id, ok := mux.Vars(r)[idPathParameter]
 	if !ok {
 		return &Error{
@@ -115,8 +188,100 @@ func (s Server) getItem(w http.ResponseWriter, r *http.Request) *Error {
 	return nil
 }
 
-func (s Server) addItem(w http.ResponseWriter, r *http.Request) *Error {
-	var request model.Item
+// deleteItem deletes an item together with its status and media file
+// records, its history is kept. The files on disk are kept.
+func (s *Server) deleteItem(w http.ResponseWriter, r *http.Request) *Error {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if _, err := s.db.GetItem(id); err != nil {
+		return &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	if err := s.db.DeleteItem(id); err != nil {
+		return &Error{
+			Message:    "Could not delete item",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// searchItem searches all indexers for releases of an item once, independent
+// of the RSS sync.
+func (s *Server) searchItem(w http.ResponseWriter, r *http.Request) *Error {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	item, err := s.db.GetItem(id)
+	if err != nil {
+		return &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	timer := time.NewTimer(s.AddTimeout)
+	defer timer.Stop()
+	select {
+	case s.addedItems <- *item:
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	case <-timer.C:
+		return &Error{
+			Message:    "Timed out while trying to search item",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+}
+
+func (s *Server) listItemFiles(w http.ResponseWriter, r *http.Request) *Error {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if _, err := s.db.GetItem(id); err != nil {
+		return &Error{
+			Message:    "Could not find item",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	files, err := s.db.ListMediaFiles(id)
+	if err != nil {
+		return &Error{
+			Message:    "Could not list files",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+// addItemRequest adds an item by its ID at any external source.
+type addItemRequest struct {
+	ExternalID       string                 `json:"externalId"`
+	ExternalIDSource model.ExternalIDSource `json:"externalIdSource"`
+	Kind             model.ItemKind         `json:"kind"`
+	QualityProfileID int                    `json:"qualityProfileId"`
+}
+
+func (s *Server) addItem(w http.ResponseWriter, r *http.Request) *Error {
+	var request addItemRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		return ErrInvalidRequestBody
 	}
@@ -126,34 +291,45 @@ func (s Server) addItem(w http.ResponseWriter, r *http.Request) *Error {
 			StatusCode: http.StatusBadRequest,
 		}
 	}
-	if item, err := s.db.GetItemByExternalID(request.ExternalID); err != sql.ErrNoRows {
-		if err == nil {
-			return &Error{
-				Message:    "Item already exists",
-				Link:       "/item/" + item.ID,
-				StatusCode: http.StatusConflict,
-			}
-		}
+	if request.QualityProfileID == 0 {
+		request.QualityProfileID = model.DefaultQualityProfileID
+	}
+	if _, err := s.db.GetQualityProfile(request.QualityProfileID); err != nil {
 		return &Error{
-			Message:    "Could not verify existence of item",
-			StatusCode: http.StatusInternalServerError,
+			Message:    "Could not find quality profile",
+			StatusCode: http.StatusBadRequest,
 		}
 	}
-	item := model.Item{
-		ExternalID: request.ExternalID,
-		ID:         uuid.NewV4().String(),
-		Status:     model.ItemStatusAdded,
+	user := principalFromContext(r.Context()).User
+	if err := s.checkRequestQuota(user); err != nil {
+		return err
+	}
+	resolved, err1 := s.resolveItem(request.Kind, request.ExternalIDSource, request.ExternalID)
+	if err1 != nil {
+		return err1
+	}
+	item := *resolved
+	item.ID = uuid.NewV4().String()
+	item.Status = model.ItemStatusAdded
+	item.QualityProfileID = request.QualityProfileID
+	if user != nil {
+		item.RequestedBy = &user.ID
 	}
-	if _, err := s.db.CreateItem(&item); err != nil {
+	created, err := s.db.CreateItem(&item)
+	if err != nil {
 		return &Error{
 			Message:    "Could not add item",
 			StatusCode: http.StatusInternalServerError,
 		}
 	}
+	created.Status = item.Status
 	timer := time.NewTimer(s.AddTimeout)
 	select {
 	case s.addedItems <- item:
 		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(created); err != nil {
+			return ErrEncodeResponse
+		}
 		return nil
 	case <-timer.C:
 		return &Error{
@@ -163,22 +339,35 @@ func (s Server) addItem(w http.ResponseWriter, r *http.Request) *Error {
 	}
 }
 
-func (s Server) listItems(w http.ResponseWriter, r *http.Request) *Error {
-	var paging model.Paging
-	if err := json.NewDecoder(r.Body).Decode(&paging); err != nil {
-		return ErrInvalidRequestBody
+func (s *Server) listItems(w http.ResponseWriter, r *http.Request) *Error {
+	paging, err1 := pagingFromQuery(r)
+	if err1 != nil {
+		return err1
 	}
-	if paging.Count == 0 {
-		paging.Count = defaultPagingCount
+	filter, err1 := itemFilterFromQuery(r)
+	if err1 != nil {
+		return err1
 	}
-	items, err := s.db.ListItems(paging.Offset, paging.Count)
+	items, err := s.db.ListItems(filter, paging.Offset, paging.Count)
 	if err != nil {
 		return &Error{
 			Message:    "Could not list items",
 			StatusCode: http.StatusInternalServerError,
 		}
 	}
-	if err := json.NewEncoder(w).Encode(items); err != nil {
+	total, err := s.db.CountItems(filter)
+	if err != nil {
+		return &Error{
+			Message:    "Could not count items",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(model.ItemPaging{
+		Items:  items,
+		Offset: paging.Offset,
+		Count:  len(items),
+		Total:  total,
+	}); err != nil {
 		return ErrEncodeResponse
 	}
 	return nil
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/KnutZuidema/godarr/pkg/scheduler"
+)
+
+const taskNamePathParameter = "name"
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Tasks == nil {
+		return notConfigured("Scheduler")
+	}
+	if err := json.NewEncoder(w).Encode(s.Tasks.List()); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+// runTask starts a task immediately, it runs in the background.
+func (s *Server) runTask(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Tasks == nil {
+		return notConfigured("Scheduler")
+	}
+	switch err := s.Tasks.Trigger(mux.Vars(r)[taskNamePathParameter]); err {
+	case nil:
+	case scheduler.ErrUnknownTask:
+		return &Error{
+			Message:    "Could not find task",
+			StatusCode: http.StatusNotFound,
+		}
+	case scheduler.ErrTaskRunning:
+		return &Error{
+			Message:    "Task is already running",
+			StatusCode: http.StatusConflict,
+		}
+	default:
+		return &Error{
+			Message:    "Could not run task",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/satori/go.uuid"
+
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const defaultQuotaDays = 7
+
+type loginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	User      *model.User `json:"user"`
+}
+
+// userRequest adds or updates a user. Fields which are omitted keep their
+// value on updates.
+type userRequest struct {
+	Name         string     `json:"name"`
+	Password     string     `json:"password"`
+	Role         model.Role `json:"role"`
+	RequestQuota *int       `json:"requestQuota"`
+	QuotaDays    int        `json:"quotaDays"`
+}
+
+var errNegativeQuota = &Error{
+	Message:    "Request quota and quota days must not be negative",
+	StatusCode: http.StatusBadRequest,
+}
+
+// negativeQuota reports whether the request sets a negative quota.
+func (r userRequest) negativeQuota() bool {
+	return (r.RequestQuota != nil && *r.RequestQuota < 0) || r.QuotaDays < 0
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) *Error {
+	var request loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	invalid := &Error{
+		Message:    "Invalid name or password",
+		StatusCode: http.StatusUnauthorized,
+	}
+	user, err := s.db.GetUserByName(request.Name)
+	if err == sql.ErrNoRows {
+		return invalid
+	}
+	if err != nil {
+		return &Error{
+			Message:    "Could not get user",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if !auth.CheckPassword(user, request.Password) {
+		return invalid
+	}
+	token, session, err := auth.CreateSession(s.db, user)
+	if err != nil {
+		return &Error{
+			Message:    "Could not create session",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(loginResponse{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+	}); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) *Error {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return &Error{
+			Message:    "Only sessions can be logged out",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err := s.db.DeleteSession(auth.HashSessionToken(strings.TrimPrefix(header, bearerPrefix))); err != nil {
+		return &Error{
+			Message:    "Could not delete session",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) *Error {
+	users, err := s.db.ListUsers()
+	if err != nil {
+		return &Error{
+			Message:    "Could not list users",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(users); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) addUser(w http.ResponseWriter, r *http.Request) *Error {
+	var request userRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Name == "" || request.Password == "" || !request.Role.Valid() {
+		return &Error{
+			Message:    "Name, password and a valid role have to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if request.negativeQuota() {
+		return errNegativeQuota
+	}
+	if request.QuotaDays == 0 {
+		request.QuotaDays = defaultQuotaDays
+	}
+	quota := 0
+	if request.RequestQuota != nil {
+		quota = *request.RequestQuota
+	}
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		return &Error{
+			Message:    "Could not hash password",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if existing, err := s.db.GetUserByName(request.Name); err == nil {
+		return &Error{
+			Message:    "User already exists",
+			Link:       "/user/" + existing.ID,
+			StatusCode: http.StatusConflict,
+		}
+	}
+	user, err := s.db.CreateUser(&model.User{
+		ID:           uuid.NewV4().String(),
+		Name:         request.Name,
+		PasswordHash: hash,
+		Role:         request.Role,
+		RequestQuota: quota,
+		QuotaDays:    request.QuotaDays,
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return &Error{
+			Message:    "Could not add user",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+// updateUser updates the fields of a user which are given. Changing the
+// password or the role revokes the sessions of the user, so they have to log
+// in again.
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) *Error {
+	user, err1 := s.userFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	var request userRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Role != "" && !request.Role.Valid() {
+		return &Error{
+			Message:    "Invalid role",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if request.negativeQuota() {
+		return errNegativeQuota
+	}
+	revoke := request.Password != "" || (request.Role != "" && request.Role != user.Role)
+	if request.Name != "" {
+		user.Name = request.Name
+	}
+	if request.Role != "" {
+		user.Role = request.Role
+	}
+	if request.Password != "" {
+		hash, err := auth.HashPassword(request.Password)
+		if err != nil {
+			return &Error{
+				Message:    "Could not hash password",
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+		user.PasswordHash = hash
+	}
+	if request.RequestQuota != nil {
+		user.RequestQuota = *request.RequestQuota
+	}
+	if request.QuotaDays != 0 {
+		user.QuotaDays = request.QuotaDays
+	}
+	user, err := s.db.UpdateUser(user)
+	if err != nil {
+		return &Error{
+			Message:    "Could not update user",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if revoke {
+		if err := s.db.DeleteUserSessions(user.ID); err != nil {
+			return &Error{
+				Message:    "Could not revoke sessions",
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) *Error {
+	user, err1 := s.userFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	if err := s.db.DeleteUser(user.ID); err != nil {
+		return &Error{
+			Message:    "Could not delete user",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) userFromPath(r *http.Request) (*model.User, *Error) {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return nil, &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	user, err := s.db.GetUser(id)
+	if err != nil {
+		return nil, &Error{
+			Message:    "Could not find user",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return user, nil
+}
+
+// checkRequestQuota rejects requests of users who already requested their
+// quota of items within the last quota days.
+func (s *Server) checkRequestQuota(user *model.User) *Error {
+	if user == nil || user.RequestQuota == 0 {
+		return nil
+	}
+	since := time.Now().UTC().AddDate(0, 0, -user.QuotaDays)
+	count, err := s.db.CountItemsRequestedSince(user.ID, since)
+	if err != nil {
+		return &Error{
+			Message:    "Could not verify request quota",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if count >= user.RequestQuota {
+		return &Error{
+			Message:    fmt.Sprintf("Request quota of %d items in %d days exceeded", user.RequestQuota, user.QuotaDays),
+			StatusCode: http.StatusTooManyRequests,
+		}
+	}
+	return nil
+}
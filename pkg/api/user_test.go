@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/auth"
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// userDB keeps a single user in memory and records revoked sessions, other
+// methods of the database are not used by updateUser and the validation of
+// addUser.
+type userDB struct {
+	database.Database
+	user    model.User
+	revoked bool
+}
+
+func (d *userDB) ListAPIKeys() ([]*model.APIKey, error) {
+	return []*model.APIKey{{ID: "1", Name: "test", Hash: auth.HashAPIKey(contractAPIKey)}}, nil
+}
+
+func (d *userDB) GetUser(id string) (*model.User, error) {
+	user := d.user
+	return &user, nil
+}
+
+func (d *userDB) UpdateUser(user *model.User) (*model.User, error) {
+	d.user = *user
+	return user, nil
+}
+
+func (d *userDB) DeleteUserSessions(userID string) error {
+	d.revoked = true
+	return nil
+}
+
+func TestUpdateUser(t *testing.T) {
+	for name, c := range map[string]struct {
+		body    string
+		quota   int
+		role    model.Role
+		revoked bool
+	}{
+		"omitted quota is kept":    {body: `{"name":"alice"}`, quota: 5, role: model.RoleMember},
+		"quota is set to 0":        {body: `{"requestQuota":0}`, quota: 0, role: model.RoleMember},
+		"same role keeps sessions": {body: `{"role":"member"}`, quota: 5, role: model.RoleMember},
+		"role change revokes":      {body: `{"role":"viewer"}`, quota: 5, role: model.RoleViewer, revoked: true},
+		"password change revokes":  {body: `{"password":"secret"}`, quota: 5, role: model.RoleMember, revoked: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := &userDB{user: model.User{
+				ID:           contractUserID,
+				Name:         "alice",
+				Role:         model.RoleMember,
+				RequestQuota: 5,
+				QuotaDays:    7,
+			}}
+			server := NewServer(db, nil, quietLogger())
+			r := httptest.NewRequest(http.MethodPut, "/user/"+contractUserID, strings.NewReader(c.body))
+			r.Header.Set(apiKeyHeader, contractAPIKey)
+			w := httptest.NewRecorder()
+			server.Router.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if db.user.RequestQuota != c.quota || db.user.QuotaDays != 7 {
+				t.Errorf("quota %d in %d days, expected %d in 7", db.user.RequestQuota, db.user.QuotaDays, c.quota)
+			}
+			if db.user.Role != c.role {
+				t.Errorf("role %s, expected %s", db.user.Role, c.role)
+			}
+			if db.revoked != c.revoked {
+				t.Errorf("sessions revoked %t, expected %t", db.revoked, c.revoked)
+			}
+		})
+	}
+}
+
+func TestNegativeQuota(t *testing.T) {
+	for name, c := range map[string]struct {
+		method, path, body string
+	}{
+		"add with negative quota":    {http.MethodPost, "/user", `{"name":"bob","password":"secret","role":"member","requestQuota":-1}`},
+		"add with negative days":     {http.MethodPost, "/user", `{"name":"bob","password":"secret","role":"member","quotaDays":-7}`},
+		"update with negative quota": {http.MethodPut, "/user/" + contractUserID, `{"requestQuota":-1}`},
+		"update with negative days":  {http.MethodPut, "/user/" + contractUserID, `{"quotaDays":-7}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			user := model.User{ID: contractUserID, Name: "alice", Role: model.RoleMember, RequestQuota: 5, QuotaDays: 7}
+			db := &userDB{user: user}
+			server := NewServer(db, nil, quietLogger())
+			r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			r.Header.Set(apiKeyHeader, contractAPIKey)
+			w := httptest.NewRecorder()
+			server.Router.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status %d, expected %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			if db.user != user {
+				t.Errorf("user changed to %+v", db.user)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/satori/go.uuid"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+type webhookRequest struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Secret  string            `json:"secret"`
+	Events  []model.EventType `json:"events"`
+	Enabled *bool             `json:"enabled"`
+}
+
+// validate checks the fields of the request which are set.
+func (request webhookRequest) validate() *Error {
+	if request.URL != "" {
+		u, err := url.Parse(request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &Error{
+				Message:    "URL has to be an absolute HTTP or HTTPS URL",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	for _, t := range request.Events {
+		valid := false
+		for _, webhookEvent := range model.WebhookEvents {
+			if t == webhookEvent {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return &Error{
+				Message:    "Invalid event " + string(t),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	return nil
+}
+
+func eventStrings(events []model.EventType) []string {
+	res := make([]string, 0, len(events))
+	for _, e := range events {
+		res = append(res, string(e))
+	}
+	return res
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) *Error {
+	webhooks, err := s.db.ListWebhooks()
+	if err != nil {
+		return &Error{
+			Message:    "Could not list webhooks",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(webhooks); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) addWebhook(w http.ResponseWriter, r *http.Request) *Error {
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if request.Name == "" || request.URL == "" {
+		return &Error{
+			Message:    "Name and URL have to be specified",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err := request.validate(); err != nil {
+		return err
+	}
+	enabled := true
+	if request.Enabled != nil {
+		enabled = *request.Enabled
+	}
+	webhook, err := s.db.CreateWebhook(&model.Webhook{
+		ID:        uuid.NewV4().String(),
+		Name:      request.Name,
+		URL:       request.URL,
+		Secret:    request.Secret,
+		Events:    eventStrings(request.Events),
+		Enabled:   enabled,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return &Error{
+			Message:    "Could not add webhook",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(webhook); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) updateWebhook(w http.ResponseWriter, r *http.Request) *Error {
+	webhook, err1 := s.webhookFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ErrInvalidRequestBody
+	}
+	if err := request.validate(); err != nil {
+		return err
+	}
+	if request.Name != "" {
+		webhook.Name = request.Name
+	}
+	if request.URL != "" {
+		webhook.URL = request.URL
+	}
+	if request.Secret != "" {
+		webhook.Secret = request.Secret
+	}
+	if request.Events != nil {
+		webhook.Events = eventStrings(request.Events)
+	}
+	if request.Enabled != nil {
+		webhook.Enabled = *request.Enabled
+	}
+	webhook, err := s.db.UpdateWebhook(webhook)
+	if err != nil {
+		return &Error{
+			Message:    "Could not update webhook",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(webhook); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) *Error {
+	webhook, err1 := s.webhookFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	if err := s.db.DeleteWebhook(webhook.ID); err != nil {
+		return &Error{
+			Message:    "Could not delete webhook",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) *Error {
+	webhook, err1 := s.webhookFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	paging, err1 := pagingFromQuery(r)
+	if err1 != nil {
+		return err1
+	}
+	deliveries, err := s.db.ListWebhookDeliveries(webhook.ID, paging.Offset, paging.Count)
+	if err != nil {
+		return &Error{
+			Message:    "Could not list webhook deliveries",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+// testWebhook sends a test event to the webhook and returns the delivery.
+func (s *Server) testWebhook(w http.ResponseWriter, r *http.Request) *Error {
+	if s.Webhooks == nil {
+		return notConfigured("Webhooks")
+	}
+	webhook, err1 := s.webhookFromPath(r)
+	if err1 != nil {
+		return err1
+	}
+	delivery := s.Webhooks.Test(webhook)
+	if delivery == nil {
+		return &Error{
+			Message:    "Could not deliver test event",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(delivery); err != nil {
+		return ErrEncodeResponse
+	}
+	return nil
+}
+
+func (s *Server) webhookFromPath(r *http.Request) (*model.Webhook, *Error) {
+	id, ok := mux.Vars(r)[idPathParameter]
+	if !ok {
+		return nil, &Error{
+			Message:    "Could not find ID in path",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	webhook, err := s.db.GetWebhook(id)
+	if err != nil {
+		return nil, &Error{
+			Message:    "Could not find webhook",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return webhook, nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const apiKeyLength = 32
+
+// GenerateAPIKey returns a new random API key and its hash. Only the hash is
+// stored, the key itself is shown once.
+func GenerateAPIKey() (key, hash string, err error) {
+	buf := make([]byte, apiKeyLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = hex.EncodeToString(buf)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes an API key for storage. API keys are long random values,
+// so a single round of SHA-256 is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchAPIKey reports whether the key matches any of the stored hashes. All
+// hashes are compared in constant time.
+func MatchAPIKey(key string, keys []*model.APIKey) bool {
+	hash := []byte(HashAPIKey(key))
+	match := 0
+	for _, k := range keys {
+		match |= subtle.ConstantTimeCompare(hash, []byte(k.Hash))
+	}
+	return match == 1
+}
+
+// CreateAPIKey generates and stores a new API key with the given name.
+func CreateAPIKey(db database.Database, name string) (string, error) {
+	key, hash, err := GenerateAPIKey()
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.CreateAPIKey(&model.APIKey{
+		ID:        uuid.NewV4().String(),
+		Name:      name,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// RotateAPIKey replaces all API keys with the given name with a new one.
+func RotateAPIKey(db database.Database, name string) (string, error) {
+	keys, err := db.ListAPIKeys()
+	if err != nil {
+		return "", err
+	}
+	key, err := CreateAPIKey(db, name)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range keys {
+		if k.Name != name {
+			continue
+		}
+		if err := db.DeleteAPIKey(k.ID); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
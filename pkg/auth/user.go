@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	sessionTokenLength = 32
+
+	SessionDuration = 30 * 24 * time.Hour
+)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(user *model.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// HashSessionToken hashes a session token for storage and lookup. Like API
+// keys, session tokens are long random values.
+func HashSessionToken(token string) string {
+	return HashAPIKey(token)
+}
+
+// CreateSession stores a new session for the user and returns its token.
+func CreateSession(db database.Database, user *model.User) (string, *model.Session, error) {
+	buf := make([]byte, sessionTokenLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+	session := &model.Session{
+		TokenHash: HashSessionToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(SessionDuration),
+	}
+	if err := db.CreateSession(session); err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"sync"
+)
+
+// Group coalesces concurrent calls with the same key, only the first call is
+// made and the others wait for its result.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// Do calls f unless a call with the same key is in progress, in which case it
+// waits for that call and returns its result. Shared reports whether the
+// result was shared with other callers.
+func (g *Group) Do(key string, f func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = f()
+	return c.value, c.err, false
+}
@@ -0,0 +1,224 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/KnutZuidema/go-btn"
+	"github.com/KnutZuidema/go-qbittorrent"
+	"github.com/KnutZuidema/go-tmdb"
+
+	"github.com/KnutZuidema/godarr/pkg/downloader"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/feed"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/monitorer"
+	"github.com/KnutZuidema/godarr/pkg/notification"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+// instance is a component instantiated from its definition.
+type instance struct {
+	component  *model.Component
+	monitorer  monitorer.Monitorer
+	feed       monitorer.Feed
+	downloader downloader.Downloader
+	providers  map[model.ItemKind]provider.Provider
+	notifier   notification.Notifier
+	// guard limits the requests of indexers and providers
+	guard *ratelimit.Guard
+	err   error
+}
+
+func (m *Manager) instantiate(component *model.Component) *instance {
+	inst := &instance{
+		component: component,
+	}
+	s, err := decodeSettings(component)
+	if err != nil {
+		inst.err = err
+		return inst
+	}
+	logger := m.logger.WithField("name", component.Name)
+	switch s := s.(type) {
+	case *BroadcasTheNetSettings:
+		inst.guard = ratelimit.NewGuard(s.RateLimit.options(broadcasTheNetRateLimit, nil), logger)
+		btnMonitorer := monitorer.NewBroadcasTheNetMonitorer(s.APIKey, m.db, inst.guard, logger, m.releases)
+		inst.monitorer = btnMonitorer
+		inst.feed = btnMonitorer
+	case *RSSSettings:
+		inst.guard = ratelimit.NewGuard(s.RateLimit.options(rssRateLimit, nil), logger)
+		rssMonitorer := monitorer.NewRSSFeedMonitorer(component.Name, s.URL, nil)
+		inst.monitorer = rssMonitorer
+		inst.feed = guardedFeed{rssMonitorer, inst.guard}
+	case *QBitTorrentSettings:
+		inst.downloader = downloader.NewQBitTorrentDownloader(s.Username, s.Password, s.Address, s.options(m.bus), logger, m.downloads, time.Duration(s.Interval))
+	case *TMDBSettings:
+		inst.guard = ratelimit.NewGuard(s.RateLimit.options(tmdbRateLimit, permanentProviderError), logger)
+		inst.providers = map[model.ItemKind]provider.Provider{
+			model.ItemKindMovie:    guardedProvider{provider.NewTMDBProvider(s.APIKey, logger, model.ItemKindMovie), inst.guard},
+			model.ItemKindTVSeries: guardedProvider{provider.NewTMDBProvider(s.APIKey, logger, model.ItemKindTVSeries), inst.guard},
+		}
+	case *TVDBSettings:
+		inst.guard = ratelimit.NewGuard(s.RateLimit.options(tvdbRateLimit, permanentProviderError), logger)
+		inst.providers = map[model.ItemKind]provider.Provider{
+			model.ItemKindTVSeries: guardedProvider{provider.NewTVDBProvider(s.options(), nil, logger), inst.guard},
+		}
+	case *OMDbSettings:
+		inst.guard = ratelimit.NewGuard(s.RateLimit.options(omdbRateLimit, permanentProviderError), logger)
+		inst.providers = map[model.ItemKind]provider.Provider{
+			model.ItemKindMovie:    guardedProvider{provider.NewOMDbProvider(s.options(), model.ItemKindMovie, nil, logger), inst.guard},
+			model.ItemKindTVSeries: guardedProvider{provider.NewOMDbProvider(s.options(), model.ItemKindTVSeries, nil, logger), inst.guard},
+		}
+	default:
+		inst.notifier = newNotifier(component.Type, s)
+	}
+	if m.cache != nil {
+		// responses of the same service are shared by its instances
+		for kind, p := range inst.providers {
+			inst.providers[kind] = provider.NewCachedProvider(p, m.cache, component.Type+"/"+string(kind))
+		}
+	}
+	return inst
+}
+
+func (s *QBitTorrentSettings) options(bus *event.Bus) downloader.QBitTorrentOptions {
+	options := downloader.QBitTorrentOptions{
+		Paused:             s.Paused,
+		SequentialDownload: s.SequentialDownload,
+		FirstLastPiecePrio: s.FirstLastPiecePrio,
+		Events:             bus,
+	}
+	if len(s.Categories) > 0 {
+		options.Categories = map[model.ItemKind]downloader.QBitTorrentCategory{}
+		for kind, category := range s.Categories {
+			options.Categories[model.ItemKind(kind)] = downloader.QBitTorrentCategory{
+				Name:     category.Name,
+				SavePath: category.SavePath,
+			}
+		}
+	}
+	for _, mapping := range s.PathMappings {
+		options.PathMappings = append(options.PathMappings, downloader.PathMapping{
+			Remote: mapping.Remote,
+			Local:  mapping.Local,
+		})
+	}
+	return options
+}
+
+func (s *TVDBSettings) options() provider.TVDBOptions {
+	return provider.TVDBOptions{
+		BaseURL: s.URL,
+		APIKey:  s.APIKey,
+		PIN:     s.PIN,
+	}
+}
+
+func (s *OMDbSettings) options() provider.OMDbOptions {
+	return provider.OMDbOptions{
+		BaseURL: s.URL,
+		APIKey:  s.APIKey,
+	}
+}
+
+func newNotifier(componentType string, s settings) notification.Notifier {
+	switch s := s.(type) {
+	case *WebhookSettings:
+		if componentType == TypeSlack {
+			return notification.NewSlackNotifier(s.URL, nil)
+		}
+		return notification.NewDiscordNotifier(s.URL, nil)
+	case *TelegramSettings:
+		return notification.NewTelegramNotifier(notification.TelegramOptions{
+			BaseURL: s.URL,
+			Token:   s.Token,
+			ChatID:  s.ChatID,
+		}, nil)
+	case *GotifySettings:
+		return notification.NewGotifyNotifier(notification.GotifyOptions{
+			BaseURL:  s.URL,
+			Token:    s.Token,
+			Priority: s.Priority,
+		}, nil)
+	case *NtfySettings:
+		return notification.NewNtfyNotifier(notification.NtfyOptions{
+			BaseURL: s.URL,
+			Topic:   s.Topic,
+			Token:   s.Token,
+		}, nil)
+	case *SMTPSettings:
+		return notification.NewSMTPNotifier(notification.SMTPOptions{
+			Address:  s.Address,
+			Username: s.Username,
+			Password: s.Password,
+			From:     s.From,
+			To:       s.To,
+		})
+	}
+	return nil
+}
+
+// Test connects to the service of a component with its settings and returns
+// a description of the result. Notifications send a test message.
+func (m *Manager) Test(component *model.Component) (string, error) {
+	s, err := decodeSettings(component)
+	if err != nil {
+		return "", err
+	}
+	switch s := s.(type) {
+	case *BroadcasTheNetSettings:
+		user, err := btn.NewClient(http.DefaultClient, s.APIKey).GetUser()
+		if err != nil {
+			return "", err
+		}
+		return "Logged in as " + user.Username, nil
+	case *RSSSettings:
+		items, err := feed.Fetch(context.Background(), nil, s.URL)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Feed contains %d releases", len(items)), nil
+	case *QBitTorrentSettings:
+		client := qbittorrent.NewClient(s.Address, m.logger)
+		if err := client.Login(s.Username, s.Password); err != nil {
+			return "", err
+		}
+		version, err := client.Application.GetAppVersion()
+		if err != nil {
+			return "", err
+		}
+		return "Connected to qBittorrent " + version, nil
+	case *TMDBSettings:
+		if _, err := tmdb.Init(tmdb.Config{APIKey: s.APIKey}).GetConfiguration(); err != nil {
+			return "", err
+		}
+		return "Connected to TMDb", nil
+	case *TVDBSettings:
+		if err := provider.NewTVDBProvider(s.options(), nil, m.logger).Login(); err != nil {
+			return "", err
+		}
+		return "Logged in to TheTVDB", nil
+	case *OMDbSettings:
+		// The Shawshank Redemption
+		item, err := provider.NewOMDbProvider(s.options(), model.ItemKindMovie, nil, m.logger).GetByID("tt0111161")
+		if err != nil {
+			return "", err
+		}
+		return "Connected to OMDb, found " + item.Title, nil
+	}
+	notifier := newNotifier(component.Type, s)
+	if notifier == nil {
+		return "", fmt.Errorf("unknown type %q", component.Type)
+	}
+	if err := notifier.Notify(notification.Message{
+		Title: "Test notification",
+		Body:  "The " + component.Type + " notification " + component.Name + " of godarr works.",
+	}); err != nil {
+		return "", err
+	}
+	return "Sent test notification", nil
+}
@@ -0,0 +1,140 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/monitorer"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+// guardedFeed passes the feed requests of an indexer through its guard.
+type guardedFeed struct {
+	feed  monitorer.Feed
+	guard *ratelimit.Guard
+}
+
+func (g guardedFeed) Recent(ctx context.Context) ([]model.IndexerRelease, error) {
+	var releases []model.IndexerRelease
+	err := g.guard.Do(ctx, func() (err error) {
+		releases, err = g.feed.Recent(ctx)
+		return err
+	})
+	return releases, err
+}
+
+// permanentProviderError reports errors for unknown or invalid IDs.
+func permanentProviderError(err error) bool {
+	var (
+		notFound provider.NotFoundError
+		invalid  *strconv.NumError
+	)
+	return errors.As(err, &notFound) || errors.As(err, &invalid)
+}
+
+// guardedProvider passes the requests of a provider through its guard.
+type guardedProvider struct {
+	provider provider.Provider
+	guard    *ratelimit.Guard
+}
+
+func (g guardedProvider) ListBySearch(search string) ([]*model.Item, error) {
+	var items []*model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		items, err = g.provider.ListBySearch(search)
+		return err
+	})
+	return items, err
+}
+
+func (g guardedProvider) GetByID(id string) (*model.Item, error) {
+	var item *model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		item, err = g.provider.GetByID(id)
+		return err
+	})
+	return item, err
+}
+
+func (g guardedProvider) Refresh(item *model.Item) (*model.Item, error) {
+	refresher, ok := g.provider.(provider.Refresher)
+	if !ok {
+		return nil, fmt.Errorf("provider can not refresh items")
+	}
+	var refreshed *model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		refreshed, err = refresher.Refresh(item)
+		return err
+	})
+	return refreshed, err
+}
+
+func (g guardedProvider) Changes(since time.Time) ([]string, error) {
+	lister, ok := g.provider.(provider.ChangeLister)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list changes")
+	}
+	var ids []string
+	err := g.guard.Do(context.Background(), func() (err error) {
+		ids, err = lister.Changes(since)
+		return err
+	})
+	return ids, err
+}
+
+func (g guardedProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	finder, ok := g.provider.(provider.Finder)
+	if !ok {
+		return nil, fmt.Errorf("provider can not find items by %s ID", source)
+	}
+	var item *model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		item, err = finder.Find(source, id)
+		return err
+	})
+	return item, err
+}
+
+func (g guardedProvider) Episodes(item *model.Item) ([]model.Episode, error) {
+	lister, ok := g.provider.(provider.EpisodeLister)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list episodes")
+	}
+	var episodes []model.Episode
+	err := g.guard.Do(context.Background(), func() (err error) {
+		episodes, err = lister.Episodes(item)
+		return err
+	})
+	return episodes, err
+}
+
+func (g guardedProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	discoverer, ok := g.provider.(provider.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("provider can not discover items")
+	}
+	var items []*model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		items, err = discoverer.Discover(list, page)
+		return err
+	})
+	return items, err
+}
+
+func (g guardedProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	discoverer, ok := g.provider.(provider.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list related items")
+	}
+	var items []*model.Item
+	err := g.guard.Do(context.Background(), func() (err error) {
+		items, err = discoverer.Related(item, relation, page)
+		return err
+	})
+	return items, err
+}
@@ -0,0 +1,387 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/downloader"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/monitorer"
+	"github.com/KnutZuidema/godarr/pkg/notification"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+var (
+	ErrNoDownloadClient = errors.New("no download client is configured")
+	ErrNoProvider       = errors.New("no provider is configured")
+)
+
+// Manager instantiates the components defined in the database and the
+// configuration file and keeps them up to date when definitions change.
+// The monitorer, downloader and providers it returns always delegate to the
+// current instances.
+type Manager struct {
+	db            database.Database
+	bus           *event.Bus
+	releases      chan<- model.Release
+	downloads     chan<- model.CompletedDownload
+	notifications *notification.Dispatcher
+	logger        log.FieldLogger
+
+	mu        sync.RWMutex
+	static    []*model.Component
+	instances map[string]*instance
+	// names of the providers in the order they are asked for metadata
+	providerOrder []string
+	// cache of the provider responses, nil if disabled
+	cache *provider.Cache
+}
+
+func NewManager(
+	db database.Database,
+	bus *event.Bus,
+	releases chan<- model.Release,
+	downloads chan<- model.CompletedDownload,
+	notifications *notification.Dispatcher,
+	logger log.FieldLogger,
+) *Manager {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Manager{
+		db:            db,
+		bus:           bus,
+		releases:      releases,
+		downloads:     downloads,
+		notifications: notifications,
+		logger:        logger.WithField("component", "ComponentManager"),
+		instances:     map[string]*instance{},
+	}
+}
+
+// SetStatic replaces the read only components of the configuration file and
+// reloads all components.
+func (m *Manager) SetStatic(components []*model.Component) error {
+	for _, component := range components {
+		component.ReadOnly = true
+	}
+	m.mu.Lock()
+	m.static = components
+	m.mu.Unlock()
+	return m.Reload()
+}
+
+// SetProviderCache caches the responses of all providers, it has to be called
+// before the components are instantiated.
+func (m *Manager) SetProviderCache(cache *provider.Cache) {
+	m.cache = cache
+}
+
+// SetProviderOrder sets the names of the providers in the order they are
+// asked for metadata, the following ones fill the fields which are missing at
+// the previous ones.
+func (m *Manager) SetProviderOrder(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.providerOrder = names
+}
+
+// List returns the components of the configuration file followed by the ones
+// of the database.
+func (m *Manager) List() ([]*model.Component, error) {
+	stored, err := m.db.ListComponents()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append(append([]*model.Component{}, m.static...), stored...), nil
+}
+
+// Get returns the component with the given ID from the configuration file or
+// the database.
+func (m *Manager) Get(id string) (*model.Component, error) {
+	m.mu.RLock()
+	for _, component := range m.static {
+		if component.ID == id {
+			m.mu.RUnlock()
+			return component, nil
+		}
+	}
+	m.mu.RUnlock()
+	return m.db.GetComponent(id)
+}
+
+// Error returns the error which occurred while instantiating the component
+// with the given ID.
+func (m *Manager) Error(id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if inst, ok := m.instances[id]; ok {
+		return inst.err
+	}
+	return nil
+}
+
+// Health returns the health of the indexer or provider with the given ID, or
+// nil if the component is not instantiated or has no health.
+func (m *Manager) Health(id string) *model.Health {
+	m.mu.RLock()
+	inst, ok := m.instances[id]
+	m.mu.RUnlock()
+	if !ok || inst.guard == nil {
+		return nil
+	}
+	health := inst.guard.Health()
+	return &health
+}
+
+// Reload instantiates all enabled components. Components whose definition did
+// not change keep their instance.
+func (m *Manager) Reload() error {
+	components, err := m.List()
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	previous := m.instances
+	m.mu.RUnlock()
+	instances := map[string]*instance{}
+	for _, component := range components {
+		if !component.Enabled {
+			continue
+		}
+		if inst, ok := previous[component.ID]; ok && inst.err == nil && sameDefinition(inst.component, component) {
+			instances[component.ID] = inst
+			continue
+		}
+		inst := m.instantiate(component)
+		if inst.err != nil {
+			m.logger.WithFields(log.Fields{
+				"category": component.Category,
+				"name":     component.Name,
+			}).Error("instantiate: ", inst.err)
+		}
+		instances[component.ID] = inst
+	}
+	m.mu.Lock()
+	m.instances = instances
+	m.mu.Unlock()
+	if m.notifications != nil {
+		m.notifications.SetNotifiers(m.notifiers())
+	}
+	return nil
+}
+
+func sameDefinition(a, b *model.Component) bool {
+	return a.Type == b.Type && a.Name == b.Name && reflect.DeepEqual([]byte(a.Settings), []byte(b.Settings))
+}
+
+// working returns the working instances of a category ordered by name.
+// Instances which are disabled after failing repeatedly are left out.
+func (m *Manager) working(category model.ComponentCategory) []*instance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var res []*instance
+	for _, inst := range m.instances {
+		if inst.err == nil && inst.component.Category == category && (inst.guard == nil || inst.guard.Available()) {
+			res = append(res, inst)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].component.Name < res[j].component.Name
+	})
+	return res
+}
+
+func (m *Manager) notifiers() map[string]notification.Notifier {
+	notifiers := map[string]notification.Notifier{}
+	for _, inst := range m.working(model.ComponentCategoryNotification) {
+		notifiers[inst.component.Name] = inst.notifier
+	}
+	return notifiers
+}
+
+// Monitorer returns a monitorer which monitors items with all indexers which
+// are enabled when monitoring starts.
+func (m *Manager) Monitorer() monitorer.Monitorer {
+	return managedMonitorer{m}
+}
+
+type managedMonitorer struct {
+	m *Manager
+}
+
+func (mm managedMonitorer) Monitor(ctx context.Context, item *model.Item) error {
+	var monitorers monitorer.MultiMonitorer
+	for _, inst := range mm.m.working(model.ComponentCategoryIndexer) {
+		monitorers = append(monitorers, inst.monitorer)
+	}
+	if len(monitorers) == 0 {
+		mm.m.logger.WithField("item", item.ID).Warn("no indexer is available, item is not monitored")
+		return nil
+	}
+	return monitorers.Monitor(ctx, item)
+}
+
+// Feeds returns the working indexers which list their recent releases.
+func (m *Manager) Feeds() []monitorer.Feed {
+	var feeds []monitorer.Feed
+	for _, inst := range m.working(model.ComponentCategoryIndexer) {
+		if inst.feed != nil {
+			feeds = append(feeds, inst.feed)
+		}
+	}
+	return feeds
+}
+
+// Downloader returns a downloader which downloads with the first enabled
+// download client and falls back to the next one on errors.
+func (m *Manager) Downloader() downloader.Downloader {
+	return managedDownloader{m}
+}
+
+type managedDownloader struct {
+	m *Manager
+}
+
+func (md managedDownloader) Download(ctx context.Context, release model.Release) error {
+	err := ErrNoDownloadClient
+	for _, inst := range md.m.working(model.ComponentCategoryDownloadClient) {
+		err = inst.downloader.Download(ctx, release)
+		// a waiting download belongs to this client, it is not started
+		// with the next one
+		if err == nil || err == downloader.ErrWaiting || ctx.Err() != nil {
+			return err
+		}
+		md.m.logger.WithField("name", inst.component.Name).Warn("download: ", err)
+	}
+	return err
+}
+
+// Providers returns providers per kind, which delegate to the first enabled
+// provider component.
+func (m *Manager) Providers() map[model.ItemKind]provider.Provider {
+	return map[model.ItemKind]provider.Provider{
+		model.ItemKindMovie:    managedProvider{m, model.ItemKindMovie},
+		model.ItemKindTVSeries: managedProvider{m, model.ItemKindTVSeries},
+	}
+}
+
+type managedProvider struct {
+	m    *Manager
+	kind model.ItemKind
+}
+
+// current returns a provider which falls back to the providers of the
+// working instances for the kind in the configured order. Providers which are
+// not part of the order follow by name, TheTVDB first for TV series since the
+// indexers number episodes like it.
+func (mp managedProvider) current() (*provider.FallbackProvider, error) {
+	mp.m.mu.RLock()
+	order := mp.m.providerOrder
+	mp.m.mu.RUnlock()
+	rank := func(inst *instance) int {
+		for i, name := range order {
+			if inst.component.Name == name {
+				return i
+			}
+		}
+		if mp.kind == model.ItemKindTVSeries && inst.component.Type == TypeTVDB {
+			return len(order)
+		}
+		return len(order) + 1
+	}
+	var instances []*instance
+	for _, inst := range mp.m.working(model.ComponentCategoryProvider) {
+		if _, ok := inst.providers[mp.kind]; ok {
+			instances = append(instances, inst)
+		}
+	}
+	if len(instances) == 0 {
+		return nil, ErrNoProvider
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return rank(instances[i]) < rank(instances[j])
+	})
+	sources := make([]provider.Source, 0, len(instances))
+	for _, inst := range instances {
+		sources = append(sources, provider.Source{
+			Name:     inst.component.Name,
+			Provider: inst.providers[mp.kind],
+		})
+	}
+	return provider.NewFallbackProvider(sources...), nil
+}
+
+func (mp managedProvider) ListBySearch(search string) ([]*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.ListBySearch(search)
+}
+
+func (mp managedProvider) GetByID(id string) (*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetByID(id)
+}
+
+func (mp managedProvider) Refresh(item *model.Item) (*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Refresh(item)
+}
+
+func (mp managedProvider) Changes(since time.Time) ([]string, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Changes(since)
+}
+
+func (mp managedProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(source, id)
+}
+
+func (mp managedProvider) Episodes(item *model.Item) ([]model.Episode, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Episodes(item)
+}
+
+func (mp managedProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Discover(list, page)
+}
+
+func (mp managedProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	p, err := mp.current()
+	if err != nil {
+		return nil, err
+	}
+	return p.Related(item, relation, page)
+}
@@ -0,0 +1,92 @@
+package component
+
+import (
+	"encoding/json"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Redacted replaces the secret settings of components in API responses.
+// Updates which contain it keep the stored secret.
+const Redacted = "********"
+
+// secrets are the settings of each type which are not returned by the API.
+var secrets = map[string][]string{
+	TypeBroadcasTheNet: {"apiKey"},
+	TypeTMDB:           {"apiKey"},
+	TypeTVDB:           {"apiKey"},
+	TypeOMDb:           {"apiKey"},
+	TypeQBitTorrent:    {"password"},
+	TypeSMTP:           {"password"},
+	TypeTelegram:       {"token"},
+	TypeGotify:         {"token"},
+	TypeNtfy:           {"token"},
+}
+
+// Redact returns a copy of the component whose secret settings are replaced
+// by Redacted.
+func Redact(component *model.Component) *model.Component {
+	redacted := *component
+	keys := secrets[component.Type]
+	if len(keys) == 0 {
+		return &redacted
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(component.Settings, &settings); err != nil || settings == nil {
+		// settings which can not be decoded may contain secrets as well
+		redacted.Settings = nil
+		return &redacted
+	}
+	for _, key := range keys {
+		if secret(settings[key]) != "" {
+			settings[key] = json.RawMessage(`"` + Redacted + `"`)
+		}
+	}
+	data, err := model.NewJSON(settings)
+	if err != nil {
+		redacted.Settings = nil
+		return &redacted
+	}
+	redacted.Settings = data
+	return &redacted
+}
+
+// KeepSecrets returns the settings of an update of a component with the
+// secrets which are Redacted replaced by the stored ones. Settings of another
+// type than the stored one are returned unchanged.
+func KeepSecrets(stored *model.Component, componentType string, settings model.JSON) (model.JSON, error) {
+	keys := secrets[componentType]
+	if componentType != stored.Type || len(keys) == 0 {
+		return settings, nil
+	}
+	var update, current map[string]json.RawMessage
+	if err := json.Unmarshal(settings, &update); err != nil || update == nil {
+		// invalid settings are reported by the validation
+		return settings, nil
+	}
+	if len(stored.Settings) > 0 {
+		if err := json.Unmarshal(stored.Settings, &current); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range keys {
+		if secret(update[key]) != Redacted {
+			continue
+		}
+		if value, ok := current[key]; ok {
+			update[key] = value
+		} else {
+			delete(update, key)
+		}
+	}
+	return model.NewJSON(update)
+}
+
+// secret returns a setting if it is a string.
+func secret(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package component
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+func TestRedactAndKeepSecrets(t *testing.T) {
+	stored := &model.Component{
+		Type:     TypeQBitTorrent,
+		Settings: model.JSON(`{"address":"http://localhost:8080","username":"admin","password":"secret"}`),
+	}
+	redacted := Redact(stored)
+	var settings map[string]string
+	if err := json.Unmarshal(redacted.Settings, &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings["password"] != Redacted || settings["username"] != "admin" {
+		t.Errorf("redacted settings %v", settings)
+	}
+	if string(stored.Settings) == string(redacted.Settings) {
+		t.Error("stored settings were changed")
+	}
+	for name, c := range map[string]struct {
+		componentType string
+		update        string
+		expected      map[string]string
+	}{
+		"placeholder keeps secret": {
+			componentType: TypeQBitTorrent,
+			update:        `{"address":"http://qbittorrent:8080","username":"admin","password":"********"}`,
+			expected:      map[string]string{"address": "http://qbittorrent:8080", "username": "admin", "password": "secret"},
+		},
+		"new secret": {
+			componentType: TypeQBitTorrent,
+			update:        `{"address":"http://localhost:8080","username":"admin","password":"changed"}`,
+			expected:      map[string]string{"address": "http://localhost:8080", "username": "admin", "password": "changed"},
+		},
+		"other type": {
+			componentType: TypeSMTP,
+			update:        `{"password":"********"}`,
+			expected:      map[string]string{"password": Redacted},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kept, err := KeepSecrets(stored, c.componentType, model.JSON(c.update))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var settings map[string]string
+			if err := json.Unmarshal(kept, &settings); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(settings, c.expected) {
+				t.Errorf("settings %v, expected %v", settings, c.expected)
+			}
+		})
+	}
+}
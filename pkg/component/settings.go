@@ -0,0 +1,355 @@
+package component
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+// Types of components by category.
+const (
+	TypeBroadcasTheNet = "broadcasthenet"
+	TypeRSS            = "rss"
+	TypeQBitTorrent    = "qbittorrent"
+	TypeTMDB           = "tmdb"
+	TypeTVDB           = "tvdb"
+	TypeOMDb           = "omdb"
+	TypeDiscord        = "discord"
+	TypeSlack          = "slack"
+	TypeTelegram       = "telegram"
+	TypeGotify         = "gotify"
+	TypeNtfy           = "ntfy"
+	TypeSMTP           = "smtp"
+)
+
+var types = map[model.ComponentCategory]map[string]func() settings{
+	model.ComponentCategoryIndexer: {
+		TypeBroadcasTheNet: func() settings { return &BroadcasTheNetSettings{} },
+		TypeRSS:            func() settings { return &RSSSettings{} },
+	},
+	model.ComponentCategoryDownloadClient: {
+		TypeQBitTorrent: func() settings { return &QBitTorrentSettings{} },
+	},
+	model.ComponentCategoryProvider: {
+		TypeTMDB: func() settings { return &TMDBSettings{} },
+		TypeTVDB: func() settings { return &TVDBSettings{} },
+		TypeOMDb: func() settings { return &OMDbSettings{} },
+	},
+	model.ComponentCategoryNotification: {
+		TypeDiscord:  func() settings { return &WebhookSettings{} },
+		TypeSlack:    func() settings { return &WebhookSettings{} },
+		TypeTelegram: func() settings { return &TelegramSettings{} },
+		TypeGotify:   func() settings { return &GotifySettings{} },
+		TypeNtfy:     func() settings { return &NtfySettings{} },
+		TypeSMTP:     func() settings { return &SMTPSettings{} },
+	},
+}
+
+type settings interface {
+	validate() error
+}
+
+// Duration is a time.Duration encoded as string like "15m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration has to be a string like \"15m\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// RateLimit limits the requests to an indexer or provider, the default of its
+// type is used if Requests is zero.
+type RateLimit struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+}
+
+func (r *RateLimit) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.Requests < 0 {
+		return errors.New("rateLimit.requests must not be negative")
+	}
+	if r.Requests > 0 && r.Per <= 0 {
+		return errors.New("rateLimit.per must be positive")
+	}
+	return nil
+}
+
+// options returns the guard options of the rate limit on top of the default
+// of the type.
+func (r *RateLimit) options(defaults RateLimit, permanent func(error) bool) ratelimit.Options {
+	if r == nil || r.Requests == 0 {
+		r = &defaults
+	}
+	return ratelimit.Options{
+		Requests:  r.Requests,
+		Per:       time.Duration(r.Per),
+		Permanent: permanent,
+	}
+}
+
+// Default rate limits by type. BroadcasTheNet allows 150 API calls per hour.
+var (
+	broadcasTheNetRateLimit = RateLimit{Requests: 150, Per: Duration(time.Hour)}
+	rssRateLimit            = RateLimit{Requests: 1, Per: Duration(time.Minute)}
+	tmdbRateLimit           = RateLimit{Requests: 40, Per: Duration(10 * time.Second)}
+	tvdbRateLimit           = RateLimit{Requests: 20, Per: Duration(time.Second)}
+	// free OMDb keys allow 1000 requests per day
+	omdbRateLimit = RateLimit{Requests: 1000, Per: Duration(24 * time.Hour)}
+)
+
+type BroadcasTheNetSettings struct {
+	APIKey    string     `json:"apiKey"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+func (s *BroadcasTheNetSettings) validate() error {
+	if s.APIKey == "" {
+		return errors.New("apiKey must not be empty")
+	}
+	return s.RateLimit.validate()
+}
+
+// RSSSettings configure an RSS or Torznab feed, the URL has to contain the API
+// key if the feed requires one.
+type RSSSettings struct {
+	URL       string     `json:"url"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+func (s *RSSSettings) validate() error {
+	if err := validateURL("url", s.URL, true); err != nil {
+		return err
+	}
+	return s.RateLimit.validate()
+}
+
+type QBitTorrentCategory struct {
+	Name     string `json:"name"`
+	SavePath string `json:"savePath,omitempty"`
+}
+
+type PathMapping struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+}
+
+type QBitTorrentSettings struct {
+	Address  string `json:"address"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Interval between progress checks, defaults to 1 minute
+	Interval           Duration                       `json:"interval,omitempty"`
+	Paused             bool                           `json:"paused,omitempty"`
+	SequentialDownload bool                           `json:"sequentialDownload,omitempty"`
+	FirstLastPiecePrio bool                           `json:"firstLastPiecePrio,omitempty"`
+	Categories         map[string]QBitTorrentCategory `json:"categories,omitempty"`
+	PathMappings       []PathMapping                  `json:"pathMappings,omitempty"`
+}
+
+func (s *QBitTorrentSettings) validate() error {
+	if err := validateURL("address", s.Address, true); err != nil {
+		return err
+	}
+	for kind, category := range s.Categories {
+		if kind != string(model.ItemKindMovie) && kind != model.ItemKindTVSeries {
+			return fmt.Errorf("categories: unknown kind %q", kind)
+		}
+		if category.Name == "" {
+			return fmt.Errorf("categories.%s.name must not be empty", kind)
+		}
+	}
+	for i, mapping := range s.PathMappings {
+		if mapping.Remote == "" || mapping.Local == "" {
+			return fmt.Errorf("pathMappings[%d]: remote and local must not be empty", i)
+		}
+	}
+	if s.Username == "" {
+		s.Username = "admin"
+	}
+	if s.Interval == 0 {
+		s.Interval = Duration(time.Minute)
+	}
+	return nil
+}
+
+type TMDBSettings struct {
+	APIKey    string     `json:"apiKey"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+func (s *TMDBSettings) validate() error {
+	if s.APIKey == "" {
+		return errors.New("apiKey must not be empty")
+	}
+	return s.RateLimit.validate()
+}
+
+// TVDBSettings configure TheTVDB, the PIN is only required for keys of
+// subscribers.
+type TVDBSettings struct {
+	APIKey    string     `json:"apiKey"`
+	PIN       string     `json:"pin,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+func (s *TVDBSettings) validate() error {
+	if s.APIKey == "" {
+		return errors.New("apiKey must not be empty")
+	}
+	if err := validateURL("url", s.URL, false); err != nil {
+		return err
+	}
+	return s.RateLimit.validate()
+}
+
+type OMDbSettings struct {
+	APIKey    string     `json:"apiKey"`
+	URL       string     `json:"url,omitempty"`
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+func (s *OMDbSettings) validate() error {
+	if s.APIKey == "" {
+		return errors.New("apiKey must not be empty")
+	}
+	if err := validateURL("url", s.URL, false); err != nil {
+		return err
+	}
+	return s.RateLimit.validate()
+}
+
+// WebhookSettings are the settings of Discord and Slack notifications.
+type WebhookSettings struct {
+	URL string `json:"url"`
+}
+
+func (s *WebhookSettings) validate() error {
+	return validateURL("url", s.URL, true)
+}
+
+type TelegramSettings struct {
+	URL    string `json:"url,omitempty"`
+	Token  string `json:"token"`
+	ChatID string `json:"chatId"`
+}
+
+func (s *TelegramSettings) validate() error {
+	if s.Token == "" || s.ChatID == "" {
+		return errors.New("token and chatId must not be empty")
+	}
+	return validateURL("url", s.URL, false)
+}
+
+type GotifySettings struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+func (s *GotifySettings) validate() error {
+	if s.Token == "" {
+		return errors.New("token must not be empty")
+	}
+	return validateURL("url", s.URL, true)
+}
+
+type NtfySettings struct {
+	URL   string `json:"url,omitempty"`
+	Topic string `json:"topic"`
+	Token string `json:"token,omitempty"`
+}
+
+func (s *NtfySettings) validate() error {
+	if s.Topic == "" {
+		return errors.New("topic must not be empty")
+	}
+	return validateURL("url", s.URL, false)
+}
+
+type SMTPSettings struct {
+	Address  string   `json:"address"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+func (s *SMTPSettings) validate() error {
+	if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		return fmt.Errorf("address: %v", err)
+	}
+	if s.From == "" || len(s.To) == 0 {
+		return errors.New("from and to must not be empty")
+	}
+	return nil
+}
+
+func validateURL(name, value string, required bool) error {
+	if value == "" {
+		if required {
+			return fmt.Errorf("%s must not be empty", name)
+		}
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute HTTP or HTTPS URL", name, value)
+	}
+	return nil
+}
+
+// Validate checks the category, type and settings of a component.
+func Validate(component *model.Component) error {
+	_, err := decodeSettings(component)
+	return err
+}
+
+// decodeSettings decodes and validates the settings of a component and fills
+// in defaults.
+func decodeSettings(component *model.Component) (settings, error) {
+	categoryTypes, ok := types[component.Category]
+	if !ok {
+		return nil, fmt.Errorf("unknown category %q", component.Category)
+	}
+	newSettings, ok := categoryTypes[component.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s type %q", component.Category, component.Type)
+	}
+	s := newSettings()
+	data := []byte(component.Settings)
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(s); err != nil {
+		return nil, fmt.Errorf("settings: %v", err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("settings: %v", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/KnutZuidema/godarr/pkg/event"
+)
+
+const (
+	IndexerTypeBroadcasTheNet     = "broadcasthenet"
+	IndexerTypeRSS                = "rss"
+	DownloadClientTypeQBitTorrent = "qbittorrent"
+)
+
+// Config is the configuration of godarr. It is read from a YAML file and
+// every value can be overridden by an environment variable, see ApplyEnv.
+type Config struct {
+	Server          ServerConfig           `yaml:"server"`
+	Postgres        PostgresConfig         `yaml:"postgres"`
+	Log             LogConfig              `yaml:"log"`
+	Events          EventsConfig           `yaml:"events"`
+	Providers       ProvidersConfig        `yaml:"providers"`
+	Indexers        []IndexerConfig        `yaml:"indexers"`
+	DownloadClients []DownloadClientConfig `yaml:"downloadClients"`
+	Library         LibraryConfig          `yaml:"library"`
+	Naming          NamingConfig           `yaml:"naming"`
+	Notifications   NotificationsConfig    `yaml:"notifications"`
+	Tasks           TasksConfig            `yaml:"tasks"`
+	Cleanup         CleanupConfig          `yaml:"cleanup"`
+	Backup          BackupConfig           `yaml:"backup"`
+}
+
+type ServerConfig struct {
+	Address string `yaml:"address"`
+	// Time to drain requests, cancel monitors and save jobs on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type PostgresConfig struct {
+	Address string `yaml:"address"`
+	Migrate bool   `yaml:"migrate"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+type EventsConfig struct {
+	// Number of recent events kept for replay to reconnecting clients
+	BufferSize int `yaml:"bufferSize"`
+}
+
+type ProvidersConfig struct {
+	// Age after which the metadata of an item is refreshed even if it did not
+	// change, at most 14 days since TMDb only lists the changes of that period
+	RefreshAfter time.Duration `yaml:"refreshAfter"`
+	TMDB         TMDBConfig    `yaml:"tmdb"`
+	// TheTVDB is preferred over TMDb for TV series
+	TVDB TVDBConfig `yaml:"tvdb"`
+	OMDb OMDbConfig `yaml:"omdb"`
+	// Names of the providers in the order they are asked for metadata, the
+	// following ones fill the fields which are missing at the previous ones
+	Order []string            `yaml:"order"`
+	Cache ProviderCacheConfig `yaml:"cache"`
+}
+
+// ProviderCacheConfig caches searches and lookups of the providers in memory
+// and optionally in the database.
+type ProviderCacheConfig struct {
+	// Time responses are cached, caching is disabled if zero
+	TTL time.Duration `yaml:"ttl"`
+	// Time unknown IDs and searches without results are cached
+	NegativeTTL time.Duration `yaml:"negativeTtl"`
+	// Responses kept in memory
+	Size int `yaml:"size"`
+	// Keep responses in the database, so they survive restarts
+	Persist bool `yaml:"persist"`
+}
+
+type TMDBConfig struct {
+	// Providers are disabled if empty
+	APIKey    string          `yaml:"apiKey"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+}
+
+type TVDBConfig struct {
+	// Provider is disabled if empty
+	APIKey string `yaml:"apiKey"`
+	// PIN of the subscriber, only required for user supported keys
+	PIN string `yaml:"pin"`
+	// Base URL of the API, defaults to TheTVDB v4
+	URL       string          `yaml:"url"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+}
+
+type OMDbConfig struct {
+	// Provider is disabled if empty
+	APIKey string `yaml:"apiKey"`
+	// Base URL of the API, defaults to OMDb
+	URL       string          `yaml:"url"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+}
+
+type IndexerConfig struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	APIKey string `yaml:"apiKey"`
+	// Feed of rss indexers, including the API key if required
+	URL       string          `yaml:"url"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+}
+
+// RateLimitConfig limits the requests to an indexer or provider, the default
+// of its type is used if Requests is zero.
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+}
+
+type DownloadClientConfig struct {
+	Name               string                    `yaml:"name"`
+	Type               string                    `yaml:"type"`
+	Address            string                    `yaml:"address"`
+	Username           string                    `yaml:"username"`
+	Password           string                    `yaml:"password"`
+	Interval           time.Duration             `yaml:"interval"`
+	Paused             bool                      `yaml:"paused"`
+	SequentialDownload bool                      `yaml:"sequentialDownload"`
+	FirstLastPiecePrio bool                      `yaml:"firstLastPiecePrio"`
+	Categories         map[string]CategoryConfig `yaml:"categories"`
+	PathMappings       []PathMappingConfig       `yaml:"pathMappings"`
+}
+
+type CategoryConfig struct {
+	Name     string `yaml:"name"`
+	SavePath string `yaml:"savePath"`
+}
+
+type PathMappingConfig struct {
+	Remote string `yaml:"remote"`
+	Local  string `yaml:"local"`
+}
+
+type LibraryConfig struct {
+	Movies     string `yaml:"movies"`
+	TV         string `yaml:"tv"`
+	RecycleBin string `yaml:"recycleBin"`
+	Hardlink   bool   `yaml:"hardlink"`
+}
+
+// NamingConfig contains the templates of folder and file names in the library,
+// empty templates use the default.
+type NamingConfig struct {
+	MovieFolder  string `yaml:"movieFolder"`
+	MovieFile    string `yaml:"movieFile"`
+	SeriesFolder string `yaml:"seriesFolder"`
+	SeasonFolder string `yaml:"seasonFolder"`
+	EpisodeFile  string `yaml:"episodeFile"`
+}
+
+type NotificationsConfig struct {
+	TitleTemplate string         `yaml:"titleTemplate"`
+	BodyTemplate  string         `yaml:"bodyTemplate"`
+	ImageBaseURL  string         `yaml:"imageBaseUrl"`
+	Discord       WebhookConfig  `yaml:"discord"`
+	Slack         WebhookConfig  `yaml:"slack"`
+	Telegram      TelegramConfig `yaml:"telegram"`
+	Gotify        GotifyConfig   `yaml:"gotify"`
+	Ntfy          NtfyConfig     `yaml:"ntfy"`
+	SMTP          SMTPConfig     `yaml:"smtp"`
+}
+
+type WebhookConfig struct {
+	// Disabled if empty
+	URL string `yaml:"url"`
+}
+
+type TelegramConfig struct {
+	URL string `yaml:"url"`
+	// Disabled if empty
+	Token  string `yaml:"token"`
+	ChatID string `yaml:"chatId"`
+}
+
+type GotifyConfig struct {
+	// Disabled if empty
+	URL      string `yaml:"url"`
+	Token    string `yaml:"token"`
+	Priority int    `yaml:"priority"`
+}
+
+type NtfyConfig struct {
+	URL string `yaml:"url"`
+	// Disabled if empty
+	Topic string `yaml:"topic"`
+	Token string `yaml:"token"`
+}
+
+type SMTPConfig struct {
+	// host:port, disabled if empty
+	Address  string   `yaml:"address"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// TasksConfig contains the schedule of the recurring tasks.
+type TasksConfig struct {
+	MetadataRefresh TaskConfig `yaml:"metadataRefresh"`
+	RSSSync         TaskConfig `yaml:"rssSync"`
+	MissingSearch   TaskConfig `yaml:"missingSearch"`
+	LibraryRescan   TaskConfig `yaml:"libraryRescan"`
+	Cleanup         TaskConfig `yaml:"cleanup"`
+	Backup          TaskConfig `yaml:"backup"`
+}
+
+type TaskConfig struct {
+	// The task only runs when triggered via the API if zero
+	Interval time.Duration `yaml:"interval"`
+	// Maximum random delay added to every interval
+	Jitter time.Duration `yaml:"jitter"`
+}
+
+type CleanupConfig struct {
+	// Age after which webhook deliveries are deleted
+	Retention time.Duration `yaml:"retention"`
+}
+
+type BackupConfig struct {
+	// Folder the backups are written to
+	Path string `yaml:"path"`
+	// Number of backups which are kept
+	Keep int `yaml:"keep"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:         "localhost:5000",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Postgres: PostgresConfig{
+			Address: "postgres://postgres@localhost/postgres?sslmode=disable",
+			Migrate: true,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Events: EventsConfig{
+			BufferSize: event.DefaultBufferSize,
+		},
+		Providers: ProvidersConfig{
+			RefreshAfter: 7 * 24 * time.Hour,
+			Cache: ProviderCacheConfig{
+				TTL:         6 * time.Hour,
+				NegativeTTL: 15 * time.Minute,
+				Size:        1000,
+			},
+		},
+		Tasks: TasksConfig{
+			MetadataRefresh: TaskConfig{Interval: 24 * time.Hour, Jitter: time.Hour},
+			RSSSync:         TaskConfig{Interval: 15 * time.Minute, Jitter: time.Minute},
+			MissingSearch:   TaskConfig{Interval: 24 * time.Hour, Jitter: time.Hour},
+			LibraryRescan:   TaskConfig{Interval: 6 * time.Hour, Jitter: 10 * time.Minute},
+			Cleanup:         TaskConfig{Interval: 24 * time.Hour, Jitter: time.Hour},
+			Backup:          TaskConfig{Interval: 7 * 24 * time.Hour, Jitter: time.Hour},
+		},
+		Cleanup: CleanupConfig{
+			Retention: 30 * 24 * time.Hour,
+		},
+		Backup: BackupConfig{
+			Path: "backups",
+			Keep: 4,
+		},
+	}
+}
+
+// Load reads the configuration from the file at path on top of the defaults,
+// applies the environment overrides and validates the result. An empty path
+// only uses defaults and environment variables.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := ApplyEnv(config, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// setDefaults fills in defaults of list entries, which can not be part of
+// Default.
+func (c *Config) setDefaults() {
+	for i := range c.Indexers {
+		indexer := &c.Indexers[i]
+		if indexer.Name == "" {
+			indexer.Name = indexer.Type
+		}
+	}
+	for i := range c.DownloadClients {
+		client := &c.DownloadClients[i]
+		if client.Name == "" {
+			client.Name = client.Type
+		}
+		if client.Username == "" {
+			client.Username = "admin"
+		}
+		if client.Interval == 0 {
+			client.Interval = time.Minute
+		}
+	}
+}
+
+// ValidationError lists every invalid value of a configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e, "\n\t")
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of environment variables overriding the
+// configuration.
+const EnvPrefix = "GODARR"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv overrides values of the configuration with environment variables.
+// The name of a variable is EnvPrefix followed by the upper cased YAML keys of
+// the path to the value, joined by underscores, like GODARR_SERVER_ADDRESS or
+// GODARR_INDEXERS_0_APIKEY for the first indexer. List entries have to exist
+// in the file to be overridden. Lists of strings are comma separated.
+func ApplyEnv(config *Config, lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(config).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnv(v reflect.Value, name string, lookup func(string) (string, bool)) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			key := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+			if err := applyEnv(v.Field(i), name+"_"+strings.ToUpper(key), lookup); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := applyEnv(v.Index(i), name+"_"+strconv.Itoa(i), lookup); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := applyEnv(elem, name+"_"+strings.ToUpper(strings.Replace(key.String(), "-", "_", -1)), lookup); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+		return nil
+	}
+	raw, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+	if err := setValue(v, raw); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Slice:
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
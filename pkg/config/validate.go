@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/notification"
+	"github.com/KnutZuidema/godarr/pkg/organizer"
+)
+
+// Validate checks the whole configuration and reports every invalid value
+// with its path as ValidationError.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	fail := func(path, format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	required := func(path, value string) {
+		if value == "" {
+			fail(path, "must not be empty")
+		}
+	}
+	validURL := func(path, value string) {
+		if value == "" {
+			return
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(path, "%q is not an absolute HTTP or HTTPS URL", value)
+		}
+	}
+	validRateLimit := func(path string, limit RateLimitConfig) {
+		if limit.Requests < 0 {
+			fail(path+".requests", "must not be negative")
+		}
+		if limit.Requests > 0 && limit.Per <= 0 {
+			fail(path+".per", "must be positive")
+		}
+	}
+
+	required("server.address", c.Server.Address)
+	if c.Server.ShutdownTimeout <= 0 {
+		fail("server.shutdownTimeout", "must be positive")
+	}
+	required("postgres.address", c.Postgres.Address)
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		fail("log.level", "%v", err)
+	}
+	if c.Events.BufferSize <= 0 {
+		fail("events.bufferSize", "must be positive")
+	}
+
+	if c.Providers.RefreshAfter <= 0 || c.Providers.RefreshAfter > 14*24*time.Hour {
+		fail("providers.refreshAfter", "must be positive and at most 336h")
+	}
+	validRateLimit("providers.tmdb.rateLimit", c.Providers.TMDB.RateLimit)
+	validURL("providers.tvdb.url", c.Providers.TVDB.URL)
+	validRateLimit("providers.tvdb.rateLimit", c.Providers.TVDB.RateLimit)
+	validURL("providers.omdb.url", c.Providers.OMDb.URL)
+	validRateLimit("providers.omdb.rateLimit", c.Providers.OMDb.RateLimit)
+	if c.Providers.Cache.TTL < 0 {
+		fail("providers.cache.ttl", "must not be negative")
+	}
+	if c.Providers.Cache.NegativeTTL < 0 {
+		fail("providers.cache.negativeTtl", "must not be negative")
+	}
+	if c.Providers.Cache.Size <= 0 {
+		fail("providers.cache.size", "must be positive")
+	}
+	providers := map[string]bool{}
+	for i, name := range c.Providers.Order {
+		path := fmt.Sprintf("providers.order[%d]", i)
+		required(path, name)
+		if providers[name] {
+			fail(path, "%q is listed twice", name)
+		}
+		providers[name] = true
+	}
+
+	names := map[string]bool{}
+	for i, indexer := range c.Indexers {
+		path := fmt.Sprintf("indexers[%d]", i)
+		if names[indexer.Name] {
+			fail(path+".name", "%q is used by another indexer", indexer.Name)
+		}
+		names[indexer.Name] = true
+		validRateLimit(path+".rateLimit", indexer.RateLimit)
+		switch indexer.Type {
+		case IndexerTypeBroadcasTheNet:
+			required(path+".apiKey", indexer.APIKey)
+		case IndexerTypeRSS:
+			required(path+".url", indexer.URL)
+			validURL(path+".url", indexer.URL)
+		case "":
+			fail(path+".type", "must not be empty")
+		default:
+			fail(path+".type", "unknown type %q, supported are %s and %s", indexer.Type, IndexerTypeBroadcasTheNet, IndexerTypeRSS)
+		}
+	}
+
+	if len(c.DownloadClients) == 0 && len(c.Indexers) > 0 {
+		fail("downloadClients", "a download client is required to download releases of the indexers")
+	}
+	names = map[string]bool{}
+	for i, client := range c.DownloadClients {
+		path := fmt.Sprintf("downloadClients[%d]", i)
+		if names[client.Name] {
+			fail(path+".name", "%q is used by another download client", client.Name)
+		}
+		names[client.Name] = true
+		switch client.Type {
+		case DownloadClientTypeQBitTorrent:
+		case "":
+			fail(path+".type", "must not be empty")
+		default:
+			fail(path+".type", "unknown type %q, supported are %s", client.Type, DownloadClientTypeQBitTorrent)
+		}
+		required(path+".address", client.Address)
+		validURL(path+".address", client.Address)
+		if client.Interval < 0 {
+			fail(path+".interval", "must not be negative")
+		}
+		for kind, category := range client.Categories {
+			if kind != string(model.ItemKindMovie) && kind != model.ItemKindTVSeries {
+				fail(path+".categories", "unknown kind %q, supported are %s and %s", kind, model.ItemKindMovie, model.ItemKindTVSeries)
+			}
+			required(path+".categories."+kind+".name", category.Name)
+		}
+		for j, mapping := range client.PathMappings {
+			required(fmt.Sprintf("%s.pathMappings[%d].remote", path, j), mapping.Remote)
+			required(fmt.Sprintf("%s.pathMappings[%d].local", path, j), mapping.Local)
+		}
+	}
+
+	if len(c.Indexers) > 0 && c.Library.Movies == "" && c.Library.TV == "" {
+		fail("library", "movies or tv is required to import downloads")
+	}
+	for _, task := range []struct {
+		name   string
+		config TaskConfig
+	}{
+		{"metadataRefresh", c.Tasks.MetadataRefresh},
+		{"rssSync", c.Tasks.RSSSync},
+		{"missingSearch", c.Tasks.MissingSearch},
+		{"libraryRescan", c.Tasks.LibraryRescan},
+		{"cleanup", c.Tasks.Cleanup},
+		{"backup", c.Tasks.Backup},
+	} {
+		if task.config.Interval < 0 {
+			fail("tasks."+task.name+".interval", "must not be negative")
+		}
+		if task.config.Jitter < 0 {
+			fail("tasks."+task.name+".jitter", "must not be negative")
+		}
+	}
+	if c.Cleanup.Retention <= 0 {
+		fail("cleanup.retention", "must be positive")
+	}
+	required("backup.path", c.Backup.Path)
+	if c.Backup.Keep <= 0 {
+		fail("backup.keep", "must be positive")
+	}
+
+	if _, err := organizer.NewNaming(c.Naming.options()); err != nil {
+		fail("naming", "%v", err)
+	}
+
+	n := c.Notifications
+	if _, err := notification.NewTemplates(n.TitleTemplate, n.BodyTemplate); err != nil {
+		fail("notifications", "%v", err)
+	}
+	validURL("notifications.imageBaseUrl", n.ImageBaseURL)
+	validURL("notifications.discord.url", n.Discord.URL)
+	validURL("notifications.slack.url", n.Slack.URL)
+	validURL("notifications.telegram.url", n.Telegram.URL)
+	if n.Telegram.Token != "" {
+		required("notifications.telegram.chatId", n.Telegram.ChatID)
+	}
+	validURL("notifications.gotify.url", n.Gotify.URL)
+	if n.Gotify.URL != "" {
+		required("notifications.gotify.token", n.Gotify.Token)
+	}
+	validURL("notifications.ntfy.url", n.Ntfy.URL)
+	if n.SMTP.Address != "" {
+		if _, _, err := net.SplitHostPort(n.SMTP.Address); err != nil {
+			fail("notifications.smtp.address", "%v", err)
+		}
+		required("notifications.smtp.from", n.SMTP.From)
+		if len(n.SMTP.To) == 0 {
+			fail("notifications.smtp.to", "must not be empty")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (n NamingConfig) options() organizer.NamingOptions {
+	return organizer.NamingOptions{
+		MovieFolder:  n.MovieFolder,
+		MovieFile:    n.MovieFile,
+		SeriesFolder: n.SeriesFolder,
+		SeasonFolder: n.SeasonFolder,
+		EpisodeFile:  n.EpisodeFile,
+	}
+}
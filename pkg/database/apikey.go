@@ -0,0 +1,53 @@
+package database
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	createAPIKey = `
+		insert into api_key (
+			id,
+			name,
+			hash,
+			created_at
+		) values (
+			:id,
+			:name,
+			:hash,
+			:created_at
+		) returning *
+	`
+
+	listAPIKeys = `
+		select * from api_key
+		order by created_at
+	`
+
+	deleteAPIKey = `
+		delete from api_key where id = $1
+	`
+)
+
+func (d *database) CreateAPIKey(key *model.APIKey) (*model.APIKey, error) {
+	var res model.APIKey
+	if err := d.createAPIKey.Get(&res, key); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) ListAPIKeys() ([]*model.APIKey, error) {
+	keys := []*model.APIKey{}
+	if err := d.listAPIKeys.Select(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (d *database) DeleteAPIKey(id string) error {
+	if _, err := d.deleteAPIKey.Exec(id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	createComponent = `
+		insert into component (
+			id,
+			category,
+			type,
+			name,
+			enabled,
+			settings,
+			created_at
+		) values (
+			:id,
+			:category,
+			:type,
+			:name,
+			:enabled,
+			:settings,
+			:created_at
+		) returning *
+	`
+
+	updateComponent = `
+		update component set
+			type=:type,
+			name=:name,
+			enabled=:enabled,
+			settings=:settings
+		where id = :id
+		returning *
+	`
+
+	getComponent = `
+		select * from component where id = $1
+	`
+
+	listComponents = `
+		select * from component
+		order by category, name
+	`
+
+	deleteComponent = `
+		delete from component where id = $1
+	`
+)
+
+func (d *database) CreateComponent(component *model.Component) (*model.Component, error) {
+	var res model.Component
+	if err := d.createComponent.Get(&res, component); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) UpdateComponent(component *model.Component) (*model.Component, error) {
+	var res model.Component
+	if err := d.updateComponent.Get(&res, component); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) GetComponent(id string) (*model.Component, error) {
+	var component model.Component
+	if err := d.getComponent.Get(&component, id); err != nil {
+		return nil, err
+	}
+	return &component, nil
+}
+
+func (d *database) ListComponents() ([]*model.Component, error) {
+	components := []*model.Component{}
+	if err := d.listComponents.Select(&components); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+func (d *database) DeleteComponent(id string) error {
+	if _, err := d.deleteComponent.Exec(id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"io"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -12,44 +14,183 @@ type Database interface {
 	io.Closer
 	GetItem(id string) (*model.Item, error)
 	GetItemByExternalID(externalID string) (*model.Item, error)
+	GetItemBySourceID(source model.ExternalIDSource, id string) (*model.Item, error)
 	CreateItem(item *model.Item) (*model.Item, error)
-	ListItems(offset, count int) ([]*model.Item, error)
+	ImportItem(item *model.Item, files []*model.MediaFile) (*model.Item, error)
+	UpdateItemMetadata(item *model.Item) (*model.Item, error)
+	ListItems(filter model.ItemFilter, offset, count int) ([]*model.Item, error)
+	CountItems(filter model.ItemFilter) (int, error)
 	SetItemStatus(id string, status model.ItemStatus) error
 	GetItemStatus(id string) (model.ItemStatus, error)
+	SetItemEpisodeOrder(id string, order model.EpisodeOrder) error
+	SetItemPath(id, path string) error
+	DeleteItem(id string) error
+	CreateMediaFile(file *model.MediaFile) (*model.MediaFile, error)
+	UpdateMediaFile(file *model.MediaFile) error
+	DeleteMediaFile(id string) error
+	ListMediaFiles(itemID string) ([]*model.MediaFile, error)
+	ListAllMediaFiles() ([]*model.MediaFile, error)
+	GetQualityProfile(id int) (*model.QualityProfile, error)
+	ListQualityProfiles() ([]*model.QualityProfile, error)
+	CreateQualityProfile(profile *model.QualityProfile) (*model.QualityProfile, error)
+	AddHistory(entry *model.HistoryEntry) error
+	ListItemHistory(itemID string) ([]*model.HistoryEntry, error)
+	ListHistory(offset, count int) ([]*model.HistoryEntry, error)
+	CountHistory() (int, error)
+	CreateAPIKey(key *model.APIKey) (*model.APIKey, error)
+	ListAPIKeys() ([]*model.APIKey, error)
+	DeleteAPIKey(id string) error
+	CountItemsRequestedSince(userID string, since time.Time) (int, error)
+	CreateUser(user *model.User) (*model.User, error)
+	UpdateUser(user *model.User) (*model.User, error)
+	GetUser(id string) (*model.User, error)
+	GetUserByName(name string) (*model.User, error)
+	ListUsers() ([]*model.User, error)
+	DeleteUser(id string) error
+	CreateSession(session *model.Session) error
+	GetSessionUser(tokenHash string) (*model.User, error)
+	DeleteSession(tokenHash string) error
+	DeleteUserSessions(userID string) error
+	CreateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	GetWebhook(id string) (*model.Webhook, error)
+	ListWebhooks() ([]*model.Webhook, error)
+	DeleteWebhook(id string) error
+	AddWebhookDelivery(delivery *model.WebhookDelivery) error
+	ListWebhookDeliveries(webhookID string, offset, count int) ([]*model.WebhookDelivery, error)
+	CreateComponent(component *model.Component) (*model.Component, error)
+	UpdateComponent(component *model.Component) (*model.Component, error)
+	GetComponent(id string) (*model.Component, error)
+	ListComponents() ([]*model.Component, error)
+	DeleteComponent(id string) error
+	SaveJobs(jobs []*model.Job) error
+	TakeJobs() ([]*model.Job, error)
+	SaveTask(task *model.Task) error
+	ListTasks() ([]*model.Task, error)
+	DeleteExpiredSessions(now time.Time) (int64, error)
+	DeleteWebhookDeliveriesBefore(before time.Time) (int64, error)
+	GetProviderCache(key string, now time.Time) (*model.ProviderCacheEntry, error)
+	SetProviderCache(entry *model.ProviderCacheEntry) error
+	DeleteExpiredProviderCache(now time.Time) (int64, error)
 }
 
 const (
-	getItem = `
-		select * from item where id = $1
+	// selectItem selects items together with their latest status and their
+	// external IDs
+	selectItem = `
+		select item.*, coalesce((
+			select status from item_status
+			where item_id = item.id
+			order by received_at desc
+			limit 1
+		), 'added') as status,
+		coalesce((
+			select value from external_id
+			where item_id = item.id and source = 'tmdb'
+		), '') as tmdb_id,
+		coalesce((
+			select value from external_id
+			where item_id = item.id and source = 'imdb'
+		), '') as imdb_id,
+		coalesce((
+			select value from external_id
+			where item_id = item.id and source = 'tvdb'
+		), '') as tvdb_id,
+		coalesce((
+			select value from external_id
+			where item_id = item.id and source = 'tvrage'
+		), '') as tvrage_id
+		from item
 	`
 
-	getItemByExternalID = `
-		select * from item where external_id = $1
+	getItem = selectItem + `
+		where id = $1
+	`
+
+	getItemByExternalID = selectItem + `
+		where external_id = $1
+	`
+
+	getItemBySourceID = selectItem + `
+		where id = (
+			select item_id from external_id
+			where source = $1 and value = $2
+			limit 1
+		)
+	`
+
+	setExternalID = `
+		insert into external_id (
+			item_id, source, value
+		) values (
+			$1, $2, $3
+		) on conflict (item_id, source) do update set
+			value = $3
 	`
 
 	createItem = `
-		insert into item values (
+		insert into item (
+			id,
+			external_id,
+			kind,
+			title,
+			description,
+			image_path,
+			release_year,
+			genres,
+			rating,
+			path,
+			quality_profile_id,
+			requested_by,
+			refreshed_at,
+			episode_order,
+			metadata_sources
+		) values (
 			:id,
 			:external_id,
 			:kind,
 			:title,
 			:description,
 			:image_path,
-			:rating
+			:release_year,
+			coalesce(cast(:genres as text[]), '{}'),
+			:rating,
+			:path,
+			:quality_profile_id,
+			:requested_by,
+			:refreshed_at,
+			coalesce(nullif(:episode_order, ''), 'aired'),
+			coalesce(cast(:metadata_sources as jsonb), '{}')
 		) on conflict (id) do update set
 			external_id=:external_id,
 			kind=:kind,
 			title=:title,
 			description=:description,
 			image_path=:image_path,
-			rating=:rating
+			release_year=:release_year,
+			genres=coalesce(cast(:genres as text[]), '{}'),
+			rating=:rating,
+			path=:path,
+			quality_profile_id=:quality_profile_id,
+			refreshed_at=coalesce(:refreshed_at, item.refreshed_at),
+			episode_order=coalesce(nullif(:episode_order, ''), item.episode_order),
+			metadata_sources=coalesce(cast(:metadata_sources as jsonb), item.metadata_sources)
 		returning *
 	`
 
-	listItems = `
-		select * from item
-		order by id
-		offset $1 limit $2
+	// updateItemMetadata only updates the columns which are refreshed from
+	// providers, so concurrent changes of e.g. the path are kept
+	updateItemMetadata = `
+		update item set
+			title=:title,
+			description=:description,
+			image_path=:image_path,
+			release_year=:release_year,
+			genres=coalesce(cast(:genres as text[]), '{}'),
+			rating=:rating,
+			refreshed_at=:refreshed_at,
+			metadata_sources=coalesce(cast(:metadata_sources as jsonb), item.metadata_sources)
+		where id = :id
 	`
 
 	setItemStatus = `
@@ -58,60 +199,224 @@ const (
 		)
 	`
 
+	setItemEpisodeOrder = `
+		update item set episode_order = $2
+		where id = $1
+	`
+
+	setItemPath = `
+		update item set path = $2
+		where id = $1
+	`
+
 	getItemStatus = `
 		select status from item_status
 		where item_id = $1
 		order by received_at desc
 		limit 1
 	`
+
+	deleteItem = `
+		delete from item where id = $1
+	`
 )
 
 type database struct {
-	getItem             *sqlx.Stmt
-	getItemByExternalID *sqlx.Stmt
-	createItem          *sqlx.NamedStmt
-	listItems           *sqlx.Stmt
-	setItemStatus       *sqlx.Stmt
-	getItemStatus       *sqlx.Stmt
+	db    *sqlx.DB
+	stmts []io.Closer
+
+	getItem               *sqlx.Stmt
+	getItemByExternalID   *sqlx.Stmt
+	createItem            *sqlx.NamedStmt
+	updateItemMetadata    *sqlx.NamedStmt
+	setItemStatus         *sqlx.Stmt
+	getItemStatus         *sqlx.Stmt
+	getItemBySourceID     *sqlx.Stmt
+	setItemEpisodeOrder   *sqlx.Stmt
+	setItemPath           *sqlx.Stmt
+	deleteItem            *sqlx.Stmt
+	addItemDeletedHistory *sqlx.Stmt
+	setExternalID         *sqlx.Stmt
+
+	createMediaFile   *sqlx.NamedStmt
+	updateMediaFile   *sqlx.NamedStmt
+	deleteMediaFile   *sqlx.Stmt
+	listMediaFiles    *sqlx.Stmt
+	listAllMediaFiles *sqlx.Stmt
+
+	getQualityProfile    *sqlx.Stmt
+	listQualityProfiles  *sqlx.Stmt
+	createQualityProfile *sqlx.NamedStmt
+
+	addHistory      *sqlx.NamedStmt
+	listItemHistory *sqlx.Stmt
+	listHistory     *sqlx.Stmt
+	countHistory    *sqlx.Stmt
+
+	createAPIKey *sqlx.NamedStmt
+	listAPIKeys  *sqlx.Stmt
+	deleteAPIKey *sqlx.Stmt
+
+	countItemsRequestedSince *sqlx.Stmt
+	createUser               *sqlx.NamedStmt
+	updateUser               *sqlx.NamedStmt
+	getUser                  *sqlx.Stmt
+	getUserByName            *sqlx.Stmt
+	listUsers                *sqlx.Stmt
+	deleteUser               *sqlx.Stmt
+	createSession            *sqlx.NamedStmt
+	getSessionUser           *sqlx.Stmt
+	deleteSession            *sqlx.Stmt
+	deleteUserSessions       *sqlx.Stmt
+	deleteExpiredSessions    *sqlx.Stmt
+
+	createWebhook         *sqlx.NamedStmt
+	updateWebhook         *sqlx.NamedStmt
+	getWebhook            *sqlx.Stmt
+	listWebhooks          *sqlx.Stmt
+	deleteWebhook         *sqlx.Stmt
+	addWebhookDelivery    *sqlx.NamedStmt
+	listWebhookDeliveries *sqlx.Stmt
+
+	deleteWebhookDeliveriesBefore *sqlx.Stmt
+
+	createComponent *sqlx.NamedStmt
+	updateComponent *sqlx.NamedStmt
+	getComponent    *sqlx.Stmt
+	listComponents  *sqlx.Stmt
+	deleteComponent *sqlx.Stmt
+
+	createJob *sqlx.NamedStmt
+	takeJobs  *sqlx.Stmt
+
+	saveTask  *sqlx.NamedStmt
+	listTasks *sqlx.Stmt
+
+	getProviderCache           *sqlx.Stmt
+	setProviderCache           *sqlx.NamedStmt
+	deleteExpiredProviderCache *sqlx.Stmt
 }
 
-func New(db *sqlx.DB) (Database, error) {
-	getItem, err := db.Preparex(getItem)
-	if err != nil {
-		return nil, err
+// preparer prepares statements until the first error occurs and keeps track
+// of the prepared statements, so they can be closed.
+type preparer struct {
+	db    *sqlx.DB
+	stmts []io.Closer
+	err   error
+}
+
+func (p *preparer) stmt(query string) *sqlx.Stmt {
+	if p.err != nil {
+		return nil
 	}
-	getItemByExternalID, err := db.Preparex(getItemByExternalID)
+	stmt, err := p.db.Preparex(query)
 	if err != nil {
-		return nil, err
+		p.err = err
+		return nil
 	}
-	createItem, err := db.PrepareNamed(createItem)
-	if err != nil {
-		return nil, err
+	p.stmts = append(p.stmts, stmt)
+	return stmt
+}
+
+func (p *preparer) named(query string) *sqlx.NamedStmt {
+	if p.err != nil {
+		return nil
 	}
-	listItems, err := db.Preparex(listItems)
+	stmt, err := p.db.PrepareNamed(query)
 	if err != nil {
-		return nil, err
+		p.err = err
+		return nil
 	}
-	setItemStatus, err := db.Preparex(setItemStatus)
-	if err != nil {
-		return nil, err
+	p.stmts = append(p.stmts, stmt)
+	return stmt
+}
+
+func New(db *sqlx.DB) (Database, error) {
+	p := &preparer{db: db}
+	d := &database{
+		db: db,
+
+		getItem:               p.stmt(getItem),
+		getItemByExternalID:   p.stmt(getItemByExternalID),
+		createItem:            p.named(createItem),
+		updateItemMetadata:    p.named(updateItemMetadata),
+		setItemStatus:         p.stmt(setItemStatus),
+		getItemStatus:         p.stmt(getItemStatus),
+		getItemBySourceID:     p.stmt(getItemBySourceID),
+		setItemEpisodeOrder:   p.stmt(setItemEpisodeOrder),
+		setItemPath:           p.stmt(setItemPath),
+		deleteItem:            p.stmt(deleteItem),
+		addItemDeletedHistory: p.stmt(addItemDeletedHistory),
+		setExternalID:         p.stmt(setExternalID),
+
+		createMediaFile:   p.named(createMediaFile),
+		updateMediaFile:   p.named(updateMediaFile),
+		deleteMediaFile:   p.stmt(deleteMediaFile),
+		listMediaFiles:    p.stmt(listMediaFiles),
+		listAllMediaFiles: p.stmt(listAllMediaFiles),
+
+		getQualityProfile:    p.stmt(getQualityProfile),
+		listQualityProfiles:  p.stmt(listQualityProfiles),
+		createQualityProfile: p.named(createQualityProfile),
+
+		addHistory:      p.named(addHistory),
+		listItemHistory: p.stmt(listItemHistory),
+		listHistory:     p.stmt(listHistory),
+		countHistory:    p.stmt(countHistory),
+
+		createAPIKey: p.named(createAPIKey),
+		listAPIKeys:  p.stmt(listAPIKeys),
+		deleteAPIKey: p.stmt(deleteAPIKey),
+
+		countItemsRequestedSince: p.stmt(countItemsRequestedSince),
+		createUser:               p.named(createUser),
+		updateUser:               p.named(updateUser),
+		getUser:                  p.stmt(getUser),
+		getUserByName:            p.stmt(getUserByName),
+		listUsers:                p.stmt(listUsers),
+		deleteUser:               p.stmt(deleteUser),
+		createSession:            p.named(createSession),
+		getSessionUser:           p.stmt(getSessionUser),
+		deleteSession:            p.stmt(deleteSession),
+		deleteUserSessions:       p.stmt(deleteUserSessions),
+		deleteExpiredSessions:    p.stmt(deleteExpiredSessions),
+
+		createWebhook:         p.named(createWebhook),
+		updateWebhook:         p.named(updateWebhook),
+		getWebhook:            p.stmt(getWebhook),
+		listWebhooks:          p.stmt(listWebhooks),
+		deleteWebhook:         p.stmt(deleteWebhook),
+		addWebhookDelivery:    p.named(addWebhookDelivery),
+		listWebhookDeliveries: p.stmt(listWebhookDeliveries),
+
+		deleteWebhookDeliveriesBefore: p.stmt(deleteWebhookDeliveriesBefore),
+
+		createComponent: p.named(createComponent),
+		updateComponent: p.named(updateComponent),
+		getComponent:    p.stmt(getComponent),
+		listComponents:  p.stmt(listComponents),
+		deleteComponent: p.stmt(deleteComponent),
+
+		createJob: p.named(createJob),
+		takeJobs:  p.stmt(takeJobs),
+
+		saveTask:  p.named(saveTask),
+		listTasks: p.stmt(listTasks),
+
+		getProviderCache:           p.stmt(getProviderCache),
+		setProviderCache:           p.named(setProviderCache),
+		deleteExpiredProviderCache: p.stmt(deleteExpiredProviderCache),
 	}
-	getItemStatus, err := db.Preparex(getItemStatus)
-	if err != nil {
-		return nil, err
+	d.stmts = p.stmts
+	if p.err != nil {
+		_ = d.Close()
+		return nil, p.err
 	}
-	return &database{
-		getItem:             getItem,
-		getItemByExternalID: getItemByExternalID,
-		createItem:          createItem,
-		listItems:           listItems,
-		setItemStatus:       setItemStatus,
-		getItemStatus:       getItemStatus,
-	}, nil
+	return d, nil
 }
 
 func (d *database) Close() error {
-	for _, stmt := range []io.Closer{d.getItem, d.getItemByExternalID, d.createItem, d.listItems, d.getItemStatus, d.setItemStatus} {
+	for _, stmt := range d.stmts {
 		if err := stmt.Close(); err != nil {
 			return err
 		}
@@ -135,20 +440,118 @@ func (d *database) GetItemByExternalID(externalID string) (*model.Item, error) {
 	return &item, nil
 }
 
-func (d *database) CreateItem(item *model.Item) (*model.Item, error) {
-	var res model.Item
-	if err := d.createItem.Get(&res, item); err != nil {
+func (d *database) GetItemBySourceID(source model.ExternalIDSource, id string) (*model.Item, error) {
+	var item model.Item
+	if err := d.getItemBySourceID.Get(&item, source, id); err != nil {
 		return nil, err
 	}
-	return &res, nil
+	return &item, nil
 }
 
-func (d *database) ListItems(offset, count int) ([]*model.Item, error) {
-	items := []*model.Item{}
-	if err := d.listItems.Select(&items, offset, count); err != nil {
+// CreateItem creates or updates an item together with its external IDs. IDs
+// which are empty keep their stored value.
+func (d *database) CreateItem(item *model.Item) (res *model.Item, err error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
 		return nil, err
 	}
-	return items, nil
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	id, err := d.createItemTx(tx, item)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return d.GetItem(id)
+}
+
+// ImportItem creates an item found in the library together with its media
+// files and marks it as downloaded. Nothing is stored if any of it fails.
+func (d *database) ImportItem(item *model.Item, files []*model.MediaFile) (res *model.Item, err error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	id, err := d.createItemTx(tx, item)
+	if err != nil {
+		return nil, err
+	}
+	stmt := tx.NamedStmt(d.createMediaFile)
+	for _, file := range files {
+		file.ItemID = id
+		if _, err = stmt.Exec(file); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = tx.Stmtx(d.setItemStatus).Exec(id, model.ItemStatusDownloaded); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return d.GetItem(id)
+}
+
+func (d *database) createItemTx(tx *sqlx.Tx, item *model.Item) (string, error) {
+	var created model.Item
+	if err := tx.NamedStmt(d.createItem).Get(&created, item); err != nil {
+		return "", err
+	}
+	stmt := tx.Stmtx(d.setExternalID)
+	for _, source := range model.ExternalIDSources {
+		if id := item.ExternalIDs.Get(source); id != "" {
+			if _, err := stmt.Exec(created.ID, source, id); err != nil {
+				return "", err
+			}
+		}
+	}
+	return created.ID, nil
+}
+
+// UpdateItemMetadata updates the metadata of an item refreshed from its
+// providers together with its external IDs. IDs which are empty keep their
+// stored value.
+func (d *database) UpdateItemMetadata(item *model.Item) (res *model.Item, err error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	result, err := tx.NamedStmt(d.updateItemMetadata).Exec(item)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+	stmt := tx.Stmtx(d.setExternalID)
+	for _, source := range model.ExternalIDSources {
+		if id := item.ExternalIDs.Get(source); id != "" {
+			if _, err = stmt.Exec(item.ID, source, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return d.GetItem(item.ID)
 }
 
 func (d *database) SetItemStatus(id string, status model.ItemStatus) error {
@@ -165,3 +568,41 @@ func (d *database) GetItemStatus(id string) (model.ItemStatus, error) {
 	}
 	return model.ItemStatus(res), nil
 }
+
+func (d *database) SetItemEpisodeOrder(id string, order model.EpisodeOrder) error {
+	if _, err := d.setItemEpisodeOrder.Exec(id, order); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetItemPath sets the folder of an item in its library root, without
+// touching its other fields.
+func (d *database) SetItemPath(id, path string) error {
+	if _, err := d.setItemPath.Exec(id, path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteItem deletes an item together with its status, media file records
+// and jobs. Its history is kept with the title of the item and an itemDeleted
+// entry is added to it. The files on disk are kept.
+func (d *database) DeleteItem(id string) (err error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err = tx.Stmtx(d.addItemDeletedHistory).Exec(id, model.HistoryEventItemDeleted, time.Now().UTC()); err != nil {
+		return err
+	}
+	if _, err = tx.Stmtx(d.deleteItem).Exec(id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
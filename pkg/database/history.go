@@ -0,0 +1,86 @@
+package database
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	addHistory = `
+		insert into history (
+			item_id,
+			title,
+			event,
+			date,
+			data
+		) values (
+			:item_id,
+			coalesce((select title from item where id = :item_id), ''),
+			:event,
+			:date,
+			:data
+		)
+	`
+
+	// the item of an entry is null once the item was deleted
+	historyColumns = `
+		id,
+		coalesce(item_id::text, '') as item_id,
+		title,
+		event,
+		date,
+		data
+	`
+
+	listItemHistory = `
+		select ` + historyColumns + ` from history
+		where item_id = $1
+		order by date desc, id desc
+	`
+
+	listHistory = `
+		select ` + historyColumns + ` from history
+		order by date desc, id desc
+		offset $1 limit $2
+	`
+
+	addItemDeletedHistory = `
+		insert into history (item_id, title, event, date, data)
+		select id, coalesce(title, ''), $2, $3, jsonb_build_object('kind', kind, 'path', path)
+		from item where id = $1
+	`
+
+	countHistory = `
+		select count(*) from history
+	`
+)
+
+func (d *database) AddHistory(entry *model.HistoryEntry) error {
+	if _, err := d.addHistory.Exec(entry); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) ListItemHistory(itemID string) ([]*model.HistoryEntry, error) {
+	entries := []*model.HistoryEntry{}
+	if err := d.listItemHistory.Select(&entries, itemID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (d *database) ListHistory(offset, count int) ([]*model.HistoryEntry, error) {
+	entries := []*model.HistoryEntry{}
+	if err := d.listHistory.Select(&entries, offset, count); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (d *database) CountHistory() (int, error) {
+	var count int
+	if err := d.countHistory.Get(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// itemSortColumns maps the sort orders of items to the columns they sort by.
+// Only columns listed here are ever interpolated into a query.
+var itemSortColumns = map[model.ItemSort]string{
+	model.ItemSortTitle:       "lower(title)",
+	model.ItemSortAddedAt:     "added_at",
+	model.ItemSortReleaseYear: "release_year",
+	model.ItemSortRating:      "rating",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// itemQuery builds the where clause and arguments of a filtered item query.
+type itemQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (q *itemQuery) where(condition string, arg interface{}) {
+	q.args = append(q.args, arg)
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, len(q.args)))
+}
+
+func (q *itemQuery) arg(arg interface{}) string {
+	q.args = append(q.args, arg)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *itemQuery) String() string {
+	// wrap the select to be able to filter by the computed status
+	query := "select * from (" + selectItem + ") item"
+	if len(q.conditions) > 0 {
+		query += " where " + strings.Join(q.conditions, " and ")
+	}
+	return query
+}
+
+func newItemQuery(filter model.ItemFilter) *itemQuery {
+	q := &itemQuery{}
+	if filter.Kind != "" {
+		q.where("kind = $%d", filter.Kind)
+	}
+	if filter.Status != "" {
+		q.where("status = $%d", filter.Status)
+	}
+	if filter.Genre != "" {
+		// genres are compared case insensitively but otherwise exactly
+		q.where("exists (select 1 from unnest(genres) genre where lower(genre) = lower($%d))", filter.Genre)
+	}
+	if filter.MinYear != 0 {
+		q.where("release_year >= $%d", filter.MinYear)
+	}
+	if filter.MaxYear != 0 {
+		q.where("release_year <= $%d", filter.MaxYear)
+	}
+	if filter.MinRating != 0 {
+		q.where("rating >= $%d", filter.MinRating)
+	}
+	if filter.MaxRating != 0 {
+		q.where("rating <= $%d", filter.MaxRating)
+	}
+	if filter.Search != "" {
+		// ilike on title is served by the trigram index
+		q.where("title ilike '%%' || $%d || '%%'", likeEscaper.Replace(filter.Search))
+	}
+	return q
+}
+
+func (d *database) ListItems(filter model.ItemFilter, offset, count int) ([]*model.Item, error) {
+	q := newItemQuery(filter)
+	order := "id"
+	if column, ok := itemSortColumns[filter.Sort]; ok {
+		direction := "asc"
+		if filter.Descending {
+			direction = "desc"
+		}
+		// id keeps the order stable between pages
+		order = fmt.Sprintf("%s %s nulls last, id", column, direction)
+	}
+	query := fmt.Sprintf("%s order by %s offset %s limit %s", q, order, q.arg(offset), q.arg(count))
+	items := []*model.Item{}
+	if err := d.db.Select(&items, query, q.args...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (d *database) CountItems(filter model.ItemFilter) (int, error) {
+	q := newItemQuery(filter)
+	var count int
+	if err := d.db.Get(&count, "select count(*) from ("+q.String()+") item", q.args...); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
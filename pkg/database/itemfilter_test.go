@@ -0,0 +1,29 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+func TestNewItemQuery(t *testing.T) {
+	q := newItemQuery(model.ItemFilter{
+		Kind:   model.ItemKindMovie,
+		Genre:  "Sci_Fi%",
+		Search: "100%",
+	})
+	expectedConditions := []string{
+		"kind = $1",
+		"exists (select 1 from unnest(genres) genre where lower(genre) = lower($2))",
+		"title ilike '%' || $3 || '%'",
+	}
+	if !reflect.DeepEqual(q.conditions, expectedConditions) {
+		t.Errorf("conditions %q, expected %q", q.conditions, expectedConditions)
+	}
+	// the genre is compared as is, only the search is a pattern
+	expectedArgs := []interface{}{model.ItemKind(model.ItemKindMovie), "Sci_Fi%", `100\%`}
+	if !reflect.DeepEqual(q.args, expectedArgs) {
+		t.Errorf("arguments %q, expected %q", q.args, expectedArgs)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	createJob = `
+		insert into job (
+			id,
+			kind,
+			item_id,
+			title,
+			indexer,
+			size,
+			quality,
+			torrent,
+			path,
+			created_at
+		) values (
+			:id,
+			:kind,
+			:item_id,
+			:title,
+			:indexer,
+			:size,
+			:quality,
+			:torrent,
+			:path,
+			:created_at
+		)
+	`
+
+	takeJobs = `
+		delete from job
+		returning *
+	`
+)
+
+// SaveJobs stores the jobs in a single transaction, so either all or none of
+// them are resumed.
+func (d *database) SaveJobs(jobs []*model.Job) (err error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	stmt := tx.NamedStmt(d.createJob)
+	for _, job := range jobs {
+		if _, err := stmt.Exec(job); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TakeJobs returns the stored jobs ordered by creation and removes them.
+func (d *database) TakeJobs() ([]*model.Job, error) {
+	var res []*model.Job
+	if err := d.takeJobs.Select(&res); err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
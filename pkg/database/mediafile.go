@@ -0,0 +1,105 @@
+package database
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	createMediaFile = `
+		insert into media_file (
+			id,
+			item_id,
+			season_number,
+			episode_number,
+			path,
+			size,
+			quality,
+			release_group,
+			imported_at,
+			modified_at
+		) values (
+			:id,
+			:item_id,
+			:season_number,
+			:episode_number,
+			:path,
+			:size,
+			:quality,
+			:release_group,
+			:imported_at,
+			:modified_at
+		) on conflict (path) do update set
+			id=:id,
+			item_id=:item_id,
+			season_number=:season_number,
+			episode_number=:episode_number,
+			size=:size,
+			quality=:quality,
+			release_group=:release_group,
+			imported_at=:imported_at,
+			modified_at=:modified_at
+		returning *
+	`
+
+	updateMediaFile = `
+		update media_file set
+			size=:size,
+			quality=:quality,
+			release_group=:release_group,
+			modified_at=:modified_at
+		where id = :id
+	`
+
+	deleteMediaFile = `
+		delete from media_file where id = $1
+	`
+
+	listMediaFiles = `
+		select * from media_file
+		where item_id = $1
+		order by season_number, episode_number, path
+	`
+
+	listAllMediaFiles = `
+		select * from media_file
+		order by item_id, path
+	`
+)
+
+func (d *database) CreateMediaFile(file *model.MediaFile) (*model.MediaFile, error) {
+	var res model.MediaFile
+	if err := d.createMediaFile.Get(&res, file); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) UpdateMediaFile(file *model.MediaFile) error {
+	if _, err := d.updateMediaFile.Exec(file); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) DeleteMediaFile(id string) error {
+	if _, err := d.deleteMediaFile.Exec(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) ListMediaFiles(itemID string) ([]*model.MediaFile, error) {
+	files := []*model.MediaFile{}
+	if err := d.listMediaFiles.Select(&files, itemID); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (d *database) ListAllMediaFiles() ([]*model.MediaFile, error) {
+	files := []*model.MediaFile{}
+	if err := d.listAllMediaFiles.Select(&files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
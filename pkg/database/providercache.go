@@ -0,0 +1,62 @@
+package database
+
+import (
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	getProviderCache = `
+		select * from provider_cache
+		where key = $1 and expires_at > $2
+	`
+
+	setProviderCache = `
+		insert into provider_cache (
+			key,
+			value,
+			not_found,
+			expires_at
+		) values (
+			:key,
+			:value,
+			:not_found,
+			:expires_at
+		) on conflict (key) do update set
+			value=:value,
+			not_found=:not_found,
+			expires_at=:expires_at
+	`
+
+	deleteExpiredProviderCache = `
+		delete from provider_cache where expires_at < $1
+	`
+)
+
+// GetProviderCache returns the cached response with the key which has not
+// expired at now, sql.ErrNoRows is returned otherwise.
+func (d *database) GetProviderCache(key string, now time.Time) (*model.ProviderCacheEntry, error) {
+	var entry model.ProviderCacheEntry
+	if err := d.getProviderCache.Get(&entry, key, now); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (d *database) SetProviderCache(entry *model.ProviderCacheEntry) error {
+	if _, err := d.setProviderCache.Exec(entry); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpiredProviderCache deletes cached responses which expired before
+// now and returns their number.
+func (d *database) DeleteExpiredProviderCache(now time.Time) (int64, error) {
+	res, err := d.deleteExpiredProviderCache.Exec(now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"github.com/lib/pq"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	getQualityProfile = `
+		select * from quality_profile where id = $1
+	`
+
+	listQualityProfiles = `
+		select * from quality_profile
+		order by id
+	`
+
+	createQualityProfile = `
+		insert into quality_profile (
+			name,
+			allowed,
+			cutoff
+		) values (
+			:name,
+			:allowed,
+			:cutoff
+		) returning *
+	`
+)
+
+type qualityProfile struct {
+	ID      int            `db:"id"`
+	Name    string         `db:"name"`
+	Allowed pq.StringArray `db:"allowed"`
+	Cutoff  string         `db:"cutoff"`
+}
+
+func (p qualityProfile) model() *model.QualityProfile {
+	allowed := make([]model.Quality, 0, len(p.Allowed))
+	for _, quality := range p.Allowed {
+		allowed = append(allowed, model.Quality(quality))
+	}
+	return &model.QualityProfile{
+		ID:      p.ID,
+		Name:    p.Name,
+		Allowed: allowed,
+		Cutoff:  model.Quality(p.Cutoff),
+	}
+}
+
+func (d *database) GetQualityProfile(id int) (*model.QualityProfile, error) {
+	var res qualityProfile
+	if err := d.getQualityProfile.Get(&res, id); err != nil {
+		return nil, err
+	}
+	return res.model(), nil
+}
+
+func (d *database) ListQualityProfiles() ([]*model.QualityProfile, error) {
+	var rows []qualityProfile
+	if err := d.listQualityProfiles.Select(&rows); err != nil {
+		return nil, err
+	}
+	profiles := make([]*model.QualityProfile, 0, len(rows))
+	for _, row := range rows {
+		profiles = append(profiles, row.model())
+	}
+	return profiles, nil
+}
+
+func (d *database) CreateQualityProfile(profile *model.QualityProfile) (*model.QualityProfile, error) {
+	row := qualityProfile{
+		Name:   profile.Name,
+		Cutoff: string(profile.Cutoff),
+	}
+	for _, quality := range profile.Allowed {
+		row.Allowed = append(row.Allowed, string(quality))
+	}
+	if row.Allowed == nil {
+		row.Allowed = pq.StringArray{}
+	}
+	var res qualityProfile
+	if err := d.createQualityProfile.Get(&res, row); err != nil {
+		return nil, err
+	}
+	return res.model(), nil
+}
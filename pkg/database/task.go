@@ -0,0 +1,47 @@
+package database
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	saveTask = `
+		insert into task (
+			name,
+			last_run_at,
+			last_duration,
+			last_error,
+			next_run_at
+		) values (
+			:name,
+			:last_run_at,
+			:last_duration,
+			:last_error,
+			:next_run_at
+		) on conflict (name) do update set
+			last_run_at=:last_run_at,
+			last_duration=:last_duration,
+			last_error=:last_error,
+			next_run_at=:next_run_at
+	`
+
+	listTasks = `
+		select * from task
+		order by name
+	`
+)
+
+func (d *database) SaveTask(task *model.Task) error {
+	if _, err := d.saveTask.Exec(task); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) ListTasks() ([]*model.Task, error) {
+	var res []*model.Task
+	if err := d.listTasks.Select(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	countItemsRequestedSince = `
+		select count(*) from item
+		where requested_by = $1 and added_at >= $2
+	`
+
+	createUser = `
+		insert into user_account (
+			id,
+			name,
+			password_hash,
+			role,
+			request_quota,
+			quota_days,
+			created_at
+		) values (
+			:id,
+			:name,
+			:password_hash,
+			:role,
+			:request_quota,
+			:quota_days,
+			:created_at
+		) returning *
+	`
+
+	updateUser = `
+		update user_account set
+			name=:name,
+			password_hash=:password_hash,
+			role=:role,
+			request_quota=:request_quota,
+			quota_days=:quota_days
+		where id = :id
+		returning *
+	`
+
+	getUser = `
+		select * from user_account where id = $1
+	`
+
+	getUserByName = `
+		select * from user_account where name = $1
+	`
+
+	listUsers = `
+		select * from user_account
+		order by name
+	`
+
+	deleteUser = `
+		delete from user_account where id = $1
+	`
+
+	createSession = `
+		insert into session (
+			token_hash,
+			user_id,
+			expires_at
+		) values (
+			:token_hash,
+			:user_id,
+			:expires_at
+		)
+	`
+
+	getSessionUser = `
+		select user_account.* from session
+		join user_account on user_account.id = session.user_id
+		where session.token_hash = $1 and session.expires_at > now() at time zone 'utc'
+	`
+
+	deleteSession = `
+		delete from session where token_hash = $1
+	`
+
+	deleteUserSessions = `
+		delete from session where user_id = $1
+	`
+
+	deleteExpiredSessions = `
+		delete from session where expires_at < $1
+	`
+)
+
+func (d *database) CountItemsRequestedSince(userID string, since time.Time) (int, error) {
+	var count int
+	if err := d.countItemsRequestedSince.Get(&count, userID, since); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d *database) CreateUser(user *model.User) (*model.User, error) {
+	var res model.User
+	if err := d.createUser.Get(&res, user); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) UpdateUser(user *model.User) (*model.User, error) {
+	var res model.User
+	if err := d.updateUser.Get(&res, user); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) GetUser(id string) (*model.User, error) {
+	var user model.User
+	if err := d.getUser.Get(&user, id); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *database) GetUserByName(name string) (*model.User, error) {
+	var user model.User
+	if err := d.getUserByName.Get(&user, name); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *database) ListUsers() ([]*model.User, error) {
+	users := []*model.User{}
+	if err := d.listUsers.Select(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *database) DeleteUser(id string) error {
+	if _, err := d.deleteUser.Exec(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) CreateSession(session *model.Session) error {
+	if _, err := d.createSession.Exec(session); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) GetSessionUser(tokenHash string) (*model.User, error) {
+	var user model.User
+	if err := d.getSessionUser.Get(&user, tokenHash); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *database) DeleteSession(tokenHash string) error {
+	if _, err := d.deleteSession.Exec(tokenHash); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions of a user, which logs them out.
+func (d *database) DeleteUserSessions(userID string) error {
+	if _, err := d.deleteUserSessions.Exec(userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes sessions which expired before now and returns
+// their number.
+func (d *database) DeleteExpiredSessions(now time.Time) (int64, error) {
+	res, err := d.deleteExpiredSessions.Exec(now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	createWebhook = `
+		insert into webhook (
+			id,
+			name,
+			url,
+			secret,
+			events,
+			enabled,
+			created_at
+		) values (
+			:id,
+			:name,
+			:url,
+			:secret,
+			coalesce(cast(:events as text[]), '{}'),
+			:enabled,
+			:created_at
+		) returning *
+	`
+
+	updateWebhook = `
+		update webhook set
+			name=:name,
+			url=:url,
+			secret=:secret,
+			events=coalesce(cast(:events as text[]), '{}'),
+			enabled=:enabled
+		where id = :id
+		returning *
+	`
+
+	getWebhook = `
+		select * from webhook where id = $1
+	`
+
+	listWebhooks = `
+		select * from webhook
+		order by name
+	`
+
+	deleteWebhook = `
+		delete from webhook where id = $1
+	`
+
+	addWebhookDelivery = `
+		insert into webhook_delivery (
+			webhook_id,
+			event_id,
+			event_type,
+			payload,
+			attempt,
+			status_code,
+			error,
+			success,
+			date
+		) values (
+			:webhook_id,
+			:event_id,
+			:event_type,
+			:payload,
+			:attempt,
+			:status_code,
+			:error,
+			:success,
+			:date
+		)
+	`
+
+	listWebhookDeliveries = `
+		select * from webhook_delivery
+		where webhook_id = $1
+		order by date desc, id desc
+		offset $2 limit $3
+	`
+
+	deleteWebhookDeliveriesBefore = `
+		delete from webhook_delivery where date < $1
+	`
+)
+
+func (d *database) CreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	var res model.Webhook
+	if err := d.createWebhook.Get(&res, webhook); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) UpdateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	var res model.Webhook
+	if err := d.updateWebhook.Get(&res, webhook); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *database) GetWebhook(id string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := d.getWebhook.Get(&webhook, id); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (d *database) ListWebhooks() ([]*model.Webhook, error) {
+	webhooks := []*model.Webhook{}
+	if err := d.listWebhooks.Select(&webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (d *database) DeleteWebhook(id string) error {
+	if _, err := d.deleteWebhook.Exec(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) AddWebhookDelivery(delivery *model.WebhookDelivery) error {
+	if _, err := d.addWebhookDelivery.Exec(delivery); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *database) ListWebhookDeliveries(webhookID string, offset, count int) ([]*model.WebhookDelivery, error) {
+	deliveries := []*model.WebhookDelivery{}
+	if err := d.listWebhookDeliveries.Select(&deliveries, webhookID, offset, count); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// DeleteWebhookDeliveriesBefore deletes delivery attempts older than before
+// and returns their number.
+func (d *database) DeleteWebhookDeliveriesBefore(before time.Time) (int64, error) {
+	res, err := d.deleteWebhookDeliveriesBefore.Exec(before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
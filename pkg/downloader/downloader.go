@@ -1,5 +1,19 @@
 package downloader
 
+import (
+	"context"
+	"errors"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Downloader downloads a release until it is completed or the context is
+// canceled.
 type Downloader interface {
-	Download(file []byte) error
+	Download(ctx context.Context, release model.Release) error
 }
+
+// ErrWaiting is returned by Download for a download which does not progress
+// until it is started in the download client, like a torrent which was added
+// paused. It is not a failure, the download is checked again later.
+var ErrWaiting = errors.New("download is paused in the download client")
@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathMapping translates a path as seen by a download client into the path
+// under which godarr can access the same location.
+type PathMapping struct {
+	Remote string
+	Local  string
+}
+
+type PathMappings []PathMapping
+
+// Map returns the local path for the given remote path. The mapping with the
+// longest matching remote prefix wins. Paths without a matching mapping are
+// returned unchanged.
+func (m PathMappings) Map(remote string) string {
+	var (
+		best  PathMapping
+		found bool
+	)
+	remote = filepath.Clean(remote)
+	for _, mapping := range m {
+		prefix := filepath.Clean(mapping.Remote)
+		if remote != prefix && !strings.HasPrefix(remote, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+		if !found || len(prefix) > len(filepath.Clean(best.Remote)) {
+			best = mapping
+			found = true
+		}
+	}
+	if !found {
+		return remote
+	}
+	rel, err := filepath.Rel(filepath.Clean(best.Remote), remote)
+	if err != nil {
+		return remote
+	}
+	return filepath.Join(best.Local, rel)
+}
+
+// Check returns an error for each mapping whose local path does not exist or
+// is not a directory.
+func (m PathMappings) Check() []error {
+	var errs []error
+	for _, mapping := range m {
+		info, err := os.Stat(mapping.Local)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("path mapping %s -> %s: %v", mapping.Remote, mapping.Local, err))
+			continue
+		}
+		if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("path mapping %s -> %s: local path is not a directory", mapping.Remote, mapping.Local))
+		}
+	}
+	return errs
+}
@@ -2,59 +2,233 @@ package downloader
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/KnutZuidema/go-qbittorrent"
-	"github.com/KnutZuidema/go-qbittorrent/pkg/model"
+	qbtmodel "github.com/KnutZuidema/go-qbittorrent/pkg/model"
 	"github.com/anacrolix/torrent/metainfo"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
 )
 
+type QBitTorrentCategory struct {
+	Name     string
+	SavePath string
+}
+
+type QBitTorrentOptions struct {
+	// Categories torrents are added to, by the kind of the item they belong to
+	Categories map[model.ItemKind]QBitTorrentCategory
+	// Add torrents in the paused state, their downloads wait until they are
+	// started in qBittorrent
+	Paused bool
+	// Download pieces in sequential order
+	SequentialDownload bool
+	// Prioritize the first and last piece of each file
+	FirstLastPiecePrio bool
+	PathMappings       PathMappings
+	// Bus download progress is published on
+	Events *event.Bus
+}
+
+var DefaultQBitTorrentCategories = map[model.ItemKind]QBitTorrentCategory{
+	model.ItemKindMovie: {
+		Name: "godarr-movies",
+	},
+	model.ItemKindTVSeries: {
+		Name: "godarr-tv",
+	},
+}
+
 type QBitTorrentDownloader struct {
 	logger        log.FieldLogger
-	output        chan<- string
+	output        chan<- model.CompletedDownload
 	client        *qbittorrent.Client
+	username      string
+	password      string
 	checkInterval time.Duration
+	options       QBitTorrentOptions
+
+	mu        sync.Mutex
+	connected bool
 }
 
-func NewQBitTorrentDownloader(username, password, url string, logger log.FieldLogger, output chan<- string, interval time.Duration) (*QBitTorrentDownloader, error) {
+// NewQBitTorrentDownloader creates a downloader which logs in to qBittorrent
+// before its first download, so a client which is not reachable yet is used
+// once it is.
+func NewQBitTorrentDownloader(username, password, url string, options QBitTorrentOptions, logger log.FieldLogger, output chan<- model.CompletedDownload, interval time.Duration) *QBitTorrentDownloader {
 	if logger == nil {
 		logger = log.StandardLogger()
 	}
+	if options.Categories == nil {
+		options.Categories = DefaultQBitTorrentCategories
+	}
 	client := qbittorrent.NewClient(url, logger)
-	if err := client.Login(username, password); err != nil {
-		return nil, err
+	logger = logger.WithField("component", "QBitTorrentDownloader")
+	for _, err := range options.PathMappings.Check() {
+		logger.Warn(err)
 	}
 	return &QBitTorrentDownloader{
-		logger:        logger.WithField("component", "QBitTorrentDownloader"),
+		logger:        logger,
 		client:        client,
+		username:      username,
+		password:      password,
 		output:        output,
 		checkInterval: interval,
-	}, nil
+		options:       options,
+	}
+}
+
+// connect logs in and sets up the categories unless this succeeded before.
+func (d *QBitTorrentDownloader) connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.connected {
+		return nil
+	}
+	if err := d.client.Login(d.username, d.password); err != nil {
+		return fmt.Errorf("login: %v", err)
+	}
+	if err := d.setupCategories(); err != nil {
+		return err
+	}
+	d.connected = true
+	return nil
+}
+
+// disconnect makes the next download log in again, after a request failed
+// because qBittorrent restarted or the session expired, for example.
+func (d *QBitTorrentDownloader) disconnect() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connected = false
+}
+
+func (d *QBitTorrentDownloader) setupCategories() error {
+	existing, err := d.client.Torrent.GetCategories()
+	if err != nil {
+		return err
+	}
+	for _, category := range d.options.Categories {
+		current, ok := existing[category.Name]
+		switch {
+		case !ok:
+			err = d.client.Torrent.AddCategory(category.Name, category.SavePath)
+		case current.SavePath != category.SavePath:
+			err = d.client.Torrent.EditCategory(category.Name, category.SavePath)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("setup category %s: %v", category.Name, err)
+		}
+	}
+	return nil
 }
 
-func (d QBitTorrentDownloader) Download(file []byte) error {
-	info, err := metainfo.Load(bytes.NewBuffer(file))
+func (d *QBitTorrentDownloader) Download(ctx context.Context, release model.Release) error {
+	if err := d.connect(); err != nil {
+		return err
+	}
+	item := release.Item
+	meta, err := metainfo.Load(bytes.NewBuffer(release.Torrent))
+	if err != nil {
+		return err
+	}
+	info, err := meta.UnmarshalInfo()
 	if err != nil {
 		return err
 	}
-	if err := d.client.Torrent.AddFiles(map[string][]byte{uuid.NewV4().String(): file}, &model.AddTorrentsOptions{
-		Category: "godarr",
-	}); err != nil {
+	category, ok := d.options.Categories[item.Kind]
+	if !ok {
+		return fmt.Errorf("no category configured for kind %s", item.Kind)
+	}
+	hash := meta.HashInfoBytes().String()
+	torrent, err := d.torrent(hash)
+	if err != nil {
+		d.disconnect()
 		return err
 	}
+	// a resumed download continues the torrent which was added before
+	if torrent == nil {
+		options := &qbtmodel.AddTorrentsOptions{
+			Category:           category.Name,
+			SequentialDownload: d.options.SequentialDownload,
+			FirstLastPiecePrio: d.options.FirstLastPiecePrio,
+		}
+		if d.options.Paused {
+			options.Paused = strconv.FormatBool(d.options.Paused)
+		}
+		if err := d.client.Torrent.AddFiles(map[string][]byte{uuid.NewV4().String(): release.Torrent}, options); err != nil {
+			d.disconnect()
+			return err
+		}
+	}
 	ticker := time.NewTicker(d.checkInterval)
 	defer ticker.Stop()
-	for range ticker.C {
-		res, err := d.client.Torrent.GetProperties(info.HashInfoBytes().String())
+	piecesHave := -1
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		res, err := d.client.Torrent.GetProperties(hash)
 		if err != nil {
+			d.disconnect()
 			return err
 		}
+		if res.PiecesHave != piecesHave && res.PiecesNum > 0 {
+			piecesHave = res.PiecesHave
+			d.options.Events.Publish(model.EventDownloadProgress, item.ID, model.DownloadProgressData{
+				Release:  release.Title,
+				Progress: float64(res.PiecesHave) / float64(res.PiecesNum),
+			})
+		}
 		if res.PiecesHave == res.PiecesNum {
-			d.output <- res.SavePath
-			break
+			select {
+			case d.output <- model.CompletedDownload{
+				Release: release,
+				Path:    d.options.PathMappings.Map(filepath.Join(res.SavePath, info.Name)),
+			}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		// a paused torrent does not progress until it is started in
+		// qBittorrent, so it is not waited for
+		torrent, err := d.torrent(hash)
+		if err != nil {
+			d.disconnect()
+			return err
+		}
+		if torrent != nil && torrent.State == qbtmodel.StatePausedDL {
+			return ErrWaiting
 		}
 	}
-	return nil
+}
+
+// torrent returns the torrent with the info hash, or nil if qBittorrent does
+// not know it.
+func (d *QBitTorrentDownloader) torrent(hash string) (*qbtmodel.Torrent, error) {
+	torrents, err := d.client.Torrent.GetList(&qbtmodel.GetTorrentListOptions{Hashes: hash})
+	if err != nil {
+		return nil, err
+	}
+	for _, torrent := range torrents {
+		if strings.EqualFold(torrent.Hash, hash) {
+			return torrent, nil
+		}
+	}
+	return nil, nil
 }
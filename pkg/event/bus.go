@@ -0,0 +1,142 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	DefaultBufferSize = 1000
+	subscriptionSize  = 64
+)
+
+// Filter restricts the events a subscription receives. Zero values do not
+// restrict the events.
+type Filter struct {
+	Types  []model.EventType
+	ItemID string
+}
+
+func (f Filter) Matches(event model.Event) bool {
+	if f.ItemID != "" && f.ItemID != event.ItemID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+type Subscription struct {
+	C      <-chan model.Event
+	c      chan model.Event
+	filter Filter
+	bus    *Bus
+	once   sync.Once
+}
+
+// Close unsubscribes from the bus and closes C.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscriptions, s)
+		s.bus.mu.Unlock()
+		close(s.c)
+	})
+}
+
+// Bus distributes events to subscribers and keeps the most recent events in a
+// bounded buffer, so reconnecting subscribers can replay missed events.
+// Publishing never blocks, subscribers which do not keep up lose events.
+type Bus struct {
+	logger log.FieldLogger
+
+	mu            sync.Mutex
+	lastID        int64
+	buffer        []model.Event
+	next          int
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewBus(size int, logger log.FieldLogger) *Bus {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Bus{
+		logger:        logger.WithField("component", "EventBus"),
+		buffer:        make([]model.Event, 0, size),
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish sends an event to all matching subscribers. Publishing on a nil bus
+// does nothing, so components can be used without one.
+func (b *Bus) Publish(eventType model.EventType, itemID string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	event := model.Event{
+		ID:     b.lastID,
+		Type:   eventType,
+		ItemID: itemID,
+		Date:   time.Now().UTC(),
+		Data:   data,
+	}
+	if len(b.buffer) < cap(b.buffer) {
+		b.buffer = append(b.buffer, event)
+	} else {
+		b.buffer[b.next] = event
+		b.next = (b.next + 1) % len(b.buffer)
+	}
+	for s := range b.subscriptions {
+		if !s.filter.Matches(event) {
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			b.logger.WithField("event", event.ID).Warn("subscriber is too slow, dropped event")
+		}
+	}
+}
+
+// Subscribe returns a subscription receiving all future events matching the
+// filter, together with the buffered events newer than lastID. A lastID of 0
+// does not replay any events.
+func (b *Bus) Subscribe(filter Filter, lastID int64) (*Subscription, []model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan model.Event, subscriptionSize)
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		bus:    b,
+	}
+	b.subscriptions[s] = struct{}{}
+	var replay []model.Event
+	if lastID > 0 {
+		for i := range b.buffer {
+			event := b.buffer[(b.next+i)%len(b.buffer)]
+			if event.ID > lastID && filter.Matches(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return s, replay
+}
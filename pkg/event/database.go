@@ -0,0 +1,89 @@
+package event
+
+import (
+	"database/sql"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Database publishes events for changes of items made through the wrapped
+// database.
+type Database struct {
+	database.Database
+	bus *Bus
+}
+
+func NewDatabase(db database.Database, bus *Bus) *Database {
+	return &Database{
+		Database: db,
+		bus:      bus,
+	}
+}
+
+func (d *Database) CreateItem(item *model.Item) (*model.Item, error) {
+	eventType := model.EventItemUpdated
+	if _, err := d.Database.GetItem(item.ID); err == sql.ErrNoRows {
+		eventType = model.EventItemAdded
+	}
+	res, err := d.Database.CreateItem(item)
+	if err != nil {
+		return nil, err
+	}
+	d.bus.Publish(eventType, res.ID, res)
+	return res, nil
+}
+
+func (d *Database) ImportItem(item *model.Item, files []*model.MediaFile) (*model.Item, error) {
+	res, err := d.Database.ImportItem(item, files)
+	if err != nil {
+		return nil, err
+	}
+	d.bus.Publish(model.EventItemAdded, res.ID, res)
+	return res, nil
+}
+
+func (d *Database) UpdateItemMetadata(item *model.Item) (*model.Item, error) {
+	res, err := d.Database.UpdateItemMetadata(item)
+	if err != nil {
+		return nil, err
+	}
+	d.bus.Publish(model.EventItemUpdated, res.ID, res)
+	return res, nil
+}
+
+func (d *Database) SetItemPath(id, path string) error {
+	if err := d.Database.SetItemPath(id, path); err != nil {
+		return err
+	}
+	item, err := d.Database.GetItem(id)
+	if err != nil {
+		return err
+	}
+	d.bus.Publish(model.EventItemUpdated, id, item)
+	return nil
+}
+
+func (d *Database) SetItemStatus(id string, status model.ItemStatus) error {
+	if err := d.Database.SetItemStatus(id, status); err != nil {
+		return err
+	}
+	d.bus.Publish(model.EventStatusChanged, id, model.StatusChangedData{
+		Status: status,
+	})
+	return nil
+}
+
+// DeleteItem deletes an item and publishes it as the data of the event, as it
+// can no longer be fetched afterwards.
+func (d *Database) DeleteItem(id string) error {
+	item, err := d.Database.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if err := d.Database.DeleteItem(id); err != nil {
+		return err
+	}
+	d.bus.Publish(model.EventItemDeleted, id, item)
+	return nil
+}
@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+// Item is an entry of an RSS feed. Torznab and Newznab attributes are parsed
+// into the matching fields and are available by name in Attributes.
+type Item struct {
+	GUID        string
+	Title       string
+	Link        string
+	DownloadURL string
+	Size        int64
+	PublishedAt time.Time
+	TVDbID      string
+	IMDbID      string
+	Seeders     int
+	Attributes  map[string]string
+}
+
+type rss struct {
+	XMLName xml.Name
+	// set if the root element is a Torznab error
+	Code        string `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+	Channel     struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	GUID      string `xml:"guid"`
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	PubDate   string `xml:"pubDate"`
+	Size      string `xml:"size"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length string `xml:"length,attr"`
+	} `xml:"enclosure"`
+	// torznab:attr and newznab:attr
+	Attributes []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+}
+
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+// Parse reads the items of an RSS 2.0 feed, including Torznab feeds. A
+// Torznab error response is returned as error.
+func Parse(r io.Reader) ([]Item, error) {
+	var doc rss
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "rss":
+	case "error":
+		return nil, fmt.Errorf("feed error %s: %s", doc.Code, doc.Description)
+	default:
+		return nil, fmt.Errorf("unexpected root element %q", doc.XMLName.Local)
+	}
+	items := make([]Item, 0, len(doc.Channel.Items))
+	for _, raw := range doc.Channel.Items {
+		item := Item{
+			GUID:        strings.TrimSpace(raw.GUID),
+			Title:       strings.TrimSpace(raw.Title),
+			Link:        strings.TrimSpace(raw.Link),
+			DownloadURL: raw.Enclosure.URL,
+			Attributes:  map[string]string{},
+		}
+		for _, attr := range raw.Attributes {
+			item.Attributes[strings.ToLower(attr.Name)] = attr.Value
+		}
+		if item.DownloadURL == "" {
+			item.DownloadURL = item.Link
+		}
+		if item.GUID == "" {
+			item.GUID = item.DownloadURL
+		}
+		for _, size := range []string{item.Attributes["size"], raw.Size, raw.Enclosure.Length} {
+			if n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64); err == nil && n > 0 {
+				item.Size = n
+				break
+			}
+		}
+		for _, format := range dateFormats {
+			if t, err := time.Parse(format, strings.TrimSpace(raw.PubDate)); err == nil {
+				item.PublishedAt = t.UTC()
+				break
+			}
+		}
+		if id := item.Attributes["tvdbid"]; id != "" && id != "0" {
+			item.TVDbID = id
+		}
+		item.IMDbID = IMDbID(item.Attributes["imdbid"])
+		item.Seeders, _ = strconv.Atoi(item.Attributes["seeders"])
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// IMDbID normalizes an IMDb ID like "133093" or "0133093" to "tt0133093". It
+// returns an empty string for empty or zero IDs.
+func IMDbID(id string) string {
+	id = strings.TrimPrefix(strings.TrimSpace(id), "tt")
+	n, err := strconv.Atoi(id)
+	if err != nil || n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("tt%07d", n)
+}
+
+// Fetch requests the feed at url and parses it.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]Item, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ratelimit.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return Parse(resp.Body)
+}
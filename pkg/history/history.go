@@ -0,0 +1,111 @@
+package history
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// eventTypes maps history events to the events published on the event bus.
+var eventTypes = map[model.HistoryEvent]model.EventType{
+	model.HistoryEventGrabbed:     model.EventGrabbed,
+	model.HistoryEventDownloaded:  model.EventDownloaded,
+	model.HistoryEventImported:    model.EventImported,
+	model.HistoryEventUpgraded:    model.EventUpgraded,
+	model.HistoryEventFailed:      model.EventFailed,
+	model.HistoryEventDeleted:     model.EventFileDeleted,
+	model.HistoryEventItemDeleted: model.EventItemDeleted,
+}
+
+// Recorder records events of items in the history and publishes them on the
+// event bus. Failures to record are logged instead of returned, so they never
+// interrupt the recorded action.
+type Recorder struct {
+	db     database.Database
+	bus    *event.Bus
+	logger log.FieldLogger
+}
+
+func NewRecorder(db database.Database, bus *event.Bus, logger log.FieldLogger) *Recorder {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Recorder{
+		db:     db,
+		bus:    bus,
+		logger: logger.WithField("component", "HistoryRecorder"),
+	}
+}
+
+func (r *Recorder) Grabbed(release model.Release) {
+	r.record(release.Item.ID, model.HistoryEventGrabbed, model.GrabbedData{
+		Indexer: release.Indexer,
+		Release: release.Title,
+		Size:    release.Size,
+		Quality: release.Quality,
+	})
+}
+
+func (r *Recorder) Downloaded(download model.CompletedDownload) {
+	r.record(download.Release.Item.ID, model.HistoryEventDownloaded, model.DownloadedData{
+		Release: download.Release.Title,
+		Path:    download.Path,
+	})
+}
+
+func (r *Recorder) Imported(itemID, source, destination string, quality model.Quality) {
+	r.record(itemID, model.HistoryEventImported, model.ImportedData{
+		Source:      source,
+		Destination: destination,
+		Quality:     quality,
+	})
+}
+
+func (r *Recorder) Upgraded(itemID string, old *model.MediaFile, path string, quality model.Quality) {
+	r.record(itemID, model.HistoryEventUpgraded, model.UpgradedData{
+		OldPath:    old.Path,
+		OldQuality: old.Quality,
+		NewPath:    path,
+		NewQuality: quality,
+	})
+}
+
+func (r *Recorder) Failed(itemID, stage, release string, err error) {
+	r.record(itemID, model.HistoryEventFailed, model.FailedData{
+		Stage:   stage,
+		Release: release,
+		Error:   err.Error(),
+	})
+}
+
+func (r *Recorder) Deleted(itemID, path, reason string) {
+	r.record(itemID, model.HistoryEventDeleted, model.DeletedData{
+		Path:   path,
+		Reason: reason,
+	})
+}
+
+func (r *Recorder) record(itemID string, event model.HistoryEvent, data interface{}) {
+	r.bus.Publish(eventTypes[event], itemID, data)
+	logger := r.logger.WithFields(log.Fields{
+		"item":  itemID,
+		"event": event,
+	})
+	payload, err := model.NewJSON(data)
+	if err != nil {
+		logger.Error("encode history data: ", err)
+		return
+	}
+	if err := r.db.AddHistory(&model.HistoryEntry{
+		ItemID: itemID,
+		Event:  event,
+		Date:   time.Now().UTC(),
+		Data:   payload,
+	}); err != nil {
+		logger.Error("record history: ", err)
+	}
+}
@@ -0,0 +1,183 @@
+package library
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+var (
+	titleYearRegexp  = regexp.MustCompile(`^(.+?)[ ._\-(\[]+((?:19|20)\d{2})(?:[)\]]|[ ._\-]|$)`)
+	episodeRegexp    = regexp.MustCompile(`(?i)(?:^|[ ._\-])s(\d{1,2})[ ._\-]?e(\d{1,3})|(?:^|[ ._\-])(\d{1,2})x(\d{2,3})(?:[ ._\-]|$)`)
+	seasonRegexp     = regexp.MustCompile(`(?i)^(?:season|series|s)[ ._\-]?(\d{1,2})$`)
+	seasonPackRegexp = regexp.MustCompile(`(?i)(?:^|[ ._\-])s(\d{1,2})(?:[ ._\-]|$)`)
+	separatorRegexp  = regexp.MustCompile(`[._]+`)
+	bracketRegexp    = regexp.MustCompile(`[\[(].*?[\])]`)
+	groupRegexp      = regexp.MustCompile(`-([a-zA-Z0-9]+)$`)
+	qualityRegexps   = []struct {
+		regexp  *regexp.Regexp
+		quality string
+	}{
+		{regexp.MustCompile(`(?i)\bremux\b`), "Remux"},
+		{regexp.MustCompile(`(?i)\b(?:blu-?ray|bdrip|brrip|bd(?:25|50|5|9))\b`), "Bluray"},
+		{regexp.MustCompile(`(?i)\bweb(?:-?dl|-?rip)?\b`), "WEB"},
+		{regexp.MustCompile(`(?i)\b(?:hdtv|pdtv|dsr)\b`), "HDTV"},
+		{regexp.MustCompile(`(?i)\b(?:dvd(?:rip|r|5|9)?)\b`), "DVD"},
+	}
+	resolutionRegexp = regexp.MustCompile(`(?i)\b(2160p|4k|1080[pi]|720p|576p|480p)\b`)
+
+	videoExtensions = map[string]bool{
+		".avi":  true,
+		".m2ts": true,
+		".m4v":  true,
+		".mkv":  true,
+		".mov":  true,
+		".mp4":  true,
+		".mpeg": true,
+		".mpg":  true,
+		".ts":   true,
+		".wmv":  true,
+	}
+)
+
+func IsVideo(name string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// parseTitle infers a title and an optional release year from a folder or
+// file name like "The Matrix (1999)" or "The.Matrix.1999.1080p.BluRay".
+func parseTitle(name string) (string, int) {
+	if IsVideo(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if match := titleYearRegexp.FindStringSubmatch(name); match != nil {
+		year, _ := strconv.Atoi(match[2])
+		return cleanTitle(match[1]), year
+	}
+	return cleanTitle(bracketRegexp.ReplaceAllString(name, "")), 0
+}
+
+// ParseEpisode extracts season and episode numbers from a file name like
+// "Show.S01E02.720p.mkv" or "Show - 1x02.mkv".
+func ParseEpisode(name string) (season, episode int, ok bool) {
+	match := episodeRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return 0, 0, false
+	}
+	if match[1] != "" {
+		season, _ = strconv.Atoi(match[1])
+		episode, _ = strconv.Atoi(match[2])
+	} else {
+		season, _ = strconv.Atoi(match[3])
+		episode, _ = strconv.Atoi(match[4])
+	}
+	return season, episode, true
+}
+
+// ParseReleaseTitle infers the title and an optional year of the movie or
+// series of a release name like "Show.Name.S01E02.720p.HDTV-GROUP",
+// "Show.Name.S01.1080p.WEB-GROUP" or "Movie.Title.1999.1080p.BluRay-GROUP".
+func ParseReleaseTitle(name string) (string, int) {
+	if loc := episodeRegexp.FindStringIndex(name); loc != nil {
+		name = name[:loc[0]]
+	} else if loc := seasonPackRegexp.FindStringIndex(name); loc != nil {
+		name = name[:loc[0]]
+	}
+	return parseTitle(name)
+}
+
+// ParseSeasonPack extracts the season number of a release of a whole season
+// like "Show.Name.S01.1080p.WEB-GROUP".
+func ParseSeasonPack(name string) (int, bool) {
+	if episodeRegexp.MatchString(name) {
+		return 0, false
+	}
+	match := seasonPackRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	season, _ := strconv.Atoi(match[1])
+	return season, true
+}
+
+// SameTitle reports whether two titles are equal regardless of case and
+// punctuation.
+func SameTitle(a, b string) bool {
+	return normalizeTitle(a) == normalizeTitle(b)
+}
+
+// parseSeason extracts the season number from a folder name like "Season 01".
+func parseSeason(name string) (int, bool) {
+	if strings.EqualFold(name, "specials") {
+		return 0, true
+	}
+	match := seasonRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	season, _ := strconv.Atoi(match[1])
+	return season, true
+}
+
+func cleanTitle(title string) string {
+	title = separatorRegexp.ReplaceAllString(title, " ")
+	return strings.Join(strings.Fields(strings.Trim(title, " -")), " ")
+}
+
+// normalizeTitle reduces a title to lower case letters and digits, so titles
+// can be compared regardless of punctuation.
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ParseQuality infers the quality of a release from its name, combining
+// source and resolution like "Bluray-1080p".
+func ParseQuality(name string) model.Quality {
+	source := ""
+	for _, q := range qualityRegexps {
+		if q.regexp.MatchString(name) {
+			source = q.quality
+			break
+		}
+	}
+	resolution := strings.ToLower(resolutionRegexp.FindString(name))
+	switch resolution {
+	case "4k":
+		resolution = "2160p"
+	case "1080i":
+		resolution = "1080p"
+	case "576p":
+		resolution = "480p"
+	}
+	switch {
+	case source != "" && resolution != "":
+		return model.Quality(source + "-" + resolution)
+	case source != "":
+		return model.Quality(source)
+	case resolution != "":
+		return model.Quality("Unknown-" + resolution)
+	}
+	return model.QualityUnknown
+}
+
+// ParseReleaseGroup returns the group suffix of a scene style release name
+// like "Show.S01E02.720p.HDTV.x264-GROUP".
+func ParseReleaseGroup(name string) string {
+	if IsVideo(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if match := groupRegexp.FindStringSubmatch(name); match != nil {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+const (
+	// refreshPageSize is the number of items loaded at once during a refresh.
+	refreshPageSize = 100
+	// changesWindow is how far back providers list changes, items refreshed
+	// before it are refreshed regardless of changes.
+	changesWindow = 14 * 24 * time.Hour
+)
+
+// Refresher updates title, description, image, release year, genres and
+// rating of items from their providers. Only items which changed at the
+// provider since their last refresh are fetched again, unless their last
+// refresh is older than the maximum age.
+type Refresher struct {
+	db        database.Database
+	providers map[model.ItemKind]provider.Provider
+	maxAge    time.Duration
+	logger    log.FieldLogger
+}
+
+// NewRefresher returns a refresher which refreshes items regardless of
+// changes once their metadata is older than maxAge.
+func NewRefresher(db database.Database, providers map[model.ItemKind]provider.Provider, maxAge time.Duration, logger log.FieldLogger) *Refresher {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Refresher{
+		db:        db,
+		providers: providers,
+		maxAge:    maxAge,
+		logger:    logger.WithField("component", "MetadataRefresher"),
+	}
+}
+
+// RefreshChanged refreshes the items which changed at their provider since
+// their last refresh and the items whose last refresh is older than the
+// maximum age. Items which fail to refresh are logged and skipped, the error
+// only reports their number.
+func (r *Refresher) RefreshChanged(ctx context.Context) error {
+	now := time.Now().UTC()
+	var items []*model.Item
+	for offset := 0; ; offset += refreshPageSize {
+		page, err := r.db.ListItems(model.ItemFilter{}, offset, refreshPageSize)
+		if err != nil {
+			return err
+		}
+		items = append(items, page...)
+		if len(page) < refreshPageSize {
+			break
+		}
+	}
+	// the changes are requested since the oldest refresh of the items which
+	// are not due anyway
+	since := map[model.ItemKind]time.Time{}
+	for _, item := range items {
+		if r.due(item, now) {
+			continue
+		}
+		if oldest, ok := since[item.Kind]; !ok || item.RefreshedAt.Before(oldest) {
+			since[item.Kind] = *item.RefreshedAt
+		}
+	}
+	for kind, t := range since {
+		if earliest := now.Add(-changesWindow); t.Before(earliest) {
+			since[kind] = earliest
+		}
+	}
+	changed := map[model.ItemKind]map[string]bool{}
+	for kind, t := range since {
+		ids, err := r.changes(kind, t)
+		if err != nil {
+			// without changes every item of the kind is refreshed
+			r.logger.WithField("kind", kind).Warn("list changes: ", err)
+			continue
+		}
+		changed[kind] = map[string]bool{}
+		for _, id := range ids {
+			changed[kind][id] = true
+		}
+	}
+	refreshed, failed := 0, 0
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if ids, ok := changed[item.Kind]; ok && !r.due(item, now) && !ids[item.ExternalIDs.TMDB] {
+			continue
+		}
+		if err := r.Refresh(item); err != nil {
+			r.logger.WithField("item", item.ID).Warn("refresh: ", err)
+			failed++
+			continue
+		}
+		refreshed++
+	}
+	r.logger.WithFields(log.Fields{
+		"refreshed": refreshed,
+		"failed":    failed,
+	}).Info("refreshed metadata")
+	if failed > 0 {
+		return fmt.Errorf("%d items could not be refreshed", failed)
+	}
+	return nil
+}
+
+// due reports whether an item has to be refreshed regardless of changes,
+// since it was never refreshed, its TMDb ID is unknown or its metadata is
+// older than the maximum age or the changes window.
+func (r *Refresher) due(item *model.Item, now time.Time) bool {
+	if item.RefreshedAt == nil || item.ExternalIDs.TMDB == "" {
+		return true
+	}
+	age := now.Sub(*item.RefreshedAt)
+	return age > r.maxAge || age > changesWindow
+}
+
+func (r *Refresher) changes(kind model.ItemKind, since time.Time) ([]string, error) {
+	p, ok := r.providers[kind]
+	if !ok {
+		return nil, fmt.Errorf("no provider for kind %s", kind)
+	}
+	lister, ok := p.(provider.ChangeLister)
+	if !ok {
+		return nil, fmt.Errorf("provider of kind %s can not list changes", kind)
+	}
+	return lister.Changes(since)
+}
+
+// Refresh fetches the current metadata of an item and stores it. Only the
+// metadata is written, other fields of the item are not overwritten.
+func (r *Refresher) Refresh(item *model.Item) error {
+	p, ok := r.providers[item.Kind]
+	if !ok {
+		return fmt.Errorf("no provider for kind %s", item.Kind)
+	}
+	refresher, ok := p.(provider.Refresher)
+	if !ok {
+		return fmt.Errorf("provider of kind %s can not refresh items", item.Kind)
+	}
+	current, err := refresher.Refresh(item)
+	if err != nil {
+		return err
+	}
+	item.Title = current.Title
+	item.Description = current.Description
+	item.ImagePath = current.ImagePath
+	item.ReleaseYear = current.ReleaseYear
+	item.Genres = current.Genres
+	item.Rating = current.Rating
+	item.MetadataSources = current.MetadataSources
+	// the provider knows the current IDs, stored ones only fill gaps
+	ids := current.ExternalIDs
+	ids.Merge(item.ExternalIDs)
+	item.ExternalIDs = ids
+	now := time.Now().UTC()
+	item.RefreshedAt = &now
+	_, err = r.db.UpdateItemMetadata(item)
+	return err
+}
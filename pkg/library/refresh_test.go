@@ -0,0 +1,94 @@
+package library
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+// refreshDB lists items from memory and records metadata updates, other
+// methods of the database are not used by the refresher.
+type refreshDB struct {
+	database.Database
+	items   []*model.Item
+	updated []*model.Item
+}
+
+func (d *refreshDB) ListItems(filter model.ItemFilter, offset, count int) ([]*model.Item, error) {
+	if offset >= len(d.items) {
+		return nil, nil
+	}
+	return d.items[offset:], nil
+}
+
+func (d *refreshDB) UpdateItemMetadata(item *model.Item) (*model.Item, error) {
+	d.updated = append(d.updated, item)
+	return item, nil
+}
+
+// refreshProvider returns the items with a new title and records the time
+// changes were requested since.
+type refreshProvider struct {
+	provider.Provider
+	changed []string
+	since   time.Time
+}
+
+func (p *refreshProvider) Refresh(item *model.Item) (*model.Item, error) {
+	return &model.Item{Title: item.Title + " (refreshed)", ExternalIDs: item.ExternalIDs}, nil
+}
+
+func (p *refreshProvider) Changes(since time.Time) ([]string, error) {
+	p.since = since
+	return p.changed, nil
+}
+
+func TestRefreshChanged(t *testing.T) {
+	now := time.Now().UTC()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	day := 24 * time.Hour
+	db := &refreshDB{
+		items: []*model.Item{
+			{ID: "never", Kind: model.ItemKindMovie, ExternalIDs: model.ExternalIDs{TMDB: "1"}},
+			{ID: "changed", Kind: model.ItemKindMovie, RefreshedAt: ago(day), ExternalIDs: model.ExternalIDs{TMDB: "2"}},
+			{ID: "unchanged", Kind: model.ItemKindMovie, RefreshedAt: ago(2 * day), ExternalIDs: model.ExternalIDs{TMDB: "3"}},
+			{ID: "outside-window", Kind: model.ItemKindMovie, RefreshedAt: ago(20 * day), ExternalIDs: model.ExternalIDs{TMDB: "4"}},
+			{ID: "without-tmdb", Kind: model.ItemKindMovie, RefreshedAt: ago(day)},
+		},
+	}
+	p := &refreshProvider{changed: []string{"2"}}
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	// a maximum age beyond the changes window checks that it is clamped
+	refresher := NewRefresher(db, map[model.ItemKind]provider.Provider{model.ItemKindMovie: p}, 30*day, logger)
+	if err := refresher.RefreshChanged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if earliest := now.Add(-changesWindow); p.since.Before(earliest) {
+		t.Errorf("changes requested since %s, before the window starting %s", p.since, earliest)
+	}
+	var updated []string
+	for _, item := range db.updated {
+		updated = append(updated, item.ID)
+		if item.RefreshedAt == nil || item.RefreshedAt.Before(now) {
+			t.Errorf("%s: refreshed at %v", item.ID, item.RefreshedAt)
+		}
+	}
+	sort.Strings(updated)
+	expected := []string{"changed", "never", "outside-window", "without-tmdb"}
+	if !reflect.DeepEqual(updated, expected) {
+		t.Errorf("updated %v, expected %v", updated, expected)
+	}
+}
@@ -0,0 +1,117 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Rescanner checks known media files against the file system to detect files
+// that were deleted or changed outside of godarr.
+type Rescanner struct {
+	db      database.Database
+	history *history.Recorder
+	logger  log.FieldLogger
+}
+
+func NewRescanner(db database.Database, history *history.Recorder, logger log.FieldLogger) *Rescanner {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Rescanner{
+		db:      db,
+		history: history,
+		logger:  logger.WithField("component", "LibraryRescanner"),
+	}
+}
+
+// Rescan removes media files which no longer exist and updates the size and
+// modification time of changed ones. Items without any remaining media file
+// are monitored again.
+//
+// Missing files are only removed if the library root containing their item
+// still exists, so an unmounted library does not remove every file. The run
+// is aborted if the root is missing. Files of an item whose whole folder was
+// deleted are removed like single deleted files.
+func (r *Rescanner) Rescan() error {
+	files, err := r.db.ListAllMediaFiles()
+	if err != nil {
+		return err
+	}
+	remaining := map[string]int{}
+	for _, file := range files {
+		remaining[file.ItemID]++
+	}
+	roots := map[string]error{}
+	for _, file := range files {
+		logger := r.logger.WithFields(log.Fields{
+			"item": file.ItemID,
+			"path": file.Path,
+		})
+		info, err := os.Stat(file.Path)
+		if os.IsNotExist(err) {
+			root, err := r.libraryRoot(file)
+			if err != nil {
+				return err
+			}
+			unavailable, checked := roots[root]
+			if !checked {
+				if _, err := os.Stat(root); err != nil {
+					unavailable = fmt.Errorf("library root %s is not available: %v", root, err)
+				}
+				roots[root] = unavailable
+			}
+			if unavailable != nil {
+				return unavailable
+			}
+			logger.Warn("media file was deleted")
+			if err := r.db.DeleteMediaFile(file.ID); err != nil {
+				return err
+			}
+			r.history.Deleted(file.ItemID, file.Path, "file was deleted outside of godarr")
+			remaining[file.ItemID]--
+			if remaining[file.ItemID] == 0 {
+				if err := r.db.SetItemStatus(file.ItemID, model.ItemStatusMonitored); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			logger.Error("stat media file: ", err)
+			continue
+		}
+		modified := info.ModTime().UTC().Truncate(time.Microsecond)
+		if info.Size() == file.Size && modified.Equal(file.ModifiedAt) {
+			continue
+		}
+		logger.Warn("media file was changed")
+		file.Size = info.Size()
+		file.ModifiedAt = modified
+		if err := r.db.UpdateMediaFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// libraryRoot returns the library root containing the folder of the item of
+// a media file.
+func (r *Rescanner) libraryRoot(file *model.MediaFile) (string, error) {
+	dir := filepath.Dir(file.Path)
+	item, err := r.db.GetItem(file.ItemID)
+	if err != nil {
+		return "", err
+	}
+	if item.Path != "" {
+		dir = item.Path
+	}
+	return filepath.Dir(dir), nil
+}
@@ -0,0 +1,126 @@
+package library
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// rescanDB serves items and media files from memory and records deletions
+// and status changes, other methods of the database are not used by the
+// rescanner.
+type rescanDB struct {
+	database.Database
+	items    map[string]*model.Item
+	files    []*model.MediaFile
+	deleted  []string
+	statuses map[string]model.ItemStatus
+	history  []*model.HistoryEntry
+}
+
+func (d *rescanDB) GetItem(id string) (*model.Item, error) {
+	return d.items[id], nil
+}
+
+func (d *rescanDB) ListAllMediaFiles() ([]*model.MediaFile, error) {
+	return d.files, nil
+}
+
+func (d *rescanDB) DeleteMediaFile(id string) error {
+	d.deleted = append(d.deleted, id)
+	return nil
+}
+
+func (d *rescanDB) SetItemStatus(id string, status model.ItemStatus) error {
+	d.statuses[id] = status
+	return nil
+}
+
+func (d *rescanDB) AddHistory(entry *model.HistoryEntry) error {
+	d.history = append(d.history, entry)
+	return nil
+}
+
+func newTestRescanner(db database.Database) *Rescanner {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return NewRescanner(db, history.NewRecorder(db, nil, logger), logger)
+}
+
+func TestRescan(t *testing.T) {
+	root, err := ioutil.TempDir("", "godarr-rescan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	heat := filepath.Join(root, "Heat (1995)")
+	if err := os.Mkdir(heat, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db := &rescanDB{
+		items: map[string]*model.Item{
+			"heat":   {ID: "heat", Path: heat},
+			"ronin":  {ID: "ronin", Path: filepath.Join(root, "Ronin (1998)")},
+			"legacy": {ID: "legacy"},
+		},
+		files: []*model.MediaFile{
+			{ID: "heat-deleted", ItemID: "heat", Path: filepath.Join(heat, "Heat.mkv")},
+			{ID: "ronin-missing", ItemID: "ronin", Path: filepath.Join(root, "Ronin (1998)", "Ronin.mkv")},
+			{ID: "legacy-deleted", ItemID: "legacy", Path: filepath.Join(heat, "Legacy.mkv")},
+		},
+		statuses: map[string]model.ItemStatus{},
+	}
+	if err := newTestRescanner(db).Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(db.deleted)
+	// the folder of ronin was deleted as a whole under an existing root
+	if expected := []string{"heat-deleted", "legacy-deleted", "ronin-missing"}; !reflect.DeepEqual(db.deleted, expected) {
+		t.Errorf("deleted %v, expected %v", db.deleted, expected)
+	}
+	expected := map[string]model.ItemStatus{
+		"heat":   model.ItemStatusMonitored,
+		"legacy": model.ItemStatusMonitored,
+		"ronin":  model.ItemStatusMonitored,
+	}
+	if !reflect.DeepEqual(db.statuses, expected) {
+		t.Errorf("statuses %v, expected %v", db.statuses, expected)
+	}
+	deletions := map[string]bool{}
+	for _, entry := range db.history {
+		if entry.Event == model.HistoryEventDeleted {
+			deletions[entry.ItemID] = true
+		}
+	}
+	if !deletions["ronin"] || len(deletions) != 3 {
+		t.Errorf("deletions recorded for %v, expected heat, legacy and ronin", deletions)
+	}
+}
+
+func TestRescanMissingRoot(t *testing.T) {
+	root := filepath.Join(os.TempDir(), "godarr-rescan-unmounted")
+	db := &rescanDB{
+		items: map[string]*model.Item{
+			"heat": {ID: "heat", Path: filepath.Join(root, "Heat (1995)")},
+		},
+		files: []*model.MediaFile{
+			{ID: "heat", ItemID: "heat", Path: filepath.Join(root, "Heat (1995)", "Heat.mkv")},
+		},
+		statuses: map[string]model.ItemStatus{},
+	}
+	if err := newTestRescanner(db).Rescan(); err == nil {
+		t.Error("rescan without library root did not fail")
+	}
+	if len(db.deleted) > 0 || len(db.statuses) > 0 {
+		t.Errorf("deleted %v and changed %v without library root", db.deleted, db.statuses)
+	}
+}
@@ -0,0 +1,201 @@
+package library
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/provider"
+)
+
+const maxMatches = 5
+
+type File struct {
+	Path          string `json:"path"`
+	Size          int64  `json:"size"`
+	SeasonNumber  int    `json:"seasonNumber,omitempty"`
+	EpisodeNumber int    `json:"episodeNumber,omitempty"`
+}
+
+// Candidate is an item found on disk together with the provider items it
+// possibly refers to.
+type Candidate struct {
+	Path      string         `json:"path"`
+	Kind      model.ItemKind `json:"kind"`
+	Title     string         `json:"title"`
+	Year      int            `json:"year,omitempty"`
+	Files     []File         `json:"files"`
+	Matches   []*model.Item  `json:"matches"`
+	Confident bool           `json:"confident"`
+}
+
+type Scanner struct {
+	providers map[model.ItemKind]provider.Provider
+	logger    log.FieldLogger
+}
+
+func NewScanner(providers map[model.ItemKind]provider.Provider, logger log.FieldLogger) *Scanner {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Scanner{
+		providers: providers,
+		logger:    logger.WithField("component", "LibraryScanner"),
+	}
+}
+
+// Scan walks the direct children of root, which is expected to contain items
+// of the given kind, and matches each of them against the provider for that
+// kind.
+func (s *Scanner) Scan(root string, kind model.ItemKind) ([]*Candidate, error) {
+	p, ok := s.providers[kind]
+	if !ok {
+		return nil, fmt.Errorf("no provider for kind %s", kind)
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*Candidate
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		var candidate *Candidate
+		switch kind {
+		case model.ItemKindMovie:
+			candidate, err = scanMovie(path, entry)
+		case model.ItemKindTVSeries:
+			candidate, err = scanSeries(path, entry)
+		default:
+			return nil, fmt.Errorf("invalid kind: %v", kind)
+		}
+		if err != nil {
+			s.logger.WithField("path", path).Warn("scan: ", err)
+			continue
+		}
+		if candidate == nil {
+			continue
+		}
+		if err := match(p, candidate); err != nil {
+			s.logger.WithField("path", path).Warn("match: ", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+func scanMovie(path string, info os.FileInfo) (*Candidate, error) {
+	title, year := parseTitle(info.Name())
+	candidate := &Candidate{
+		Path:  path,
+		Kind:  model.ItemKindMovie,
+		Title: title,
+		Year:  year,
+	}
+	if !info.IsDir() {
+		if !IsVideo(info.Name()) {
+			return nil, nil
+		}
+		candidate.Files = []File{{Path: path, Size: info.Size()}}
+		return candidate, nil
+	}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && IsVideo(info.Name()) {
+			candidate.Files = append(candidate.Files, File{Path: file, Size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidate.Files) == 0 {
+		return nil, nil
+	}
+	return candidate, nil
+}
+
+func scanSeries(path string, info os.FileInfo) (*Candidate, error) {
+	if !info.IsDir() {
+		return nil, nil
+	}
+	title, year := parseTitle(info.Name())
+	candidate := &Candidate{
+		Path:  path,
+		Kind:  model.ItemKindTVSeries,
+		Title: title,
+		Year:  year,
+	}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !IsVideo(info.Name()) {
+			return nil
+		}
+		season, episode, ok := ParseEpisode(info.Name())
+		if !ok {
+			return nil
+		}
+		if folderSeason, ok := parseSeason(filepath.Base(filepath.Dir(file))); ok && folderSeason != season {
+			return nil
+		}
+		candidate.Files = append(candidate.Files, File{
+			Path:          file,
+			Size:          info.Size(),
+			SeasonNumber:  season,
+			EpisodeNumber: episode,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidate.Files) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidate.Files, func(i, j int) bool {
+		a, b := candidate.Files[i], candidate.Files[j]
+		if a.SeasonNumber != b.SeasonNumber {
+			return a.SeasonNumber < b.SeasonNumber
+		}
+		return a.EpisodeNumber < b.EpisodeNumber
+	})
+	return candidate, nil
+}
+
+// match searches the provider for the candidate's title. A candidate is
+// confident if exactly one result has the same title and, if known, the same
+// release year.
+func match(p provider.Provider, candidate *Candidate) error {
+	results, err := p.ListBySearch(candidate.Title)
+	if err != nil {
+		return err
+	}
+	var exact []*model.Item
+	for _, result := range results {
+		if normalizeTitle(result.Title) != normalizeTitle(candidate.Title) {
+			continue
+		}
+		if candidate.Year != 0 && result.ReleaseYear != candidate.Year {
+			continue
+		}
+		exact = append(exact, result)
+	}
+	if len(exact) == 1 {
+		candidate.Matches = exact
+		candidate.Confident = true
+		return nil
+	}
+	if len(results) > maxMatches {
+		results = results[:maxMatches]
+	}
+	candidate.Matches = results
+	return nil
+}
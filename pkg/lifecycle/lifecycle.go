@@ -0,0 +1,132 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Manager starts the HTTP servers and background workers of godarr and stops
+// them in order when the process is asked to terminate:
+//
+//  1. servers stop accepting connections and drain in-flight requests
+//  2. workers are signaled to stop and awaited
+//  3. shutdown hooks run in the order they were added, like canceling
+//     monitors, persisting jobs and closing the database
+//
+// All steps share a single deadline.
+type Manager struct {
+	logger log.FieldLogger
+
+	servers []*http.Server
+	errs    chan error
+	stop    chan struct{}
+	workers sync.WaitGroup
+	hooks   []hook
+}
+
+type hook struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+func NewManager(logger log.FieldLogger) *Manager {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Manager{
+		logger: logger.WithField("component", "Lifecycle"),
+		errs:   make(chan error, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Go runs a worker in the background until stop is closed.
+func (m *Manager) Go(name string, run func(stop <-chan struct{})) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		run(m.stop)
+		m.logger.WithField("worker", name).Debug("stopped")
+	}()
+}
+
+// Serve starts listening with the server in the background. Run returns if
+// the server fails.
+func (m *Manager) Serve(server *http.Server) {
+	m.servers = append(m.servers, server)
+	go func() {
+		m.logger.Infof("Listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			select {
+			case m.errs <- fmt.Errorf("serve %s: %v", server.Addr, err):
+			default:
+			}
+		}
+	}()
+}
+
+// OnShutdown adds a hook which runs after servers and workers stopped.
+func (m *Manager) OnShutdown(name string, f func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, f: f})
+}
+
+// Run blocks until one of the signals is received or a server fails and
+// shuts down within the timeout afterwards.
+func (m *Manager) Run(timeout time.Duration, signals ...os.Signal) error {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+	var err error
+	select {
+	case sig := <-received:
+		m.logger.Infof("received %s, shutting down", sig)
+	case err = <-m.errs:
+		m.logger.Error(err, ", shutting down")
+	}
+	if shutdownErr := m.Shutdown(timeout); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// Shutdown stops servers and workers and runs the shutdown hooks. Every step
+// is attempted even if a previous one failed or the deadline passed.
+func (m *Manager) Shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var errs []string
+	for _, server := range m.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("shutdown server %s: %v", server.Addr, err))
+		}
+	}
+	close(m.stop)
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, "workers did not stop before the deadline")
+	}
+	for _, hook := range m.hooks {
+		if err := hook.f(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", hook.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	m.logger.Info("shut down")
+	return nil
+}
@@ -0,0 +1,142 @@
+package maintenance
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	backupPrefix     = "godarr-backup-"
+	backupExtension  = ".json.gz"
+	backupTimeFormat = "20060102T150405Z"
+	backupPageSize   = 500
+)
+
+// Snapshot is the content of a backup. Secrets of webhooks and credentials of
+// users are not part of it.
+type Snapshot struct {
+	CreatedAt       time.Time               `json:"createdAt"`
+	Items           []*model.Item           `json:"items"`
+	MediaFiles      []*model.MediaFile      `json:"mediaFiles"`
+	QualityProfiles []*model.QualityProfile `json:"qualityProfiles"`
+	Components      []*model.Component      `json:"components"`
+	Webhooks        []*model.Webhook        `json:"webhooks"`
+}
+
+// Backuper writes gzip compressed JSON snapshots of the database into a
+// folder and keeps only the most recent ones.
+type Backuper struct {
+	db     database.Database
+	dir    string
+	keep   int
+	logger log.FieldLogger
+}
+
+func NewBackuper(db database.Database, dir string, keep int, logger log.FieldLogger) *Backuper {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Backuper{
+		db:     db,
+		dir:    dir,
+		keep:   keep,
+		logger: logger.WithField("component", "Backuper"),
+	}
+}
+
+func (b *Backuper) Backup(ctx context.Context) error {
+	snapshot, err := b.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(b.dir, backupPrefix+snapshot.CreatedAt.Format(backupTimeFormat)+backupExtension)
+	if err := writeSnapshot(path, snapshot); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	b.logger.WithField("path", path).Info("created backup")
+	return b.prune()
+}
+
+func (b *Backuper) snapshot(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		CreatedAt: time.Now().UTC(),
+	}
+	for offset := 0; ; offset += backupPageSize {
+		items, err := b.db.ListItems(model.ItemFilter{}, offset, backupPageSize)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Items = append(snapshot.Items, items...)
+		if len(items) < backupPageSize {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	var err error
+	if snapshot.MediaFiles, err = b.db.ListAllMediaFiles(); err != nil {
+		return nil, err
+	}
+	if snapshot.QualityProfiles, err = b.db.ListQualityProfiles(); err != nil {
+		return nil, err
+	}
+	if snapshot.Components, err = b.db.ListComponents(); err != nil {
+		return nil, err
+	}
+	if snapshot.Webhooks, err = b.db.ListWebhooks(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func writeSnapshot(path string, snapshot *Snapshot) (err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	writer := gzip.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// prune deletes all but the most recent backups.
+func (b *Backuper) prune() error {
+	paths, err := filepath.Glob(filepath.Join(b.dir, backupPrefix+"*"+backupExtension))
+	if err != nil {
+		return err
+	}
+	if len(paths) <= b.keep {
+		return nil
+	}
+	// the timestamp in the name sorts chronologically
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-b.keep] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		b.logger.WithField("path", path).Debug("deleted old backup")
+	}
+	return nil
+}
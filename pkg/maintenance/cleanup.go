@@ -0,0 +1,57 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+)
+
+// Cleaner deletes expired sessions and provider responses and webhook
+// deliveries older than the retention.
+type Cleaner struct {
+	db        database.Database
+	retention time.Duration
+	logger    log.FieldLogger
+}
+
+func NewCleaner(db database.Database, retention time.Duration, logger log.FieldLogger) *Cleaner {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &Cleaner{
+		db:        db,
+		retention: retention,
+		logger:    logger.WithField("component", "Cleaner"),
+	}
+}
+
+func (c *Cleaner) Clean(ctx context.Context) error {
+	now := time.Now().UTC()
+	sessions, err := c.db.DeleteExpiredSessions(now)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	deliveries, err := c.db.DeleteWebhookDeliveriesBefore(now.Add(-c.retention))
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	responses, err := c.db.DeleteExpiredProviderCache(now)
+	if err != nil {
+		return err
+	}
+	c.logger.WithFields(log.Fields{
+		"sessions":   sessions,
+		"deliveries": deliveries,
+		"responses":  responses,
+	}).Info("cleaned up")
+	return nil
+}
@@ -0,0 +1,12 @@
+package model
+
+import (
+	"time"
+)
+
+type APIKey struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Hash      string    `json:"-" db:"hash"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+)
+
+type ComponentCategory string
+
+const (
+	ComponentCategoryIndexer        ComponentCategory = "indexer"
+	ComponentCategoryDownloadClient ComponentCategory = "downloadClient"
+	ComponentCategoryProvider       ComponentCategory = "provider"
+	ComponentCategoryNotification   ComponentCategory = "notification"
+)
+
+func (c ComponentCategory) Valid() bool {
+	switch c {
+	case ComponentCategoryIndexer, ComponentCategoryDownloadClient, ComponentCategoryProvider, ComponentCategoryNotification:
+		return true
+	}
+	return false
+}
+
+// Component is the definition of an indexer, download client, provider or
+// notification, which is instantiated at runtime. The settings depend on the
+// type of the component.
+type Component struct {
+	ID       string            `json:"id" db:"id"`
+	Category ComponentCategory `json:"category" db:"category"`
+	Type     string            `json:"type" db:"type"`
+	Name     string            `json:"name" db:"name"`
+	Enabled  bool              `json:"enabled" db:"enabled"`
+	Settings JSON              `json:"settings" db:"settings"`
+	// Components from the configuration file are read only and not stored in
+	// the database
+	ReadOnly  bool      `json:"readOnly" db:"-"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
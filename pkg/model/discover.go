@@ -0,0 +1,36 @@
+package model
+
+// DiscoverList is a curated list of movies or TV series of a provider.
+type DiscoverList string
+
+const (
+	DiscoverListPopular  DiscoverList = "popular"
+	DiscoverListTopRated DiscoverList = "top-rated"
+	// Movies which will be released soon and TV series which air in the
+	// next week
+	DiscoverListUpcoming DiscoverList = "upcoming"
+	// Movies in theaters and TV series which air today
+	DiscoverListNowPlaying DiscoverList = "now-playing"
+)
+
+func (l DiscoverList) Valid() bool {
+	switch l {
+	case DiscoverListPopular, DiscoverListTopRated, DiscoverListUpcoming, DiscoverListNowPlaying:
+		return true
+	}
+	return false
+}
+
+// Relation is the way items are related to an item.
+type Relation string
+
+const (
+	// Items which were liked by the same users
+	RelationRecommendations Relation = "recommendations"
+	// Items with similar genres and keywords
+	RelationSimilar Relation = "similar"
+)
+
+func (r Relation) Valid() bool {
+	return r == RelationRecommendations || r == RelationSimilar
+}
@@ -0,0 +1,9 @@
+package model
+
+// CompletedDownload is the content of a finished download, which is ready to
+// be organized into the library.
+type CompletedDownload struct {
+	Release Release
+	// Local path of the downloaded file or directory
+	Path string
+}
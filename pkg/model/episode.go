@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// EpisodeOrder decides how the episodes of a TV series are numbered.
+type EpisodeOrder string
+
+const (
+	// Numbering in the order the episodes were aired
+	EpisodeOrderAired EpisodeOrder = "aired"
+	// Numbering of the DVD releases
+	EpisodeOrderDVD EpisodeOrder = "dvd"
+)
+
+func (o EpisodeOrder) Valid() bool {
+	return o == EpisodeOrderAired || o == EpisodeOrderDVD
+}
+
+type Episode struct {
+	SeasonNumber  int        `json:"seasonNumber"`
+	EpisodeNumber int        `json:"episodeNumber"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description,omitempty"`
+	AirDate       *time.Time `json:"airDate,omitempty"`
+}
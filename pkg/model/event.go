@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+type EventType string
+
+const (
+	EventItemAdded        EventType = "itemAdded"
+	EventItemUpdated      EventType = "itemUpdated"
+	EventItemDeleted      EventType = "itemDeleted"
+	EventStatusChanged    EventType = "statusChanged"
+	EventGrabbed          EventType = "grabbed"
+	EventDownloadProgress EventType = "downloadProgress"
+	EventDownloaded       EventType = "downloaded"
+	EventImported         EventType = "imported"
+	EventUpgraded         EventType = "upgraded"
+	EventFailed           EventType = "failed"
+	EventFileDeleted      EventType = "fileDeleted"
+)
+
+// Event is published on the event bus whenever something happens to an item.
+type Event struct {
+	ID     int64       `json:"id"`
+	Type   EventType   `json:"type"`
+	ItemID string      `json:"itemId,omitempty"`
+	Date   time.Time   `json:"date"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+type StatusChangedData struct {
+	Status ItemStatus `json:"status"`
+}
+
+type DownloadProgressData struct {
+	Release string `json:"release"`
+	// Progress between 0 and 1
+	Progress float64 `json:"progress"`
+}
@@ -0,0 +1,96 @@
+package model
+
+// ExternalIDSource is a service which identifies items by its own IDs.
+type ExternalIDSource string
+
+const (
+	ExternalIDSourceTMDB   ExternalIDSource = "tmdb"
+	ExternalIDSourceIMDb   ExternalIDSource = "imdb"
+	ExternalIDSourceTVDb   ExternalIDSource = "tvdb"
+	ExternalIDSourceTVRage ExternalIDSource = "tvrage"
+)
+
+var ExternalIDSources = []ExternalIDSource{
+	ExternalIDSourceTMDB,
+	ExternalIDSourceIMDb,
+	ExternalIDSourceTVDb,
+	ExternalIDSourceTVRage,
+}
+
+func (s ExternalIDSource) Valid() bool {
+	for _, source := range ExternalIDSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryExternalIDSource returns the source of the external ID of items of
+// the given kind, which is IMDb for movies and TVDb for TV series.
+func PrimaryExternalIDSource(kind ItemKind) ExternalIDSource {
+	if kind == ItemKindTVSeries {
+		return ExternalIDSourceTVDb
+	}
+	return ExternalIDSourceIMDb
+}
+
+// ExternalIDs are the IDs of an item at the metadata providers and indexers,
+// unknown IDs are empty.
+type ExternalIDs struct {
+	TMDB   string `json:"tmdb,omitempty" db:"tmdb_id"`
+	IMDb   string `json:"imdb,omitempty" db:"imdb_id"`
+	TVDb   string `json:"tvdb,omitempty" db:"tvdb_id"`
+	TVRage string `json:"tvrage,omitempty" db:"tvrage_id"`
+}
+
+// Get returns the ID of the given source.
+func (ids ExternalIDs) Get(source ExternalIDSource) string {
+	switch source {
+	case ExternalIDSourceTMDB:
+		return ids.TMDB
+	case ExternalIDSourceIMDb:
+		return ids.IMDb
+	case ExternalIDSourceTVDb:
+		return ids.TVDb
+	case ExternalIDSourceTVRage:
+		return ids.TVRage
+	}
+	return ""
+}
+
+// Set replaces the ID of the given source.
+func (ids *ExternalIDs) Set(source ExternalIDSource, id string) {
+	switch source {
+	case ExternalIDSourceTMDB:
+		ids.TMDB = id
+	case ExternalIDSourceIMDb:
+		ids.IMDb = id
+	case ExternalIDSourceTVDb:
+		ids.TVDb = id
+	case ExternalIDSourceTVRage:
+		ids.TVRage = id
+	}
+}
+
+// Merge fills in the IDs which are unknown with the ones of other.
+func (ids *ExternalIDs) Merge(other ExternalIDs) {
+	for _, source := range ExternalIDSources {
+		if ids.Get(source) == "" {
+			ids.Set(source, other.Get(source))
+		}
+	}
+}
+
+// SourceID returns the ID of the item at the source. The external ID is used
+// for the primary source of items added before IDs were stored per source,
+// unless it is the TMDb ID of an item unknown at the primary source.
+func (item *Item) SourceID(source ExternalIDSource) string {
+	if id := item.ExternalIDs.Get(source); id != "" {
+		return id
+	}
+	if source == PrimaryExternalIDSource(item.Kind) && item.ExternalID != item.ExternalIDs.TMDB {
+		return item.ExternalID
+	}
+	return ""
+}
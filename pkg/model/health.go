@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusHealthy HealthStatus = "healthy"
+	// The last call failed, but calls are still made
+	HealthStatusFailing HealthStatus = "failing"
+	// No calls are made until DisabledUntil since too many calls failed
+	HealthStatusDisabled HealthStatus = "disabled"
+)
+
+// Health is the state of the connection to an indexer or provider.
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Number of consecutive failed calls
+	Failures      int        `json:"failures"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt *time.Time `json:"lastFailureAt,omitempty"`
+	DisabledUntil *time.Time `json:"disabledUntil,omitempty"`
+}
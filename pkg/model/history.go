@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+)
+
+type HistoryEvent string
+
+const (
+	HistoryEventGrabbed     HistoryEvent = "grabbed"
+	HistoryEventDownloaded  HistoryEvent = "downloaded"
+	HistoryEventImported    HistoryEvent = "imported"
+	HistoryEventUpgraded    HistoryEvent = "upgraded"
+	HistoryEventFailed      HistoryEvent = "failed"
+	HistoryEventDeleted     HistoryEvent = "deleted"
+	HistoryEventItemDeleted HistoryEvent = "itemDeleted"
+)
+
+type HistoryEntry struct {
+	ID int64 `json:"id" db:"id"`
+	// Empty once the item was deleted
+	ItemID string `json:"itemId,omitempty" db:"item_id"`
+	// Title of the item when the entry was recorded
+	Title string       `json:"title" db:"title"`
+	Event HistoryEvent `json:"event" db:"event"`
+	Date  time.Time    `json:"date" db:"date"`
+	Data  JSON         `json:"data" db:"data"`
+}
+
+type GrabbedData struct {
+	Indexer string  `json:"indexer"`
+	Release string  `json:"release"`
+	Size    int64   `json:"size"`
+	Quality Quality `json:"quality"`
+}
+
+type DownloadedData struct {
+	Release string `json:"release"`
+	Path    string `json:"path"`
+}
+
+type ImportedData struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Quality     Quality `json:"quality"`
+}
+
+type UpgradedData struct {
+	OldPath    string  `json:"oldPath"`
+	OldQuality Quality `json:"oldQuality"`
+	NewPath    string  `json:"newPath"`
+	NewQuality Quality `json:"newQuality"`
+}
+
+type FailedData struct {
+	// Stage of the pipeline which failed, like monitor, download or organize
+	Stage   string `json:"stage"`
+	Release string `json:"release,omitempty"`
+	Error   string `json:"error"`
+}
+
+type ItemDeletedData struct {
+	Kind ItemKind `json:"kind"`
+	Path string   `json:"path"`
+}
+
+type DeletedData struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
@@ -1,5 +1,11 @@
 package model
 
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
 type ItemKind string
 
 const (
@@ -15,16 +21,28 @@ const (
 	ItemStatusDownloaded            = "downloaded"
 )
 
+// Item is a movie or TV series. The external ID is the IMDb ID of movies and
+// the TVDb ID of TV series, the IDs of all sources are part of ExternalIDs.
 type Item struct {
-	ID             string      `json:"id" db:"id"`
-	ExternalID     string      `json:"externalId" db:"external_id"`
-	Kind           ItemKind    `json:"kind" db:"kind"`
-	Title          string      `json:"title" db:"title"`
-	Description    string      `json:"description" db:"description"`
-	ImagePath      string      `json:"imagePath" db:"image_path"`
-	ReleaseYear    int         `json:"releaseYear" db:"release_year"`
-	Genres         []string    `json:"genres" db:"genres"`
-	Rating         float64     `json:"rating" db:"rating"`
-	Status         ItemStatus  `json:"status" db:"status"`
-	AdditionalData interface{} `json:"data"`
+	// Empty for items of providers which are not in the library
+	ID               string         `json:"id,omitempty" db:"id"`
+	ExternalID       string         `json:"externalId" db:"external_id"`
+	Kind             ItemKind       `json:"kind" db:"kind"`
+	Title            string         `json:"title" db:"title"`
+	Description      string         `json:"description" db:"description"`
+	ImagePath        string         `json:"imagePath" db:"image_path"`
+	ReleaseYear      int            `json:"releaseYear" db:"release_year"`
+	Genres           pq.StringArray `json:"genres,omitempty" db:"genres"`
+	Rating           float64        `json:"rating" db:"rating"`
+	Status           ItemStatus     `json:"status" db:"status"`
+	Path             string         `json:"path,omitempty" db:"path"`
+	QualityProfileID int            `json:"qualityProfileId" db:"quality_profile_id"`
+	RequestedBy      *string        `json:"requestedBy,omitempty" db:"requested_by"`
+	AddedAt          time.Time      `json:"addedAt" db:"added_at"`
+	RefreshedAt      *time.Time     `json:"refreshedAt,omitempty" db:"refreshed_at"`
+	EpisodeOrder     EpisodeOrder   `json:"episodeOrder,omitempty" db:"episode_order"`
+	// Provider of each metadata field
+	MetadataSources MetadataSources `json:"metadataSources,omitempty" db:"metadata_sources"`
+	AdditionalData  interface{}     `json:"data,omitempty"`
+	ExternalIDs     `json:"externalIds"`
 }
@@ -0,0 +1,34 @@
+package model
+
+type ItemSort string
+
+const (
+	ItemSortTitle       ItemSort = "title"
+	ItemSortAddedAt     ItemSort = "addedAt"
+	ItemSortReleaseYear ItemSort = "releaseYear"
+	ItemSortRating      ItemSort = "rating"
+)
+
+func (s ItemSort) Valid() bool {
+	switch s {
+	case ItemSortTitle, ItemSortAddedAt, ItemSortReleaseYear, ItemSortRating:
+		return true
+	}
+	return false
+}
+
+// ItemFilter restricts and orders listed items. Zero values do not restrict
+// the result.
+type ItemFilter struct {
+	Kind      ItemKind
+	Status    ItemStatus
+	Genre     string
+	MinYear   int
+	MaxYear   int
+	MinRating float64
+	MaxRating float64
+	// Case insensitive substring of the title
+	Search     string
+	Sort       ItemSort
+	Descending bool
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+type JobKind string
+
+const (
+	JobKindMonitor  JobKind = "monitor"
+	JobKindDownload JobKind = "download"
+	JobKindOrganize JobKind = "organize"
+)
+
+// Job is a unit of work of the pipeline, which was interrupted by a shutdown
+// and is resumed on the next start. Download and organize jobs contain the
+// release, organize jobs also the path of the completed download.
+type Job struct {
+	ID        string    `db:"id"`
+	Kind      JobKind   `db:"kind"`
+	ItemID    string    `db:"item_id"`
+	Title     string    `db:"title"`
+	Indexer   string    `db:"indexer"`
+	Size      int64     `db:"size"`
+	Quality   Quality   `db:"quality"`
+	Torrent   []byte    `db:"torrent"`
+	Path      string    `db:"path"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+type MediaFile struct {
+	ID            string    `json:"id" db:"id"`
+	ItemID        string    `json:"itemId" db:"item_id"`
+	SeasonNumber  *int      `json:"seasonNumber,omitempty" db:"season_number"`
+	EpisodeNumber *int      `json:"episodeNumber,omitempty" db:"episode_number"`
+	Path          string    `json:"path" db:"path"`
+	Size          int64     `json:"size" db:"size"`
+	Quality       Quality   `json:"quality" db:"quality"`
+	ReleaseGroup  string    `json:"releaseGroup" db:"release_group"`
+	ImportedAt    time.Time `json:"importedAt" db:"imported_at"`
+	ModifiedAt    time.Time `json:"modifiedAt" db:"modified_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Metadata fields of an item whose source is recorded.
+const (
+	MetadataFieldTitle       = "title"
+	MetadataFieldDescription = "description"
+	MetadataFieldImagePath   = "imagePath"
+	MetadataFieldReleaseYear = "releaseYear"
+	MetadataFieldGenres      = "genres"
+	MetadataFieldRating      = "rating"
+)
+
+// MetadataSources maps the metadata fields of an item to the name of the
+// provider they were fetched from.
+type MetadataSources map[string]string
+
+// Value stores empty sources as NULL, so updates without sources keep the
+// stored ones.
+func (s MetadataSources) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	buf, err := json.Marshal(map[string]string(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(buf), nil
+}
+
+func (s *MetadataSources) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	default:
+		return fmt.Errorf("cannot scan %T into MetadataSources", src)
+	}
+}
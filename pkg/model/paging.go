@@ -4,3 +4,17 @@ type Paging struct {
 	Offset int
 	Count  int
 }
+
+type ItemPaging struct {
+	Items  []*Item `json:"items"`
+	Offset int     `json:"offset"`
+	Count  int     `json:"count"`
+	Total  int     `json:"total"`
+}
+
+type HistoryPaging struct {
+	Entries []*HistoryEntry `json:"entries"`
+	Offset  int             `json:"offset"`
+	Count   int             `json:"count"`
+	Total   int             `json:"total"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// ProviderCacheEntry is a cached response of a provider. Unknown IDs and
+// searches without results are cached as not found.
+type ProviderCacheEntry struct {
+	Key       string    `db:"key"`
+	Value     JSON      `db:"value"`
+	NotFound  bool      `db:"not_found"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
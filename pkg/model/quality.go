@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+)
+
+// Quality of a release or media file, combining source and resolution like
+// "Bluray-1080p".
+type Quality string
+
+const (
+	QualityUnknown Quality = "Unknown"
+
+	DefaultQualityProfileID = 1
+)
+
+var (
+	qualitySourceRanks = map[string]int{
+		"Unknown": 0,
+		"DVD":     1,
+		"HDTV":    2,
+		"WEB":     3,
+		"Bluray":  4,
+		"Remux":   5,
+	}
+	qualityResolutionRanks = map[string]int{
+		"":      0,
+		"480p":  1,
+		"720p":  2,
+		"1080p": 3,
+		"2160p": 4,
+	}
+)
+
+// Rank orders qualities by resolution first and source second. Higher ranks
+// are better.
+func (q Quality) Rank() int {
+	parts := strings.SplitN(string(q), "-", 2)
+	source, resolution := parts[0], ""
+	if len(parts) == 2 {
+		resolution = parts[1]
+	} else if source == "DVD" {
+		resolution = "480p"
+	}
+	return qualityResolutionRanks[resolution]*10 + qualitySourceRanks[source]
+}
+
+type QualityProfile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Qualities which may be downloaded, any quality is allowed if empty
+	Allowed []Quality `json:"allowed"`
+	// Quality at which an item is no longer upgraded
+	Cutoff Quality `json:"cutoff"`
+}
+
+func (p *QualityProfile) Allows(q Quality) bool {
+	if len(p.Allowed) == 0 {
+		return true
+	}
+	for _, allowed := range p.Allowed {
+		if allowed == q {
+			return true
+		}
+	}
+	return false
+}
+
+// CutoffMet reports whether the given quality is at least as good as the
+// cutoff of the profile.
+func (p *QualityProfile) CutoffMet(current Quality) bool {
+	return current.Rank() >= p.Cutoff.Rank()
+}
+
+// Wants reports whether a release of the given quality should be downloaded
+// for an item whose best file has the current quality. An empty current
+// quality means the item has no file yet.
+func (p *QualityProfile) Wants(current, candidate Quality) bool {
+	if !p.Allows(candidate) {
+		return false
+	}
+	if current == "" {
+		return true
+	}
+	return !p.CutoffMet(current) && candidate.Rank() > current.Rank()
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// Release is a downloadable release of an item found by a monitorer.
+type Release struct {
+	Item    *Item
+	Title   string
+	Indexer string
+	Size    int64
+	Quality Quality
+	Torrent []byte
+}
+
+// IndexerRelease is a release listed in the recent releases of an indexer,
+// before it is matched to an item. The IDs are empty if the indexer does not
+// know them.
+type IndexerRelease struct {
+	GUID        string
+	Title       string
+	Indexer     string
+	Size        int64
+	DownloadURL string
+	TVDbID      string
+	IMDbID      string
+	PublishedAt time.Time
+}
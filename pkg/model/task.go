@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// Task is a recurring maintenance job of the scheduler. Durations are in
+// seconds, tasks with an interval of zero only run when triggered manually.
+type Task struct {
+	Name         string     `json:"name" db:"name"`
+	Interval     float64    `json:"interval" db:"-"`
+	Running      bool       `json:"running" db:"-"`
+	LastRunAt    *time.Time `json:"lastRunAt" db:"last_run_at"`
+	LastDuration float64    `json:"lastDuration" db:"last_duration"`
+	LastError    string     `json:"lastError,omitempty" db:"last_error"`
+	NextRunAt    *time.Time `json:"nextRunAt" db:"next_run_at"`
+}
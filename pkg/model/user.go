@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+)
+
+type Role string
+
+const (
+	// RoleAdmin may configure godarr and manage users
+	RoleAdmin Role = "admin"
+	// RoleMember may add and request items
+	RoleMember Role = "member"
+	// RoleViewer has read only access
+	RoleViewer Role = "viewer"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether the role has at least the permissions of other.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[other]
+}
+
+type User struct {
+	ID           string `json:"id" db:"id"`
+	Name         string `json:"name" db:"name"`
+	PasswordHash string `json:"-" db:"password_hash"`
+	Role         Role   `json:"role" db:"role"`
+	// Maximum amount of items the user may request within QuotaDays, unlimited
+	// if 0
+	RequestQuota int       `json:"requestQuota" db:"request_quota"`
+	QuotaDays    int       `json:"quotaDays" db:"quota_days"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+type Session struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookEvents are the events webhooks can subscribe to. Webhooks without
+// events receive all of them.
+var WebhookEvents = []EventType{
+	EventGrabbed,
+	EventDownloaded,
+	EventImported,
+	EventUpgraded,
+	EventFailed,
+	EventFileDeleted,
+	EventItemDeleted,
+}
+
+// EventWebhookTest is only sent to a single webhook to test it.
+const EventWebhookTest EventType = "test"
+
+type Webhook struct {
+	ID        string         `json:"id" db:"id"`
+	Name      string         `json:"name" db:"name"`
+	URL       string         `json:"url" db:"url"`
+	Secret    string         `json:"-" db:"secret"`
+	Events    pq.StringArray `json:"events" db:"events"`
+	Enabled   bool           `json:"enabled" db:"enabled"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+}
+
+func (w *Webhook) Wants(eventType EventType) bool {
+	if eventType == EventWebhookTest {
+		return true
+	}
+	if len(w.Events) == 0 {
+		for _, t := range WebhookEvents {
+			if t == eventType {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range w.Events {
+		if EventType(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a single attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         int64     `json:"id" db:"id"`
+	WebhookID  string    `json:"webhookId" db:"webhook_id"`
+	EventID    int64     `json:"eventId" db:"event_id"`
+	EventType  EventType `json:"eventType" db:"event_type"`
+	Payload    JSON      `json:"payload" db:"payload"`
+	Attempt    int       `json:"attempt" db:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty" db:"status_code"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	Success    bool      `json:"success" db:"success"`
+	Date       time.Time `json:"date" db:"date"`
+}
@@ -1,63 +1,169 @@
 package monitorer
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/KnutZuidema/go-btn"
-	"github.com/KnutZuidema/go-btn/pkg/model"
+	btnmodel "github.com/KnutZuidema/go-btn/pkg/model"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/feed"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+const (
+	broadcasTheNetIndexer     = "BroadcasTheNet"
+	broadcasTheNetSearchCount = 20
+	// BroadcasTheNet has no RSS feed, the most recent torrents are listed by
+	// a search without options instead
+	broadcasTheNetRecentCount = 100
 )
 
 type BroadcasTheNetMonitorer struct {
-	client         *btn.Client
-	logger         log.FieldLogger
-	output         chan<- []byte
-	searchInterval time.Duration
+	client *btn.Client
+	db     database.Database
+	guard  *ratelimit.Guard
+	logger log.FieldLogger
+	output chan<- model.Release
 }
 
-func NewBroadcasTheNetMonitorer(apiKey string, logger log.FieldLogger, output chan<- []byte, interval time.Duration) *BroadcasTheNetMonitorer {
+// NewBroadcasTheNetMonitorer creates a monitorer whose requests to the API of
+// BroadcasTheNet pass through guard.
+func NewBroadcasTheNetMonitorer(apiKey string, db database.Database, guard *ratelimit.Guard, logger log.FieldLogger, output chan<- model.Release) *BroadcasTheNetMonitorer {
 	if logger == nil {
 		logger = log.StandardLogger()
 	}
 	return &BroadcasTheNetMonitorer{
-		client:         btn.NewClient(http.DefaultClient, apiKey),
-		logger:         logger.WithField("component", "BroadcasTheNetMonitorer"),
-		output:         output,
-		searchInterval: interval,
-	}
-}
-
-func (m *BroadcasTheNetMonitorer) Monitor(tvdbID string) (err error) {
-	var torrent model.Torrent
-	ticker := time.NewTicker(m.searchInterval)
-	defer ticker.Stop()
-	for range ticker.C {
-		torrents, err := m.client.SearchTorrents(model.SearchTorrentOptions{
-			TVDbID: tvdbID,
-		}, 1, 0)
-		if err != nil {
+		// the client reports statuses only as text
+		client: btn.NewClient(&http.Client{Transport: ratelimit.Transport{}}, apiKey),
+		db:     db,
+		guard:  guard,
+		logger: logger.WithField("component", "BroadcasTheNetMonitorer"),
+		output: output,
+	}
+}
+
+// Monitor searches for releases of the item, identified by its TVDb ID, once.
+// Every release is matched to the season or episode it contains and the best
+// release of each which is an upgrade over its media files and was not
+// grabbed before is sent to the output.
+func (m *BroadcasTheNetMonitorer) Monitor(ctx context.Context, item *model.Item) error {
+	tvdbID := item.SourceID(model.ExternalIDSourceTVDb)
+	if tvdbID == "" {
+		// BroadcasTheNet only lists TV series
+		return nil
+	}
+	profile, err := m.db.GetQualityProfile(item.QualityProfileID)
+	if err != nil {
+		return err
+	}
+	files, err := m.db.ListMediaFiles(item.ID)
+	if err != nil {
+		return err
+	}
+	if current := lowestQuality(files); current != "" && profile.CutoffMet(current) {
+		return nil
+	}
+	torrents, err := m.search(ctx, btnmodel.SearchTorrentOptions{
+		TVDbID: tvdbID,
+	}, broadcasTheNetSearchCount)
+	if err != nil {
+		return err
+	}
+	best := rssCandidates{}
+	for _, torrent := range torrents {
+		best.add(item, profile, files, broadcasTheNetRelease(torrent))
+	}
+	for _, candidate := range best.sorted() {
+		if _, err := grab(ctx, m.db, m.output, m.logger, candidate); err != nil {
 			return err
 		}
-		if len(torrents) > 0 {
-			torrent = torrents[0]
-			break
-		}
 	}
-	resp, err := http.Get(torrent.DownloadURL)
+	return nil
+}
+
+// Recent lists the most recently uploaded torrents.
+func (m *BroadcasTheNetMonitorer) Recent(ctx context.Context) ([]model.IndexerRelease, error) {
+	torrents, err := m.search(ctx, btnmodel.SearchTorrentOptions{}, broadcasTheNetRecentCount)
 	if err != nil {
+		return nil, err
+	}
+	releases := make([]model.IndexerRelease, 0, len(torrents))
+	for _, torrent := range torrents {
+		releases = append(releases, broadcasTheNetRelease(torrent))
+	}
+	return releases, nil
+}
+
+// search passes a search for torrents through the guard, so only the requests
+// to the API are limited and retried.
+func (m *BroadcasTheNetMonitorer) search(ctx context.Context, options btnmodel.SearchTorrentOptions, count int) ([]btnmodel.Torrent, error) {
+	var torrents []btnmodel.Torrent
+	err := m.guard.Do(ctx, func() (err error) {
+		torrents, err = m.client.SearchTorrents(options, count, 0)
 		return err
+	})
+	return torrents, err
+}
+
+func broadcasTheNetRelease(torrent btnmodel.Torrent) model.IndexerRelease {
+	release := model.IndexerRelease{
+		GUID:        strconv.Itoa(torrent.TorrentID),
+		Title:       torrent.ReleaseName,
+		Indexer:     broadcasTheNetIndexer,
+		Size:        int64(torrent.Size),
+		DownloadURL: torrent.DownloadURL,
+		IMDbID:      feed.IMDbID(strconv.Itoa(torrent.ImdbID)),
+		PublishedAt: torrent.Time,
+	}
+	if torrent.TvdbID > 0 {
+		release.TVDbID = strconv.Itoa(torrent.TvdbID)
+	}
+	return release
+}
+
+// currentQuality returns the lowest quality among the media files of the
+// item, or an empty quality if it has none.
+func currentQuality(db database.Database, item *model.Item) (model.Quality, error) {
+	files, err := db.ListMediaFiles(item.ID)
+	if err != nil {
+		return "", err
+	}
+	return lowestQuality(files), nil
+}
+
+func lowestQuality(files []*model.MediaFile) model.Quality {
+	var current model.Quality
+	for _, file := range files {
+		if current == "" || file.Quality.Rank() < current.Rank() {
+			current = file.Quality
+		}
+	}
+	return current
+}
+
+func download(ctx context.Context, url string) (buf []byte, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
 		if e := resp.Body.Close(); e != nil {
 			err = e
 		}
 	}()
-	buf, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status %s", resp.Status)
 	}
-	m.output <- buf
-	return nil
+	return ioutil.ReadAll(resp.Body)
 }
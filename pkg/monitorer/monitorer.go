@@ -1,5 +1,19 @@
 package monitorer
 
+import (
+	"context"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Monitorer searches an indexer for releases of an item once. Releases which
+// appear later are found by the RSS sync.
 type Monitorer interface {
-	Monitor(value string) error
+	Monitor(ctx context.Context, item *model.Item) error
+}
+
+// Feed is implemented by indexers which list their most recent releases, it
+// is used by the RSS sync.
+type Feed interface {
+	Recent(ctx context.Context) ([]model.IndexerRelease, error)
 }
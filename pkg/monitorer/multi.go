@@ -0,0 +1,38 @@
+package monitorer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// MultiMonitorer monitors items with several monitorers at once, like one per
+// indexer.
+type MultiMonitorer []Monitorer
+
+func (m MultiMonitorer) Monitor(ctx context.Context, item *model.Item) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []string
+	)
+	for _, monitorer := range m {
+		wg.Add(1)
+		go func(monitorer Monitorer) {
+			defer wg.Done()
+			if err := monitorer.Monitor(ctx, item); err != nil {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
+			}
+		}(monitorer)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package monitorer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/library"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// wantedPageSize is the number of items loaded at once by the RSS sync.
+const wantedPageSize = 500
+
+// RSSSync fetches the recent releases of every indexer once and matches them
+// against all wanted items and episodes, instead of searching every indexer
+// for every item.
+type RSSSync struct {
+	db     database.Database
+	feeds  func() []Feed
+	output chan<- model.Release
+	logger log.FieldLogger
+}
+
+// NewRSSSync creates an RSS sync of the indexers returned by feeds, which is
+// called on every sync, so indexers can change at runtime.
+func NewRSSSync(db database.Database, feeds func() []Feed, output chan<- model.Release, logger log.FieldLogger) *RSSSync {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	return &RSSSync{
+		db:     db,
+		feeds:  feeds,
+		output: output,
+		logger: logger.WithField("component", "RSSSync"),
+	}
+}
+
+// rssCandidate is the best release found for an item, season or episode.
+type rssCandidate struct {
+	item    *model.Item
+	release model.IndexerRelease
+	quality model.Quality
+}
+
+// Sync grabs the best release of every wanted item, season or episode among
+// the recent releases. Indexers which fail are skipped and reported in the
+// error after the others were processed.
+func (s *RSSSync) Sync(ctx context.Context) error {
+	items, err := s.wanted()
+	if err != nil {
+		return err
+	}
+	var (
+		releases []model.IndexerRelease
+		errs     []string
+	)
+	for _, f := range s.feeds() {
+		recent, err := f.Recent(ctx)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		releases = append(releases, recent...)
+	}
+	if len(items) > 0 {
+		candidates, err := s.match(newRSSMatcher(items), releases)
+		if err != nil {
+			return err
+		}
+		grabbed := 0
+		for _, candidate := range candidates {
+			ok, err := grab(ctx, s.db, s.output, s.logger, candidate)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				errs = append(errs, err.Error())
+			}
+			if ok {
+				grabbed++
+			}
+		}
+		s.logger.WithFields(log.Fields{
+			"releases": len(releases),
+			"grabbed":  grabbed,
+		}).Info("synced")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// wanted returns the items which releases are searched for, see Wanted.
+func (s *RSSSync) wanted() ([]*model.Item, error) {
+	var wanted []*model.Item
+	err := Wanted(s.db, wantedPageSize, func(item *model.Item) error {
+		wanted = append(wanted, item)
+		return nil
+	})
+	return wanted, err
+}
+
+// match returns the best release per item, season and episode which is an
+// upgrade over the existing media files, ordered by item and target.
+func (s *RSSSync) match(matcher *rssMatcher, releases []model.IndexerRelease) ([]*rssCandidate, error) {
+	var (
+		best     = rssCandidates{}
+		profiles = map[int]*model.QualityProfile{}
+		files    = map[string][]*model.MediaFile{}
+	)
+	for _, release := range releases {
+		item := matcher.match(release)
+		if item == nil {
+			continue
+		}
+		profile, ok := profiles[item.QualityProfileID]
+		if !ok {
+			var err error
+			if profile, err = s.db.GetQualityProfile(item.QualityProfileID); err != nil {
+				return nil, err
+			}
+			profiles[item.QualityProfileID] = profile
+		}
+		itemFiles, ok := files[item.ID]
+		if !ok {
+			var err error
+			if itemFiles, err = s.db.ListMediaFiles(item.ID); err != nil {
+				return nil, err
+			}
+			files[item.ID] = itemFiles
+		}
+		best.add(item, profile, itemFiles, release)
+	}
+	return best.sorted(), nil
+}
+
+// rssCandidates holds the best release per item, season and episode, keyed
+// by the target returned by rssTarget.
+type rssCandidates map[string]*rssCandidate
+
+// add keeps the release if it is an upgrade over the existing media files of
+// what it contains and better than the release kept for it so far. Releases
+// which contain neither a season nor an episode of a series are dropped.
+func (c rssCandidates) add(item *model.Item, profile *model.QualityProfile, files []*model.MediaFile, release model.IndexerRelease) {
+	key, current, ok := rssTarget(item, release.Title, files)
+	if !ok {
+		return
+	}
+	if candidate, ok := c[key]; ok {
+		current = candidate.quality
+	}
+	quality := library.ParseQuality(release.Title)
+	if !profile.Wants(current, quality) {
+		return
+	}
+	c[key] = &rssCandidate{
+		item:    item,
+		release: release,
+		quality: quality,
+	}
+}
+
+// sorted returns the candidates ordered by item and target.
+func (c rssCandidates) sorted() []*rssCandidate {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	candidates := make([]*rssCandidate, 0, len(keys))
+	for _, key := range keys {
+		candidates = append(candidates, c[key])
+	}
+	return candidates
+}
+
+// rssTarget returns what a release of an item contains, the whole movie, a
+// season or an episode, as key together with the current quality of it.
+func rssTarget(item *model.Item, title string, files []*model.MediaFile) (string, model.Quality, bool) {
+	if item.Kind == model.ItemKindMovie {
+		return item.ID, lowestQuality(files), true
+	}
+	if season, episode, ok := library.ParseEpisode(title); ok {
+		var matching []*model.MediaFile
+		for _, file := range files {
+			if file.SeasonNumber != nil && *file.SeasonNumber == season &&
+				file.EpisodeNumber != nil && *file.EpisodeNumber == episode {
+				matching = append(matching, file)
+			}
+		}
+		return fmt.Sprintf("%s/%02d/%03d", item.ID, season, episode), lowestQuality(matching), true
+	}
+	if season, ok := library.ParseSeasonPack(title); ok {
+		var matching []*model.MediaFile
+		for _, file := range files {
+			if file.SeasonNumber != nil && *file.SeasonNumber == season {
+				matching = append(matching, file)
+			}
+		}
+		return fmt.Sprintf("%s/%02d", item.ID, season), lowestQuality(matching), true
+	}
+	return "", "", false
+}
+
+// grab downloads the torrent of a candidate and sends it to output, unless
+// the same release was grabbed for the item before.
+func grab(ctx context.Context, db database.Database, output chan<- model.Release, logger log.FieldLogger, candidate *rssCandidate) (bool, error) {
+	grabbed, err := grabbed(db, candidate.item.ID, candidate.release.Title)
+	if err != nil || grabbed {
+		return false, err
+	}
+	file, err := download(ctx, candidate.release.DownloadURL)
+	if err != nil {
+		return false, fmt.Errorf("download %s: %v", candidate.release.Title, err)
+	}
+	logger.WithFields(log.Fields{
+		"item":    candidate.item.ID,
+		"release": candidate.release.Title,
+		"indexer": candidate.release.Indexer,
+		"quality": candidate.quality,
+	}).Info("found release")
+	select {
+	case output <- model.Release{
+		Item:    candidate.item,
+		Title:   candidate.release.Title,
+		Indexer: candidate.release.Indexer,
+		Size:    candidate.release.Size,
+		Quality: candidate.quality,
+		Torrent: file,
+	}:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// grabbed reports whether a release with the title was grabbed for the item
+// before, according to its history.
+func grabbed(db database.Database, itemID, title string) (bool, error) {
+	entries, err := db.ListItemHistory(itemID)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Event != model.HistoryEventGrabbed {
+			continue
+		}
+		var data model.GrabbedData
+		if err := json.Unmarshal(entry.Data, &data); err == nil && data.Release == title {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rssMatcher finds the wanted item of a release by its TVDb or IMDb ID, or
+// by its title if the indexer does not know the IDs.
+type rssMatcher struct {
+	items  []*model.Item
+	byTVDb map[string]*model.Item
+	byIMDb map[string]*model.Item
+}
+
+func newRSSMatcher(items []*model.Item) *rssMatcher {
+	m := &rssMatcher{
+		items:  items,
+		byTVDb: map[string]*model.Item{},
+		byIMDb: map[string]*model.Item{},
+	}
+	for _, item := range items {
+		if id := item.SourceID(model.ExternalIDSourceTVDb); id != "" {
+			m.byTVDb[id] = item
+		}
+		if id := item.SourceID(model.ExternalIDSourceIMDb); id != "" {
+			m.byIMDb[id] = item
+		}
+	}
+	return m
+}
+
+func (m *rssMatcher) match(release model.IndexerRelease) *model.Item {
+	if item, ok := m.byTVDb[release.TVDbID]; ok && release.TVDbID != "" {
+		return item
+	}
+	if item, ok := m.byIMDb[release.IMDbID]; ok && release.IMDbID != "" {
+		return item
+	}
+	if release.TVDbID != "" || release.IMDbID != "" {
+		return nil
+	}
+	kind := model.ItemKind(model.ItemKindMovie)
+	if _, _, ok := library.ParseEpisode(release.Title); ok {
+		kind = model.ItemKindTVSeries
+	} else if _, ok := library.ParseSeasonPack(release.Title); ok {
+		kind = model.ItemKindTVSeries
+	}
+	title, year := library.ParseReleaseTitle(release.Title)
+	for _, item := range m.items {
+		if item.Kind != kind || !library.SameTitle(item.Title, title) {
+			continue
+		}
+		if year != 0 && item.ReleaseYear != 0 && year != item.ReleaseYear {
+			continue
+		}
+		return item
+	}
+	return nil
+}
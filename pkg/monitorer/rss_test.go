@@ -0,0 +1,76 @@
+package monitorer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+func TestRSSCandidates(t *testing.T) {
+	season, episode := 1, 1
+	item := &model.Item{ID: "series", Kind: model.ItemKindTVSeries}
+	profile := &model.QualityProfile{Cutoff: "Bluray-1080p"}
+	files := []*model.MediaFile{
+		{SeasonNumber: &season, EpisodeNumber: &episode, Quality: "WEB-1080p"},
+	}
+	best := rssCandidates{}
+	for _, title := range []string{
+		// a worse release of an episode which has a file already
+		"Series.S01E01.720p.HDTV-GROUP",
+		"Series.S01E02.720p.HDTV-GROUP",
+		"Series.S01E02.1080p.BluRay-GROUP",
+		"Series.S01E03.720p.HDTV-GROUP",
+		// neither a season nor an episode
+		"Series.Special.1080p.BluRay-GROUP",
+	} {
+		best.add(item, profile, files, model.IndexerRelease{Title: title})
+	}
+	var titles []string
+	for _, candidate := range best.sorted() {
+		titles = append(titles, candidate.release.Title)
+	}
+	expected := []string{"Series.S01E02.1080p.BluRay-GROUP", "Series.S01E03.720p.HDTV-GROUP"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("candidates %v, expected %v", titles, expected)
+	}
+}
+
+// historyDB serves the history of items from memory, other methods of the
+// database are not used by grabbed.
+type historyDB struct {
+	database.Database
+	history map[string][]*model.HistoryEntry
+}
+
+func (d *historyDB) ListItemHistory(itemID string) ([]*model.HistoryEntry, error) {
+	return d.history[itemID], nil
+}
+
+func TestGrabbed(t *testing.T) {
+	data, err := json.Marshal(model.GrabbedData{Release: "Series.S01E02.1080p.BluRay-GROUP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &historyDB{history: map[string][]*model.HistoryEntry{
+		"series": {{ItemID: "series", Event: model.HistoryEventGrabbed, Data: data}},
+	}}
+	for _, c := range []struct {
+		itemID, title string
+		grabbed       bool
+	}{
+		{"series", "Series.S01E02.1080p.BluRay-GROUP", true},
+		{"series", "Series.S01E02.720p.HDTV-GROUP", false},
+		{"other", "Series.S01E02.1080p.BluRay-GROUP", false},
+	} {
+		ok, err := grabbed(db, c.itemID, c.title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.grabbed {
+			t.Errorf("%s of %s grabbed %t, expected %t", c.title, c.itemID, ok, c.grabbed)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package monitorer
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/KnutZuidema/godarr/pkg/feed"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// RSSFeedMonitorer is an indexer which only provides an RSS or Torznab feed
+// of recent releases. It can not search for items, its releases are only
+// found by the RSS sync.
+type RSSFeedMonitorer struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func NewRSSFeedMonitorer(name, url string, client *http.Client) *RSSFeedMonitorer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RSSFeedMonitorer{
+		name:   name,
+		url:    url,
+		client: client,
+	}
+}
+
+// Monitor does nothing, since feeds can not be searched.
+func (m *RSSFeedMonitorer) Monitor(ctx context.Context, item *model.Item) error {
+	return nil
+}
+
+func (m *RSSFeedMonitorer) Recent(ctx context.Context) ([]model.IndexerRelease, error) {
+	items, err := feed.Fetch(ctx, m.client, m.url)
+	if err != nil {
+		return nil, err
+	}
+	releases := make([]model.IndexerRelease, 0, len(items))
+	for _, item := range items {
+		releases = append(releases, model.IndexerRelease{
+			GUID:        item.GUID,
+			Title:       item.Title,
+			Indexer:     m.name,
+			Size:        item.Size,
+			DownloadURL: item.DownloadURL,
+			TVDbID:      item.TVDbID,
+			IMDbID:      item.IMDbID,
+			PublishedAt: item.PublishedAt,
+		})
+	}
+	return releases, nil
+}
@@ -0,0 +1,62 @@
+package monitorer
+
+import (
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Wanted calls f with every item which releases are searched for: items which
+// are added or monitored, and downloaded items whose lowest quality is below
+// the cutoff of their quality profile, so they are upgraded. Items are loaded
+// pageSize at a time, the first error of f is returned.
+func Wanted(db database.Database, pageSize int, f func(item *model.Item) error) error {
+	profiles := map[int]*model.QualityProfile{}
+	for _, status := range []model.ItemStatus{
+		model.ItemStatusAdded,
+		model.ItemStatusMonitored,
+		model.ItemStatusDownloaded,
+	} {
+		for offset := 0; ; offset += pageSize {
+			items, err := db.ListItems(model.ItemFilter{Status: status}, offset, pageSize)
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if status == model.ItemStatusDownloaded {
+					upgrade, err := belowCutoff(db, profiles, item)
+					if err != nil {
+						return err
+					}
+					if !upgrade {
+						continue
+					}
+				}
+				if err := f(item); err != nil {
+					return err
+				}
+			}
+			if len(items) < pageSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// belowCutoff reports whether the lowest quality among the media files of an
+// item is below the cutoff of its quality profile. Items without media files
+// are not upgraded, since their files are unknown rather than missing.
+func belowCutoff(db database.Database, profiles map[int]*model.QualityProfile, item *model.Item) (bool, error) {
+	current, err := currentQuality(db, item)
+	if err != nil || current == "" {
+		return false, err
+	}
+	profile, ok := profiles[item.QualityProfileID]
+	if !ok {
+		if profile, err = db.GetQualityProfile(item.QualityProfileID); err != nil {
+			return false, err
+		}
+		profiles[item.QualityProfileID] = profile
+	}
+	return !profile.CutoffMet(current), nil
+}
@@ -0,0 +1,83 @@
+package monitorer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// wantedDB serves items, media files and quality profiles from memory, other
+// methods of the database are not used by Wanted.
+type wantedDB struct {
+	database.Database
+	items    []*model.Item
+	files    map[string][]*model.MediaFile
+	profiles map[int]*model.QualityProfile
+}
+
+func (d *wantedDB) ListItems(filter model.ItemFilter, offset, count int) ([]*model.Item, error) {
+	var items []*model.Item
+	for _, item := range d.items {
+		if item.Status == filter.Status {
+			items = append(items, item)
+		}
+	}
+	if offset >= len(items) {
+		return nil, nil
+	}
+	items = items[offset:]
+	if len(items) > count {
+		items = items[:count]
+	}
+	return items, nil
+}
+
+func (d *wantedDB) ListMediaFiles(itemID string) ([]*model.MediaFile, error) {
+	return d.files[itemID], nil
+}
+
+func (d *wantedDB) GetQualityProfile(id int) (*model.QualityProfile, error) {
+	return d.profiles[id], nil
+}
+
+func TestWanted(t *testing.T) {
+	db := &wantedDB{
+		items: []*model.Item{
+			{ID: "added", Status: model.ItemStatusAdded, QualityProfileID: 1},
+			{ID: "monitored", Status: model.ItemStatusMonitored, QualityProfileID: 1},
+			{ID: "below-cutoff", Status: model.ItemStatusDownloaded, QualityProfileID: 1},
+			{ID: "cutoff-met", Status: model.ItemStatusDownloaded, QualityProfileID: 1},
+			{ID: "episode-below-cutoff", Status: model.ItemStatusDownloaded, QualityProfileID: 1},
+			{ID: "without-files", Status: model.ItemStatusDownloaded, QualityProfileID: 1},
+			{ID: "without-cutoff", Status: model.ItemStatusDownloaded, QualityProfileID: 2},
+		},
+		files: map[string][]*model.MediaFile{
+			"below-cutoff": {{Quality: "HDTV-720p"}},
+			"cutoff-met":   {{Quality: "Bluray-1080p"}},
+			"episode-below-cutoff": {
+				{Quality: "Bluray-1080p"},
+				{Quality: "WEB-720p"},
+			},
+			"without-cutoff": {{Quality: "DVD"}},
+		},
+		profiles: map[int]*model.QualityProfile{
+			1: {ID: 1, Cutoff: "Bluray-1080p"},
+			2: {ID: 2},
+		},
+	}
+	var wanted []string
+	// a page size below the number of items checks the paging
+	err := Wanted(db, 2, func(item *model.Item) error {
+		wanted = append(wanted, item.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"added", "monitored", "below-cutoff", "episode-below-cutoff"}
+	if !reflect.DeepEqual(wanted, expected) {
+		t.Errorf("wanted %v, expected %v", wanted, expected)
+	}
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"net/http"
+)
+
+// DiscordNotifier posts messages as embeds to a Discord webhook.
+type DiscordNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewDiscordNotifier(webhookURL string, client *http.Client) *DiscordNotifier {
+	return &DiscordNotifier{
+		url:    webhookURL,
+		client: defaultClient(client),
+	}
+}
+
+type discordImage struct {
+	URL string `json:"url"`
+}
+
+type discordEmbed struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Thumbnail   *discordImage `json:"thumbnail,omitempty"`
+}
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+func (n *DiscordNotifier) Notify(message Message) error {
+	embed := discordEmbed{
+		Title:       message.Title,
+		Description: message.Body,
+	}
+	if message.ImageURL != "" {
+		embed.Thumbnail = &discordImage{URL: message.ImageURL}
+	}
+	return postJSON(n.client, n.url, discordMessage{Embeds: []discordEmbed{embed}}, nil)
+}
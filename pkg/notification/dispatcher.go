@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Events are the events notifications are sent for.
+var Events = []model.EventType{
+	model.EventGrabbed,
+	model.EventDownloaded,
+	model.EventImported,
+	model.EventUpgraded,
+	model.EventFailed,
+	model.EventFileDeleted,
+}
+
+// Dispatcher renders events of the event bus into messages and sends them to
+// all notifiers.
+type Dispatcher struct {
+	db     database.Database
+	logger log.FieldLogger
+
+	mu           sync.RWMutex
+	notifiers    map[string]Notifier
+	templates    *Templates
+	imageBaseURL string
+}
+
+func NewDispatcher(db database.Database, notifiers map[string]Notifier, templates *Templates, logger log.FieldLogger) *Dispatcher {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	if templates == nil {
+		templates, _ = NewTemplates("", "")
+	}
+	return &Dispatcher{
+		db:           db,
+		logger:       logger.WithField("component", "NotificationDispatcher"),
+		notifiers:    notifiers,
+		templates:    templates,
+		imageBaseURL: DefaultImageBaseURL,
+	}
+}
+
+func (d *Dispatcher) SetNotifiers(notifiers map[string]Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers = notifiers
+}
+
+// SetTemplates replaces the templates and the base URL relative image paths of
+// items are resolved against.
+func (d *Dispatcher) SetTemplates(templates *Templates, imageBaseURL string) {
+	if imageBaseURL == "" {
+		imageBaseURL = DefaultImageBaseURL
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.templates = templates
+	d.imageBaseURL = imageBaseURL
+}
+
+// Run sends notifications for the events published on the bus until stop is
+// closed.
+func (d *Dispatcher) Run(bus *event.Bus, stop <-chan struct{}) {
+	subscription, _ := bus.Subscribe(event.Filter{Types: Events}, 0)
+	defer subscription.Close()
+	for {
+		select {
+		case e, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			d.Dispatch(e)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Dispatch sends a notification for an event to every notifier in the
+// background.
+func (d *Dispatcher) Dispatch(e model.Event) {
+	logger := d.logger.WithFields(log.Fields{
+		"event": e.ID,
+		"item":  e.ItemID,
+	})
+	d.mu.RLock()
+	notifiers, templates, imageBaseURL := d.notifiers, d.templates, d.imageBaseURL
+	d.mu.RUnlock()
+	if len(notifiers) == 0 {
+		return
+	}
+	item, err := d.db.GetItem(e.ItemID)
+	if err != nil {
+		logger.Error("get item: ", err)
+		return
+	}
+	message, err := templates.Render(newTemplateData(e, item, imageBaseURL))
+	if err != nil {
+		logger.Error("render notification: ", err)
+		return
+	}
+	for name, notifier := range notifiers {
+		go func(name string, notifier Notifier) {
+			if err := notifier.Notify(message); err != nil {
+				logger.WithField("notifier", name).Error("notify: ", err)
+			}
+		}(name, notifier)
+	}
+}
@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"net/http"
+	"strings"
+)
+
+type GotifyOptions struct {
+	// Base URL of the Gotify server
+	BaseURL string
+	// Token of the application messages are sent as
+	Token    string
+	Priority int
+}
+
+// GotifyNotifier sends messages to a Gotify server.
+type GotifyNotifier struct {
+	options GotifyOptions
+	client  *http.Client
+}
+
+func NewGotifyNotifier(options GotifyOptions, client *http.Client) *GotifyNotifier {
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	return &GotifyNotifier{
+		options: options,
+		client:  defaultClient(client),
+	}
+}
+
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras,omitempty"`
+}
+
+func (n *GotifyNotifier) Notify(message Message) error {
+	request := gotifyMessage{
+		Title:    message.Title,
+		Message:  message.Body,
+		Priority: n.options.Priority,
+	}
+	if message.ImageURL != "" {
+		request.Extras = map[string]interface{}{
+			"client::notification": map[string]string{
+				"bigImageUrl": message.ImageURL,
+			},
+		}
+	}
+	return postJSON(n.client, n.options.BaseURL+"/message", request, http.Header{
+		"X-Gotify-Key": {n.options.Token},
+	})
+}
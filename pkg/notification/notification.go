@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Message is a rendered notification.
+type Message struct {
+	Title string
+	Body  string
+	// Absolute URL of the poster of the item, empty if unknown
+	ImageURL string
+}
+
+type Notifier interface {
+	Notify(message Message) error
+}
+
+func defaultClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{
+			Timeout: defaultTimeout,
+		}
+	}
+	return client
+}
+
+// postJSON posts v as JSON and fails on responses other than 2xx.
+func postJSON(client *http.Client, url string, v interface{}, header http.Header) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", "application/json")
+	return post(client, url, bytes.NewReader(body), header)
+}
+
+func post(client *http.Client, url string, body io.Reader, header http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
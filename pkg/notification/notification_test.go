@@ -0,0 +1,269 @@
+package notification
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// receivedRequest is a request of a notifier to the local stand-in of its
+// service.
+type receivedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// standIn records the requests of notifiers and answers them with status.
+func standIn(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	requests := make(chan receivedRequest, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		requests <- receivedRequest{path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+// itemDB returns the item of the notifications, other methods of the
+// database are not used by the dispatcher.
+type itemDB struct {
+	database.Database
+	item *model.Item
+}
+
+func (d itemDB) GetItem(id string) (*model.Item, error) {
+	return d.item, nil
+}
+
+var (
+	testItem = &model.Item{
+		ID:          "5c7a1a4e-2b8f-4d3e-9a61-0f0c7f3b2d11",
+		Title:       "Heat",
+		ReleaseYear: 1995,
+		ImagePath:   "/poster.jpg",
+	}
+	grabbed = model.Event{
+		ID:     1,
+		Type:   model.EventGrabbed,
+		ItemID: testItem.ID,
+		Date:   time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Data: model.GrabbedData{
+			Indexer: "rss",
+			Release: "Heat.1995.1080p.BluRay-GROUP",
+			Quality: "Bluray-1080p",
+		},
+	}
+)
+
+const (
+	expectedTitle = "Grabbed Heat (1995)"
+	expectedBody  = "Heat.1995.1080p.BluRay-GROUP in Bluray-1080p"
+	expectedImage = "http://images.local/w500/poster.jpg"
+)
+
+// TestDispatch renders an event with the default templates and sends it to
+// every kind of notifier, which post it to local stand-ins of their services.
+func TestDispatch(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	defer server.Close()
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	dispatcher := NewDispatcher(itemDB{item: testItem}, map[string]Notifier{
+		"discord":  NewDiscordNotifier(server.URL+"/discord", nil),
+		"slack":    NewSlackNotifier(server.URL+"/slack", nil),
+		"telegram": NewTelegramNotifier(TelegramOptions{BaseURL: server.URL + "/telegram/", Token: "token", ChatID: "chat"}, nil),
+		"gotify":   NewGotifyNotifier(GotifyOptions{BaseURL: server.URL + "/gotify", Token: "app", Priority: 5}, nil),
+		"ntfy":     NewNtfyNotifier(NtfyOptions{BaseURL: server.URL + "/ntfy", Topic: "godarr", Token: "secret"}, nil),
+	}, nil, logger)
+	templates, err := NewTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.SetTemplates(templates, "http://images.local/w500/")
+	dispatcher.Dispatch(grabbed)
+	received := map[string]receivedRequest{}
+	for len(received) < 5 {
+		select {
+		case request := <-requests:
+			received[request.path] = request
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received only %d notifications", len(received))
+		}
+	}
+
+	for path, expected := range map[string]interface{}{
+		"/discord": discordMessage{Embeds: []discordEmbed{{
+			Title:       expectedTitle,
+			Description: expectedBody,
+			Thumbnail:   &discordImage{URL: expectedImage},
+		}}},
+		"/slack": slackMessage{
+			Text: expectedTitle,
+			Blocks: []slackBlock{{
+				Type:      "section",
+				Text:      &slackText{Type: "mrkdwn", Text: "*" + expectedTitle + "*\n" + expectedBody},
+				Accessory: &slackImage{Type: "image", ImageURL: expectedImage, AltText: expectedTitle},
+			}},
+		},
+		"/telegram/bottoken/sendPhoto": telegramMessage{
+			ChatID:  "chat",
+			Photo:   expectedImage,
+			Caption: expectedTitle + "\n" + expectedBody,
+		},
+		"/gotify/message": gotifyMessage{
+			Title:    expectedTitle,
+			Message:  expectedBody,
+			Priority: 5,
+			Extras: map[string]interface{}{
+				"client::notification": map[string]interface{}{"bigImageUrl": expectedImage},
+			},
+		},
+	} {
+		request, ok := received[path]
+		if !ok {
+			t.Errorf("no request to %s, got %v", path, keys(received))
+			continue
+		}
+		if contentType := request.header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s: content type %q", path, contentType)
+		}
+		actual := reflect.New(reflect.TypeOf(expected))
+		if err := json.Unmarshal(request.body, actual.Interface()); err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual.Elem().Interface(), expected) {
+			t.Errorf("%s: got %s, expected %+v", path, request.body, expected)
+		}
+	}
+	if key := received["/gotify/message"].header.Get("X-Gotify-Key"); key != "app" {
+		t.Errorf("gotify: key %q", key)
+	}
+
+	ntfy, ok := received["/ntfy/godarr"]
+	if !ok {
+		t.Fatalf("no request to /ntfy/godarr, got %v", keys(received))
+	}
+	for header, expected := range map[string]string{
+		"Title":         expectedTitle,
+		"Attach":        expectedImage,
+		"Authorization": "Bearer secret",
+	} {
+		if value := ntfy.header.Get(header); value != expected {
+			t.Errorf("ntfy: header %s %q, expected %q", header, value, expected)
+		}
+	}
+	if string(ntfy.body) != expectedBody {
+		t.Errorf("ntfy: body %q, expected %q", ntfy.body, expectedBody)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates(
+		`[{{.Event.Type}}] {{.Title}}`,
+		`{{.Action}} in {{.Quality}}{{if .ImageURL}} {{.ImageURL}}{{end}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]struct {
+		event     model.Event
+		item      *model.Item
+		base      string
+		expected  Message
+		expectErr bool
+	}{
+		"relative image path": {
+			event: grabbed,
+			item:  testItem,
+			base:  DefaultImageBaseURL,
+			expected: Message{
+				Title:    "[grabbed] Heat",
+				Body:     "Grabbed in Bluray-1080p https://image.tmdb.org/t/p/w500/poster.jpg",
+				ImageURL: "https://image.tmdb.org/t/p/w500/poster.jpg",
+			},
+		},
+		"absolute image URL": {
+			event: model.Event{Type: model.EventUpgraded, Data: model.UpgradedData{OldQuality: "WEB-720p", NewQuality: "Bluray-2160p"}},
+			item:  &model.Item{Title: "Heat", ImagePath: "https://artworks.thetvdb.com/poster.jpg"},
+			base:  DefaultImageBaseURL,
+			expected: Message{
+				Title:    "[upgraded] Heat",
+				Body:     "Upgraded in Bluray-2160p https://artworks.thetvdb.com/poster.jpg",
+				ImageURL: "https://artworks.thetvdb.com/poster.jpg",
+			},
+		},
+		"without image and title": {
+			event: model.Event{Type: model.EventImported, Data: model.ImportedData{Quality: "WEB-1080p"}},
+			item:  &model.Item{ExternalID: "tt0113277"},
+			expected: Message{
+				Title: "[imported] tt0113277",
+				Body:  "Imported in WEB-1080p",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			message, err := templates.Render(newTemplateData(c.event, c.item, c.base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message != c.expected {
+				t.Errorf("got %+v, expected %+v", message, c.expected)
+			}
+		})
+	}
+	if _, err := NewTemplates("{{.Title", ""); err == nil {
+		t.Error("invalid template was parsed")
+	}
+}
+
+func TestTelegramWithoutImage(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	defer server.Close()
+	notifier := NewTelegramNotifier(TelegramOptions{BaseURL: server.URL, Token: "token", ChatID: "chat"}, nil)
+	if err := notifier.Notify(Message{Title: expectedTitle, Body: expectedBody}); err != nil {
+		t.Fatal(err)
+	}
+	request := <-requests
+	if request.path != "/bottoken/sendMessage" {
+		t.Errorf("path %s, expected sendMessage", request.path)
+	}
+	var message telegramMessage
+	if err := json.Unmarshal(request.body, &message); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (telegramMessage{ChatID: "chat", Text: expectedTitle + "\n" + expectedBody}); message != expected {
+		t.Errorf("got %+v, expected %+v", message, expected)
+	}
+}
+
+func TestNotifyError(t *testing.T) {
+	server, _ := standIn(t, http.StatusUnauthorized)
+	defer server.Close()
+	err := NewGotifyNotifier(GotifyOptions{BaseURL: server.URL}, nil).Notify(Message{Title: expectedTitle})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got %v, expected error with the status", err)
+	}
+}
+
+func keys(m map[string]receivedRequest) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
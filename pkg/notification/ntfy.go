@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"net/http"
+	"strings"
+)
+
+const DefaultNtfyBaseURL = "https://ntfy.sh"
+
+type NtfyOptions struct {
+	// Base URL of the ntfy server, defaults to DefaultNtfyBaseURL
+	BaseURL string
+	Topic   string
+	// Access token, only required for protected topics
+	Token string
+}
+
+// NtfyNotifier publishes messages to a ntfy topic.
+type NtfyNotifier struct {
+	options NtfyOptions
+	client  *http.Client
+}
+
+func NewNtfyNotifier(options NtfyOptions, client *http.Client) *NtfyNotifier {
+	if options.BaseURL == "" {
+		options.BaseURL = DefaultNtfyBaseURL
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	return &NtfyNotifier{
+		options: options,
+		client:  defaultClient(client),
+	}
+}
+
+func (n *NtfyNotifier) Notify(message Message) error {
+	header := http.Header{
+		"Title": {message.Title},
+	}
+	if message.ImageURL != "" {
+		header.Set("Attach", message.ImageURL)
+	}
+	if n.options.Token != "" {
+		header.Set("Authorization", "Bearer "+n.options.Token)
+	}
+	body := message.Body
+	if body == "" {
+		// ntfy rejects empty messages
+		body = message.Title
+	}
+	return post(n.client, n.options.BaseURL+"/"+n.options.Topic, strings.NewReader(body), header)
+}
@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"net/http"
+)
+
+// SlackNotifier posts messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackNotifier(webhookURL string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{
+		url:    webhookURL,
+		client: defaultClient(client),
+	}
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackImage struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+type slackBlock struct {
+	Type      string      `json:"type"`
+	Text      *slackText  `json:"text,omitempty"`
+	Accessory *slackImage `json:"accessory,omitempty"`
+}
+
+type slackMessage struct {
+	// Text is shown in notifications of clients
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func (n *SlackNotifier) Notify(message Message) error {
+	text := "*" + message.Title + "*"
+	if message.Body != "" {
+		text += "\n" + message.Body
+	}
+	block := slackBlock{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: text},
+	}
+	if message.ImageURL != "" {
+		block.Accessory = &slackImage{
+			Type:     "image",
+			ImageURL: message.ImageURL,
+			AltText:  message.Title,
+		}
+	}
+	return postJSON(n.client, n.url, slackMessage{
+		Text:   message.Title,
+		Blocks: []slackBlock{block},
+	}, nil)
+}
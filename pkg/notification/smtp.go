@@ -0,0 +1,64 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPOptions struct {
+	// Address of the SMTP server as host:port
+	Address string
+	// Username and password for PLAIN authentication, which is skipped if the
+	// username is empty
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPNotifier sends messages as HTML email.
+type SMTPNotifier struct {
+	options SMTPOptions
+}
+
+func NewSMTPNotifier(options SMTPOptions) *SMTPNotifier {
+	return &SMTPNotifier{
+		options: options,
+	}
+}
+
+func (n *SMTPNotifier) Notify(message Message) error {
+	var auth smtp.Auth
+	if n.options.Username != "" {
+		host, _, err := net.SplitHostPort(n.options.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.options.Username, n.options.Password, host)
+	}
+	return smtp.SendMail(n.options.Address, auth, n.options.From, n.options.To, n.mail(message))
+}
+
+func (n *SMTPNotifier) mail(message Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.options.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.options.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "<h2>%s</h2>\r\n", html.EscapeString(message.Title))
+	if message.ImageURL != "" {
+		fmt.Fprintf(&b, "<img src=\"%s\" alt=\"\" width=\"200\">\r\n", html.EscapeString(message.ImageURL))
+	}
+	if message.Body != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\r\n", strings.Replace(html.EscapeString(message.Body), "\n", "<br>", -1))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"net/http"
+	"strings"
+)
+
+const DefaultTelegramBaseURL = "https://api.telegram.org"
+
+type TelegramOptions struct {
+	// Base URL of the Bot API, defaults to DefaultTelegramBaseURL
+	BaseURL string
+	Token   string
+	ChatID  string
+}
+
+// TelegramNotifier sends messages to a chat via the Telegram Bot API. Messages
+// with an image are sent as photo with the message as caption.
+type TelegramNotifier struct {
+	options TelegramOptions
+	client  *http.Client
+}
+
+func NewTelegramNotifier(options TelegramOptions, client *http.Client) *TelegramNotifier {
+	if options.BaseURL == "" {
+		options.BaseURL = DefaultTelegramBaseURL
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	return &TelegramNotifier{
+		options: options,
+		client:  defaultClient(client),
+	}
+}
+
+type telegramMessage struct {
+	ChatID  string `json:"chat_id"`
+	Text    string `json:"text,omitempty"`
+	Photo   string `json:"photo,omitempty"`
+	Caption string `json:"caption,omitempty"`
+}
+
+func (n *TelegramNotifier) Notify(message Message) error {
+	text := message.Title
+	if message.Body != "" {
+		text += "\n" + message.Body
+	}
+	method := "sendMessage"
+	request := telegramMessage{
+		ChatID: n.options.ChatID,
+		Text:   text,
+	}
+	if message.ImageURL != "" {
+		method = "sendPhoto"
+		request = telegramMessage{
+			ChatID:  n.options.ChatID,
+			Photo:   message.ImageURL,
+			Caption: text,
+		}
+	}
+	return postJSON(n.client, n.options.BaseURL+"/bot"+n.options.Token+"/"+method, request, nil)
+}
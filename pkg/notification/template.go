@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	DefaultTitleTemplate = `{{.Action}} {{.Title}}{{if .Item.ReleaseYear}} ({{.Item.ReleaseYear}}){{end}}`
+	DefaultBodyTemplate  = `{{if .Release}}{{.Release}}{{end}}` +
+		`{{if .Quality}}{{if .Release}} in {{end}}{{.Quality}}{{end}}` +
+		`{{if .Error}}{{"\n"}}{{.Error}}{{end}}`
+	// DefaultImageBaseURL is prepended to relative image paths of items
+	DefaultImageBaseURL = "https://image.tmdb.org/t/p/w500"
+)
+
+var actions = map[model.EventType]string{
+	model.EventGrabbed:     "Grabbed",
+	model.EventDownloaded:  "Downloaded",
+	model.EventImported:    "Imported",
+	model.EventUpgraded:    "Upgraded",
+	model.EventFailed:      "Failed",
+	model.EventFileDeleted: "Deleted file of",
+}
+
+// TemplateData is passed to the templates of notifications.
+type TemplateData struct {
+	Event model.Event
+	Item  *model.Item
+	// Human readable description of the event, like Grabbed or Imported
+	Action   string
+	Title    string
+	Release  string
+	Quality  model.Quality
+	Error    string
+	ImageURL string
+}
+
+func newTemplateData(event model.Event, item *model.Item, imageBaseURL string) TemplateData {
+	data := TemplateData{
+		Event:  event,
+		Item:   item,
+		Action: actions[event.Type],
+		Title:  item.Title,
+	}
+	if data.Title == "" {
+		data.Title = item.ExternalID
+	}
+	switch path := item.ImagePath; {
+	case path == "":
+	case strings.HasPrefix(path, "http://"), strings.HasPrefix(path, "https://"):
+		data.ImageURL = path
+	default:
+		data.ImageURL = strings.TrimSuffix(imageBaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+	}
+	switch payload := event.Data.(type) {
+	case model.GrabbedData:
+		data.Release = payload.Release
+		data.Quality = payload.Quality
+	case model.DownloadedData:
+		data.Release = payload.Release
+	case model.ImportedData:
+		data.Quality = payload.Quality
+	case model.UpgradedData:
+		data.Quality = payload.NewQuality
+	case model.FailedData:
+		data.Release = payload.Release
+		data.Error = payload.Error
+	case model.DeletedData:
+		data.Error = payload.Reason
+	}
+	return data
+}
+
+// Templates render the title and body of notifications from TemplateData.
+type Templates struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// NewTemplates parses the title and body templates, empty templates are
+// replaced by the defaults.
+func NewTemplates(title, body string) (*Templates, error) {
+	if title == "" {
+		title = DefaultTitleTemplate
+	}
+	if body == "" {
+		body = DefaultBodyTemplate
+	}
+	t := &Templates{}
+	var err error
+	if t.title, err = template.New("title").Parse(title); err != nil {
+		return nil, err
+	}
+	if t.body, err = template.New("body").Parse(body); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Templates) Render(data TemplateData) (Message, error) {
+	var title, body bytes.Buffer
+	if err := t.title.Execute(&title, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Title:    strings.TrimSpace(title.String()),
+		Body:     strings.TrimSpace(body.String()),
+		ImageURL: data.ImageURL,
+	}, nil
+}
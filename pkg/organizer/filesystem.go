@@ -0,0 +1,373 @@
+package organizer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/library"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+var invalidPathCharacters = strings.NewReplacer(
+	"<", "", ">", "", ":", "", `"`, "", "/", "", `\`, "", "|", "", "?", "", "*", "",
+)
+
+type FileSystemOrganizerOptions struct {
+	// Library root folders by the kind of the items they contain
+	Roots map[model.ItemKind]string
+	// Hard link files instead of moving them
+	Hardlink bool
+	// Folder replaced files are moved to, they are deleted if empty
+	RecycleBin string
+	// Names of folders and files, the default naming is used if nil
+	Naming *Naming
+}
+
+// FileSystemOrganizer moves or hard links downloaded files into a library
+// root per item kind and records them as media files. Existing files of an
+// item are replaced and moved to the recycle bin.
+type FileSystemOrganizer struct {
+	db      database.Database
+	history *history.Recorder
+	logger  log.FieldLogger
+
+	mu      sync.RWMutex
+	options FileSystemOrganizerOptions
+}
+
+func NewFileSystemOrganizer(db database.Database, history *history.Recorder, options FileSystemOrganizerOptions, logger log.FieldLogger) *FileSystemOrganizer {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	o := &FileSystemOrganizer{
+		db:      db,
+		history: history,
+		logger:  logger.WithField("component", "FileSystemOrganizer"),
+	}
+	o.SetOptions(options)
+	return o
+}
+
+// SetOptions replaces the options, downloads which are currently organized
+// keep using the previous options.
+func (o *FileSystemOrganizer) SetOptions(options FileSystemOrganizerOptions) {
+	if options.Naming == nil {
+		options.Naming = defaultNaming
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.options = options
+}
+
+func (o *FileSystemOrganizer) Organize(download model.CompletedDownload) error {
+	o.mu.RLock()
+	options := o.options
+	o.mu.RUnlock()
+	item := download.Release.Item
+	naming := options.Naming
+	root, ok := options.Roots[item.Kind]
+	if !ok {
+		return fmt.Errorf("no library root for kind %s", item.Kind)
+	}
+	files, err := videoFiles(download.Path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no video files in %s", download.Path)
+	}
+	quality := download.Release.Quality
+	if quality == "" {
+		quality = library.ParseQuality(download.Release.Title)
+	}
+	if item.Path == "" {
+		item.Path = filepath.Join(root, naming.ItemFolder(item))
+		if err := o.db.SetItemPath(item.ID, item.Path); err != nil {
+			return err
+		}
+	}
+	existing, err := o.db.ListMediaFiles(item.ID)
+	if err != nil {
+		return err
+	}
+	switch item.Kind {
+	case model.ItemKindMovie:
+		// the largest file is the movie, everything else are samples or extras
+		largest := files[0]
+		for _, file := range files[1:] {
+			if file.size > largest.size {
+				largest = file
+			}
+		}
+		destination := filepath.Join(item.Path, naming.MovieFile(item, quality)+filepath.Ext(largest.path))
+		replaced := superseded(existing, destination, quality)
+		if err := o.importFile(options, item, download.Release, largest.path, destination, nil, nil, replaced); err != nil {
+			return err
+		}
+	case model.ItemKindTVSeries:
+		imported := 0
+		for _, file := range files {
+			season, episode, ok := library.ParseEpisode(filepath.Base(file.path))
+			if !ok {
+				o.logger.WithField("path", file.path).Warn("could not parse episode from file name")
+				continue
+			}
+			destination := filepath.Join(
+				item.Path,
+				naming.SeasonFolder(item, season),
+				naming.EpisodeFile(item, season, episode, quality)+filepath.Ext(file.path),
+			)
+			var replaced []*model.MediaFile
+			for _, file := range existing {
+				if file.SeasonNumber != nil && *file.SeasonNumber == season &&
+					file.EpisodeNumber != nil && *file.EpisodeNumber == episode {
+					replaced = append(replaced, file)
+				}
+			}
+			if err := o.importFile(options, item, download.Release, file.path, destination, &season, &episode, replaced); err != nil {
+				return err
+			}
+			imported++
+		}
+		if imported == 0 {
+			return fmt.Errorf("no episodes found in %s", download.Path)
+		}
+	default:
+		return fmt.Errorf("invalid kind: %v", item.Kind)
+	}
+	return o.db.SetItemStatus(item.ID, model.ItemStatusDownloaded)
+}
+
+// superseded returns the media file of a movie which an import of the
+// quality to destination replaces: the file at the destination, or else the
+// file of the lowest quality if it is below the imported one. Other files,
+// like further editions, are kept.
+func superseded(existing []*model.MediaFile, destination string, quality model.Quality) []*model.MediaFile {
+	var lowest *model.MediaFile
+	for _, file := range existing {
+		if file.Path == destination {
+			return []*model.MediaFile{file}
+		}
+		if lowest == nil || file.Quality.Rank() < lowest.Quality.Rank() {
+			lowest = file
+		}
+	}
+	if lowest == nil || lowest.Quality.Rank() >= quality.Rank() {
+		return nil
+	}
+	return []*model.MediaFile{lowest}
+}
+
+// importFile moves source to destination and records it as media file. The
+// replaced files are moved to the recycle bin and the upgrade is recorded in
+// the history of the item.
+//
+// The source is transferred to a temporary name next to the destination
+// first, so the replaced files are only recycled once the new file is
+// complete. It is renamed into place afterwards, which does not copy as both
+// names are in the same directory. If recycling or the rename fails, the
+// recycled files are moved back and the source is restored.
+func (o *FileSystemOrganizer) importFile(options FileSystemOrganizerOptions, item *model.Item, release model.Release, source, destination string, season, episode *int, replaced []*model.MediaFile) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return err
+	}
+	partial := filepath.Join(filepath.Dir(destination), "."+filepath.Base(destination)+".partial")
+	if err := transfer(options.Hardlink, source, partial); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	var recycled []recycledFile
+	for _, file := range replaced {
+		r, err := o.recycle(options, item, file)
+		if err != nil {
+			o.unrecycle(recycled)
+			o.restore(options.Hardlink, partial, source)
+			return err
+		}
+		recycled = append(recycled, r)
+	}
+	if err := os.Rename(partial, destination); err != nil {
+		o.unrecycle(recycled)
+		o.restore(options.Hardlink, partial, source)
+		return err
+	}
+	info, err := os.Stat(destination)
+	if err != nil {
+		return err
+	}
+	name := release.Title
+	if name == "" {
+		name = filepath.Base(source)
+	}
+	quality := release.Quality
+	if quality == "" {
+		quality = library.ParseQuality(name)
+	}
+	if _, err := o.db.CreateMediaFile(&model.MediaFile{
+		ID:            uuid.NewV4().String(),
+		ItemID:        item.ID,
+		SeasonNumber:  season,
+		EpisodeNumber: episode,
+		Path:          destination,
+		Size:          info.Size(),
+		Quality:       quality,
+		ReleaseGroup:  library.ParseReleaseGroup(name),
+		ImportedAt:    time.Now().UTC(),
+		ModifiedAt:    info.ModTime().UTC().Truncate(time.Microsecond),
+	}); err != nil {
+		return err
+	}
+	o.logger.WithFields(log.Fields{
+		"source":      source,
+		"destination": destination,
+	}).Info("imported file")
+	o.history.Imported(item.ID, source, destination, quality)
+	for _, r := range recycled {
+		if err := o.finishRecycle(r); err != nil {
+			return err
+		}
+		o.history.Upgraded(item.ID, r.file, destination, quality)
+	}
+	return nil
+}
+
+// recycledFile is a replaced media file which was moved out of the way of an
+// import.
+type recycledFile struct {
+	file *model.MediaFile
+	// path the file was moved to, empty if it did not exist
+	path string
+	// whether the file is deleted once the import succeeded, since no
+	// recycle bin is configured
+	temporary bool
+}
+
+// recycle moves a replaced media file into the recycle bin. Without a recycle
+// bin it is moved to a temporary name next to it and deleted by finishRecycle
+// once the import succeeded, so it can be restored until then.
+func (o *FileSystemOrganizer) recycle(options FileSystemOrganizerOptions, item *model.Item, file *model.MediaFile) (recycledFile, error) {
+	r := recycledFile{file: file}
+	if options.RecycleBin == "" {
+		r.path = filepath.Join(filepath.Dir(file.Path), "."+filepath.Base(file.Path)+".replaced")
+		r.temporary = true
+	} else {
+		r.path = filepath.Join(options.RecycleBin, options.Naming.ItemFolder(item), filepath.Base(file.Path))
+		if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+			return r, err
+		}
+	}
+	if err := move(file.Path, r.path); err != nil {
+		if !os.IsNotExist(err) {
+			return r, err
+		}
+		r.path = ""
+	}
+	return r, nil
+}
+
+// unrecycle moves recycled files back after a failed import.
+func (o *FileSystemOrganizer) unrecycle(recycled []recycledFile) {
+	for _, r := range recycled {
+		if r.path == "" {
+			continue
+		}
+		if err := move(r.path, r.file.Path); err != nil {
+			o.logger.WithField("path", r.file.Path).Error("restore replaced file: ", err)
+		}
+	}
+}
+
+// finishRecycle removes the record of a recycled file and deletes it if no
+// recycle bin is configured.
+func (o *FileSystemOrganizer) finishRecycle(r recycledFile) error {
+	if r.temporary && r.path != "" {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	o.logger.WithField("path", r.file.Path).Info("recycled replaced file")
+	return o.db.DeleteMediaFile(r.file.ID)
+}
+
+// restore undoes the transfer of source to partial after a failed import, so
+// the download can be imported again.
+func (o *FileSystemOrganizer) restore(hardlink bool, partial, source string) {
+	var err error
+	if hardlink {
+		err = os.Remove(partial)
+	} else {
+		err = move(partial, source)
+	}
+	if err != nil {
+		o.logger.WithField("path", partial).Error("restore partial import: ", err)
+	}
+}
+
+func transfer(hardlink bool, source, destination string) error {
+	if hardlink {
+		if err := os.Link(source, destination); err == nil {
+			return nil
+		}
+		return copyFile(source, destination)
+	}
+	return move(source, destination)
+}
+
+func move(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+	// rename fails across file systems, fall back to copying
+	if err := copyFile(source, destination); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+func copyFile(source, destination string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+type videoFile struct {
+	path string
+	size int64
+}
+
+func videoFiles(root string) ([]videoFile, error) {
+	var files []videoFile
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && library.IsVideo(info.Name()) {
+			files = append(files, videoFile{path: path, size: info.Size()})
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,216 @@
+package organizer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// organizeDB keeps the media files of a single item in memory, other methods
+// of the database are not used by the organizer.
+type organizeDB struct {
+	database.Database
+	files []*model.MediaFile
+}
+
+func (d *organizeDB) ListMediaFiles(itemID string) ([]*model.MediaFile, error) {
+	return d.files, nil
+}
+
+func (d *organizeDB) CreateMediaFile(file *model.MediaFile) (*model.MediaFile, error) {
+	d.files = append(d.files, file)
+	return file, nil
+}
+
+func (d *organizeDB) DeleteMediaFile(id string) error {
+	for i, file := range d.files {
+		if file.ID == id {
+			d.files = append(d.files[:i], d.files[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (d *organizeDB) SetItemStatus(id string, status model.ItemStatus) error {
+	return nil
+}
+
+func (d *organizeDB) AddHistory(entry *model.HistoryEntry) error {
+	return nil
+}
+
+// upgrade prepares a download which upgrades an existing movie file of the
+// same name and returns the organizer, the download and the existing file.
+func upgrade(t *testing.T, dir string) (*FileSystemOrganizer, *organizeDB, model.CompletedDownload, string) {
+	item := &model.Item{
+		ID:          "heat",
+		Kind:        model.ItemKindMovie,
+		Title:       "Heat",
+		ReleaseYear: 1995,
+		Path:        filepath.Join(dir, "movies", "Heat (1995)"),
+	}
+	quality := model.Quality("Bluray-1080p")
+	existing := filepath.Join(item.Path, defaultNaming.MovieFile(item, quality)+".mkv")
+	download := filepath.Join(dir, "downloads", "Heat.1995.1080p.BluRay-GROUP")
+	for path, content := range map[string]string{
+		existing: "old",
+		filepath.Join(download, "Heat.1995.1080p.BluRay-GROUP.mkv"): "new",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &organizeDB{files: []*model.MediaFile{{ID: "old", ItemID: item.ID, Path: existing, Quality: "WEB-720p"}}}
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	o := NewFileSystemOrganizer(db, history.NewRecorder(db, nil, logger), FileSystemOrganizerOptions{
+		Roots:      map[model.ItemKind]string{model.ItemKindMovie: filepath.Join(dir, "movies")},
+		RecycleBin: filepath.Join(dir, "recycle"),
+	}, logger)
+	return o, db, model.CompletedDownload{
+		Release: model.Release{Item: item, Title: "Heat.1995.1080p.BluRay-GROUP", Quality: quality},
+		Path:    download,
+	}, existing
+}
+
+func TestOrganizeUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godarr-organize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o, db, download, existing := upgrade(t, dir)
+	if err := o.Organize(download); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		existing: "new",
+		filepath.Join(dir, "recycle", "Heat (1995)", filepath.Base(existing)): "old",
+	} {
+		if content, err := ioutil.ReadFile(path); err != nil || string(content) != expected {
+			t.Errorf("%s contains %q (%v), expected %q", path, content, err, expected)
+		}
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(existing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the item folder, expected only the imported one", len(entries))
+	}
+	if len(db.files) != 1 || db.files[0].ID == "old" || db.files[0].Path != existing {
+		t.Errorf("unexpected media files %+v", db.files)
+	}
+}
+
+func TestOrganizeFailedTransferKeepsReplacedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godarr-organize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o, db, download, existing := upgrade(t, dir)
+	// a directory in place of the temporary file makes the transfer fail
+	partial := filepath.Join(filepath.Dir(existing), "."+filepath.Base(existing)+".partial")
+	if err := os.MkdirAll(filepath.Join(partial, "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Organize(download); err == nil {
+		t.Fatal("organize did not fail")
+	}
+	if content, err := ioutil.ReadFile(existing); err != nil || string(content) != "old" {
+		t.Errorf("replaced file contains %q (%v), expected it to be kept", content, err)
+	}
+	if len(db.files) != 1 || db.files[0].ID != "old" {
+		t.Errorf("unexpected media files %+v", db.files)
+	}
+	if _, err := os.Stat(filepath.Join(download.Path, "Heat.1995.1080p.BluRay-GROUP.mkv")); err != nil {
+		t.Errorf("download was not kept: %v", err)
+	}
+}
+
+func TestOrganizeKeepsOtherEditions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godarr-organize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o, db, download, existing := upgrade(t, dir)
+	// the existing file is moved aside, so only the other editions remain
+	low := filepath.Join(filepath.Dir(existing), "Heat (1995) SDTV.avi")
+	edition := filepath.Join(filepath.Dir(existing), "Heat (1995) Director's Cut Bluray-2160p.mkv")
+	for _, path := range []string{low, edition} {
+		if err := ioutil.WriteFile(path, []byte("other"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+	db.files = []*model.MediaFile{
+		{ID: "low", ItemID: "heat", Path: low, Quality: "SDTV"},
+		{ID: "edition", ItemID: "heat", Path: edition, Quality: "Bluray-2160p"},
+	}
+	if err := o.Organize(download); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(edition); err != nil {
+		t.Errorf("other edition was not kept: %v", err)
+	}
+	if _, err := os.Stat(low); !os.IsNotExist(err) {
+		t.Errorf("superseded file was not recycled: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, file := range db.files {
+		ids[file.ID] = true
+	}
+	if len(db.files) != 2 || !ids["edition"] || ids["low"] {
+		t.Errorf("unexpected media files %+v", db.files)
+	}
+}
+
+func TestImportFileFailedRecycleRestoresFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godarr-organize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o, db, download, existing := upgrade(t, dir)
+	other := filepath.Join(filepath.Dir(existing), "Heat (1995) WEB-720p.mkv")
+	if err := ioutil.WriteFile(other, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a directory in the recycle bin in place of the second file makes its
+	// recycling fail after the first one was moved
+	if err := os.MkdirAll(filepath.Join(dir, "recycle", "Heat (1995)", filepath.Base(other), "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	replaced := []*model.MediaFile{db.files[0], {ID: "other", ItemID: "heat", Path: other}}
+	source := filepath.Join(download.Path, "Heat.1995.1080p.BluRay-GROUP.mkv")
+	if err := o.importFile(o.options, download.Release.Item, download.Release, source, existing, nil, nil, replaced); err == nil {
+		t.Fatal("import did not fail")
+	}
+	for path, expected := range map[string]string{
+		existing: "old",
+		other:    "other",
+		source:   "new",
+	} {
+		if content, err := ioutil.ReadFile(path); err != nil || string(content) != expected {
+			t.Errorf("%s contains %q (%v), expected %q", path, content, err, expected)
+		}
+	}
+	if len(db.files) != 1 || db.files[0].ID != "old" {
+		t.Errorf("unexpected media files %+v", db.files)
+	}
+}
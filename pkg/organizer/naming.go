@@ -0,0 +1,135 @@
+package organizer
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	DefaultMovieFolder  = `{{.Title}}{{if .Year}} ({{.Year}}){{end}}`
+	DefaultMovieFile    = DefaultMovieFolder
+	DefaultSeriesFolder = DefaultMovieFolder
+	DefaultSeasonFolder = `Season {{printf "%02d" .Season}}`
+	DefaultEpisodeFile  = `{{.Title}}{{if .Year}} ({{.Year}}){{end}} - S{{printf "%02d" .Season}}E{{printf "%02d" .Episode}}`
+)
+
+// NamingOptions are templates for the names of folders and files in the
+// library, they are executed with NamingData. File templates must not contain
+// the extension.
+type NamingOptions struct {
+	MovieFolder  string
+	MovieFile    string
+	SeriesFolder string
+	SeasonFolder string
+	EpisodeFile  string
+}
+
+type NamingData struct {
+	Title      string
+	Year       int
+	ExternalID string
+	Season     int
+	Episode    int
+	Quality    model.Quality
+}
+
+// Naming renders the names of folders and files in the library. Characters
+// which are invalid in paths are removed from the rendered names.
+type Naming struct {
+	movieFolder  *template.Template
+	movieFile    *template.Template
+	seriesFolder *template.Template
+	seasonFolder *template.Template
+	episodeFile  *template.Template
+}
+
+// NewNaming parses the naming templates, empty templates are replaced by the
+// defaults.
+func NewNaming(options NamingOptions) (*Naming, error) {
+	n := &Naming{}
+	for _, t := range []struct {
+		template **template.Template
+		name     string
+		text     string
+		fallback string
+	}{
+		{&n.movieFolder, "movieFolder", options.MovieFolder, DefaultMovieFolder},
+		{&n.movieFile, "movieFile", options.MovieFile, DefaultMovieFile},
+		{&n.seriesFolder, "seriesFolder", options.SeriesFolder, DefaultSeriesFolder},
+		{&n.seasonFolder, "seasonFolder", options.SeasonFolder, DefaultSeasonFolder},
+		{&n.episodeFile, "episodeFile", options.EpisodeFile, DefaultEpisodeFile},
+	} {
+		text := t.text
+		if text == "" {
+			text = t.fallback
+		}
+		parsed, err := template.New(t.name).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		// execute once to detect references to unknown fields
+		if err := parsed.Execute(&bytes.Buffer{}, NamingData{}); err != nil {
+			return nil, err
+		}
+		*t.template = parsed
+	}
+	return n, nil
+}
+
+var defaultNaming, _ = NewNaming(NamingOptions{})
+
+func newNamingData(item *model.Item) NamingData {
+	data := NamingData{
+		Title:      item.Title,
+		Year:       item.ReleaseYear,
+		ExternalID: item.ExternalID,
+	}
+	if data.Title == "" {
+		data.Title = item.ExternalID
+	}
+	return data
+}
+
+// ItemFolder returns the name of the folder of an item.
+func (n *Naming) ItemFolder(item *model.Item) string {
+	if item.Kind == model.ItemKindTVSeries {
+		return execute(n.seriesFolder, newNamingData(item))
+	}
+	return execute(n.movieFolder, newNamingData(item))
+}
+
+func (n *Naming) MovieFile(item *model.Item, quality model.Quality) string {
+	data := newNamingData(item)
+	data.Quality = quality
+	return execute(n.movieFile, data)
+}
+
+func (n *Naming) SeasonFolder(item *model.Item, season int) string {
+	data := newNamingData(item)
+	data.Season = season
+	return execute(n.seasonFolder, data)
+}
+
+func (n *Naming) EpisodeFile(item *model.Item, season, episode int, quality model.Quality) string {
+	data := newNamingData(item)
+	data.Season = season
+	data.Episode = episode
+	data.Quality = quality
+	return execute(n.episodeFile, data)
+}
+
+func execute(t *template.Template, data NamingData) string {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		// templates are verified when parsed
+		b.Reset()
+	}
+	name := strings.TrimSpace(invalidPathCharacters.Replace(b.String()))
+	if name == "" {
+		name = invalidPathCharacters.Replace(data.ExternalID)
+	}
+	return name
+}
@@ -1,5 +1,9 @@
 package organizer
 
+import (
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
 type Organizer interface {
-	Organize(filePath string) error
+	Organize(download model.CompletedDownload) error
 }
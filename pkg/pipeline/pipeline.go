@@ -0,0 +1,326 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/downloader"
+	"github.com/KnutZuidema/godarr/pkg/history"
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/monitorer"
+	"github.com/KnutZuidema/godarr/pkg/organizer"
+)
+
+const (
+	StageMonitor  = "monitor"
+	StageDownload = "download"
+	StageOrganize = "organize"
+
+	// searchPageSize is the number of items loaded at once by SearchMissing
+	searchPageSize = 100
+	// waitingRetry is the delay after which a download that was paused in
+	// the download client is checked again
+	waitingRetry = 10 * time.Minute
+)
+
+// Pipeline passes added items through monitoring, downloading and organizing.
+// Releases found by the monitorer and completed downloads of the downloader
+// have to be sent to the channels the pipeline was created with.
+type Pipeline struct {
+	db         database.Database
+	monitorer  monitorer.Monitorer
+	downloader downloader.Downloader
+	organizer  organizer.Organizer
+	history    *history.Recorder
+	logger     log.FieldLogger
+
+	items     <-chan model.Item
+	releases  <-chan model.Release
+	downloads <-chan model.CompletedDownload
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	jobs   map[string]*model.Job
+}
+
+func New(
+	db database.Database,
+	monitorer monitorer.Monitorer,
+	downloader downloader.Downloader,
+	organizer organizer.Organizer,
+	history *history.Recorder,
+	items <-chan model.Item,
+	releases <-chan model.Release,
+	downloads <-chan model.CompletedDownload,
+	logger log.FieldLogger,
+) *Pipeline {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pipeline{
+		db:         db,
+		monitorer:  monitorer,
+		downloader: downloader,
+		organizer:  organizer,
+		history:    history,
+		logger:     logger.WithField("component", "Pipeline"),
+		items:      items,
+		releases:   releases,
+		downloads:  downloads,
+		ctx:        ctx,
+		cancel:     cancel,
+		jobs:       map[string]*model.Job{},
+	}
+}
+
+// Run processes items, releases and completed downloads until stop is closed.
+func (p *Pipeline) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case item := <-p.items:
+			p.monitor(item)
+		case release := <-p.releases:
+			p.history.Grabbed(release)
+			p.download(release)
+		case download := <-p.downloads:
+			p.history.Downloaded(download)
+			p.organize(download)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Resume restarts the jobs which were interrupted by the last shutdown.
+func (p *Pipeline) Resume() error {
+	jobs, err := p.db.TakeJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		item, err := p.db.GetItem(job.ItemID)
+		if err != nil {
+			p.logger.WithField("item", job.ItemID).Warn("resume job: ", err)
+			continue
+		}
+		release := model.Release{
+			Item:    item,
+			Title:   job.Title,
+			Indexer: job.Indexer,
+			Size:    job.Size,
+			Quality: job.Quality,
+			Torrent: job.Torrent,
+		}
+		switch job.Kind {
+		case model.JobKindMonitor:
+			p.monitor(*item)
+		case model.JobKindDownload:
+			p.download(release)
+		case model.JobKindOrganize:
+			p.organize(model.CompletedDownload{
+				Release: release,
+				Path:    job.Path,
+			})
+		}
+	}
+	if len(jobs) > 0 {
+		p.logger.Infof("resumed %d jobs", len(jobs))
+	}
+	return nil
+}
+
+// SearchMissing monitors added items again which were never searched
+// successfully and are not monitored at the moment, like after their search
+// failed or godarr crashed. Items which were searched once are only matched
+// against the recent releases of the RSS sync, so indexers are not searched
+// for every wanted item on every run.
+func (p *Pipeline) SearchMissing(ctx context.Context) error {
+	var missing []*model.Item
+	// the items are collected first, since a search changes their status and
+	// would shift the following pages
+	for offset := 0; ; offset += searchPageSize {
+		items, err := p.db.ListItems(model.ItemFilter{Status: model.ItemStatusAdded}, offset, searchPageSize)
+		if err != nil {
+			return err
+		}
+		missing = append(missing, items...)
+		if len(items) < searchPageSize {
+			break
+		}
+	}
+	started := 0
+	for _, item := range missing {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !p.monitoring(item.ID) {
+			p.monitor(*item)
+			started++
+		}
+	}
+	if started > 0 {
+		p.logger.Infof("started searching for %d missing items", started)
+	}
+	return nil
+}
+
+// monitoring reports whether a monitor job of the item is running.
+func (p *Pipeline) monitoring(itemID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, job := range p.jobs {
+		if job.Kind == model.JobKindMonitor && job.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown cancels the running jobs and waits until they returned or the
+// context is done. Unfinished jobs are stored, so Resume can restart them.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	p.cancel()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.logger.Warn("jobs did not return before the deadline")
+	}
+	p.mu.Lock()
+	jobs := make([]*model.Job, 0, len(p.jobs))
+	for _, job := range p.jobs {
+		jobs = append(jobs, job)
+	}
+	p.mu.Unlock()
+	if len(jobs) == 0 {
+		return nil
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	if err := p.db.SaveJobs(jobs); err != nil {
+		return err
+	}
+	p.logger.Infof("saved %d unfinished jobs", len(jobs))
+	return nil
+}
+
+func (p *Pipeline) monitor(item model.Item) {
+	job := &model.Job{
+		Kind:   model.JobKindMonitor,
+		ItemID: item.ID,
+	}
+	p.run(job, func(ctx context.Context) error {
+		if err := p.monitorer.Monitor(ctx, &item); err != nil {
+			return err
+		}
+		return p.searched(item.ID)
+	}, func(err error) {
+		p.logger.WithField("item", item.ID).Error("monitor: ", err)
+		p.history.Failed(item.ID, StageMonitor, "", err)
+	})
+}
+
+// searched marks an added item as monitored after its first successful
+// search, from then on it is found by the RSS sync.
+func (p *Pipeline) searched(itemID string) error {
+	status, err := p.db.GetItemStatus(itemID)
+	if err != nil || status != model.ItemStatusAdded {
+		return err
+	}
+	return p.db.SetItemStatus(itemID, model.ItemStatusMonitored)
+}
+
+func (p *Pipeline) download(release model.Release) {
+	p.run(releaseJob(model.JobKindDownload, release), func(ctx context.Context) error {
+		return p.downloader.Download(ctx, release)
+	}, func(err error) {
+		p.logger.WithFields(log.Fields{
+			"item":    release.Item.ID,
+			"release": release.Title,
+		}).Error("download: ", err)
+		p.history.Failed(release.Item.ID, StageDownload, release.Title, err)
+	})
+}
+
+func (p *Pipeline) organize(download model.CompletedDownload) {
+	job := releaseJob(model.JobKindOrganize, download.Release)
+	job.Path = download.Path
+	// organizing only moves files, so it is not canceled and finishes within
+	// the deadline of the shutdown in most cases
+	p.run(job, func(context.Context) error {
+		return p.organizer.Organize(download)
+	}, func(err error) {
+		p.logger.WithFields(log.Fields{
+			"item": download.Release.Item.ID,
+			"path": download.Path,
+		}).Error("organize: ", err)
+		p.history.Failed(download.Release.Item.ID, StageOrganize, download.Release.Title, err)
+	})
+}
+
+func releaseJob(kind model.JobKind, release model.Release) *model.Job {
+	return &model.Job{
+		Kind:    kind,
+		ItemID:  release.Item.ID,
+		Title:   release.Title,
+		Indexer: release.Indexer,
+		Size:    release.Size,
+		Quality: release.Quality,
+		Torrent: release.Torrent,
+	}
+}
+
+// run executes a job in the background and reports its error with fail. Jobs
+// are tracked until they finish, jobs which are interrupted by Shutdown are
+// kept, so they can be stored.
+func (p *Pipeline) run(job *model.Job, f func(ctx context.Context) error, fail func(err error)) {
+	job.ID = uuid.NewV4().String()
+	job.CreatedAt = time.Now().UTC()
+	p.mu.Lock()
+	p.jobs[job.ID] = job
+	p.mu.Unlock()
+	p.start(job, f, fail)
+}
+
+// start executes a tracked job. Jobs which wait for a download that was
+// paused in the download client stay tracked without blocking and are
+// started again after waitingRetry.
+func (p *Pipeline) start(job *model.Job, f func(ctx context.Context) error, fail func(err error)) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		err := f(p.ctx)
+		if err != nil && p.ctx.Err() != nil {
+			return
+		}
+		if err == downloader.ErrWaiting {
+			time.AfterFunc(waitingRetry, func() {
+				p.start(job, f, fail)
+			})
+			return
+		}
+		p.mu.Lock()
+		delete(p.jobs, job.ID)
+		p.mu.Unlock()
+		if err != nil {
+			fail(err)
+		}
+	}()
+}
@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/cache"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// CacheStore persists cached responses, so they are kept across restarts and
+// shared by the instances of godarr which use the same database.
+type CacheStore interface {
+	GetProviderCache(key string, now time.Time) (*model.ProviderCacheEntry, error)
+	SetProviderCache(entry *model.ProviderCacheEntry) error
+}
+
+type CacheOptions struct {
+	// Time responses are cached
+	TTL time.Duration
+	// Time unknown IDs and searches without results are cached, defaults to
+	// TTL
+	NegativeTTL time.Duration
+	// Responses kept in memory, defaults to 1000
+	Size int
+	// Store of the responses which are not in memory, optional
+	Store CacheStore
+}
+
+// Cache keeps the responses of providers in memory and optionally in a
+// store. It is shared by the cached providers, so concurrent identical
+// requests of them are coalesced into one.
+type Cache struct {
+	options CacheOptions
+	lru     *cache.LRU
+	group   cache.Group
+	logger  logrus.FieldLogger
+}
+
+func NewCache(options CacheOptions, logger logrus.FieldLogger) *Cache {
+	if options.NegativeTTL <= 0 {
+		options.NegativeTTL = options.TTL
+	}
+	if options.Size <= 0 {
+		options.Size = 1000
+	}
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &Cache{
+		options: options,
+		lru:     cache.NewLRU(options.Size),
+		logger:  logger.WithField("component", "ProviderCache"),
+	}
+}
+
+// cached is a response encoded as JSON, which is the message of the
+// NotFoundError if NotFound is set.
+type cached struct {
+	Value    []byte
+	NotFound bool
+}
+
+// get decodes the cached response of the key into v. The response is fetched
+// if it is not cached, concurrent calls for the same key wait for the first
+// one. Fetch reports whether it found something, responses without results
+// are cached for the negative TTL like NotFoundErrors.
+func (c *Cache) get(key string, v interface{}, fetch func() (value interface{}, found bool, err error)) error {
+	value, ok := c.lru.Get(key)
+	if !ok {
+		var err error
+		value, err, _ = c.group.Do(key, func() (interface{}, error) {
+			return c.load(key, fetch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	response := value.(cached)
+	if response.NotFound {
+		var message string
+		if err := json.Unmarshal(response.Value, &message); err != nil {
+			return err
+		}
+		return NotFoundError{message}
+	}
+	// every caller decodes its own copy, since items are modified
+	return json.Unmarshal(response.Value, v)
+}
+
+// load returns the response of the key from the store or fetches it and
+// caches it in memory and in the store.
+func (c *Cache) load(key string, fetch func() (interface{}, bool, error)) (cached, error) {
+	// a call which just finished may have cached the response
+	if value, ok := c.lru.Get(key); ok {
+		return value.(cached), nil
+	}
+	now := time.Now().UTC()
+	if c.options.Store != nil {
+		entry, err := c.options.Store.GetProviderCache(key, now)
+		switch err {
+		case nil:
+			response := cached{Value: entry.Value, NotFound: entry.NotFound}
+			c.lru.Add(key, response, entry.ExpiresAt.Sub(now))
+			return response, nil
+		case sql.ErrNoRows:
+		default:
+			c.logger.WithField("key", key).Warn("get cached response: ", err)
+		}
+	}
+	value, found, err := fetch()
+	var response cached
+	ttl := c.options.TTL
+	switch err.(type) {
+	case nil:
+		if !found {
+			ttl = c.options.NegativeTTL
+		}
+	case NotFoundError:
+		value = err.Error()
+		response.NotFound = true
+		ttl = c.options.NegativeTTL
+	default:
+		return cached{}, err
+	}
+	if response.Value, err = json.Marshal(value); err != nil {
+		return cached{}, err
+	}
+	c.lru.Add(key, response, ttl)
+	if c.options.Store != nil {
+		if err := c.options.Store.SetProviderCache(&model.ProviderCacheEntry{
+			Key:       key,
+			Value:     model.JSON(response.Value),
+			NotFound:  response.NotFound,
+			ExpiresAt: now.Add(ttl),
+		}); err != nil {
+			c.logger.WithField("key", key).Warn("store cached response: ", err)
+		}
+	}
+	return response, nil
+}
+
+// CachedProvider caches the searches, lookups by ID and lists of a provider.
+// Refreshes always reach the provider, since they need the current metadata.
+type CachedProvider struct {
+	provider Provider
+	cache    *Cache
+	// prefix of the keys, which identifies the service and kind
+	prefix string
+}
+
+func NewCachedProvider(provider Provider, cache *Cache, prefix string) *CachedProvider {
+	return &CachedProvider{
+		provider: provider,
+		cache:    cache,
+		prefix:   prefix,
+	}
+}
+
+func (p *CachedProvider) ListBySearch(search string) ([]*model.Item, error) {
+	var items []*model.Item
+	key := p.prefix + "/search/" + strings.ToLower(strings.TrimSpace(search))
+	err := p.cache.get(key, &items, func() (interface{}, bool, error) {
+		items, err := p.provider.ListBySearch(search)
+		return items, len(items) > 0, err
+	})
+	return items, err
+}
+
+func (p *CachedProvider) GetByID(id string) (*model.Item, error) {
+	var item *model.Item
+	err := p.cache.get(p.prefix+"/id/"+id, &item, func() (interface{}, bool, error) {
+		item, err := p.provider.GetByID(id)
+		return item, true, err
+	})
+	return item, err
+}
+
+func (p *CachedProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	finder, ok := p.provider.(Finder)
+	if !ok {
+		return nil, fmt.Errorf("provider can not find items by %s ID", source)
+	}
+	var item *model.Item
+	err := p.cache.get(p.prefix+"/find/"+string(source)+"/"+id, &item, func() (interface{}, bool, error) {
+		item, err := finder.Find(source, id)
+		return item, true, err
+	})
+	return item, err
+}
+
+func (p *CachedProvider) Refresh(item *model.Item) (*model.Item, error) {
+	refresher, ok := p.provider.(Refresher)
+	if !ok {
+		return nil, fmt.Errorf("provider can not refresh items")
+	}
+	return refresher.Refresh(item)
+}
+
+func (p *CachedProvider) Changes(since time.Time) ([]string, error) {
+	lister, ok := p.provider.(ChangeLister)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list changes")
+	}
+	return lister.Changes(since)
+}
+
+func (p *CachedProvider) Episodes(item *model.Item) ([]model.Episode, error) {
+	lister, ok := p.provider.(EpisodeLister)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list episodes")
+	}
+	return lister.Episodes(item)
+}
+
+func (p *CachedProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	discoverer, ok := p.provider.(Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("provider can not discover items")
+	}
+	var items []*model.Item
+	key := fmt.Sprintf("%s/discover/%s/%d", p.prefix, list, page)
+	err := p.cache.get(key, &items, func() (interface{}, bool, error) {
+		items, err := discoverer.Discover(list, page)
+		return items, len(items) > 0, err
+	})
+	return items, err
+}
+
+// Related caches the related items by the TMDb ID of the item, or by its
+// external ID if the TMDb ID is unknown.
+func (p *CachedProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	discoverer, ok := p.provider.(Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("provider can not list related items")
+	}
+	id := string(model.ExternalIDSourceTMDB) + "/" + item.ExternalIDs.TMDB
+	if item.ExternalIDs.TMDB == "" {
+		id = string(model.PrimaryExternalIDSource(item.Kind)) + "/" + item.ExternalID
+	}
+	var items []*model.Item
+	key := fmt.Sprintf("%s/related/%s/%s/%d", p.prefix, relation, id, page)
+	err := p.cache.get(key, &items, func() (interface{}, bool, error) {
+		items, err := discoverer.Related(item, relation, page)
+		return items, len(items) > 0, err
+	})
+	return items, err
+}
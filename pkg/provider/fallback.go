@@ -0,0 +1,326 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Source is a provider together with the name which is recorded as source
+// of the fields it provides.
+type Source struct {
+	Name     string
+	Provider Provider
+}
+
+// FallbackProvider tries its sources in order until one of them answers. The
+// fields which are missing in the answer, like description, genres or
+// rating, are filled by asking the following sources for the same item. The
+// source of every field is recorded in the metadata sources of the item.
+type FallbackProvider struct {
+	sources []Source
+}
+
+func NewFallbackProvider(sources ...Source) *FallbackProvider {
+	return &FallbackProvider{
+		sources: sources,
+	}
+}
+
+// ListBySearch returns the results of the first source whose search
+// succeeds. Results of different sources are not merged, since each search
+// makes requests to every following source otherwise.
+func (p *FallbackProvider) ListBySearch(search string) ([]*model.Item, error) {
+	var items []*model.Item
+	err := p.each(func(source Source) (err error) {
+		items, err = source.Provider.ListBySearch(search)
+		if err == nil {
+			for _, item := range items {
+				record(item, source.Name)
+			}
+		}
+		return err
+	})
+	return items, err
+}
+
+// GetByID fetches an item from the first source, since the sources do not
+// share their IDs. If the first source fails for another reason than not
+// knowing the item, the following sources find it by the stored ID, which is
+// the IMDb ID of a movie or the TVDb ID of a TV series. Missing fields are
+// filled by the sources after the one which answered.
+func (p *FallbackProvider) GetByID(id string) (*model.Item, error) {
+	if len(p.sources) == 0 {
+		return nil, fmt.Errorf("no provider is configured")
+	}
+	first := p.sources[0]
+	item, err := first.Provider.GetByID(id)
+	if err == nil {
+		record(item, first.Name)
+		p.complete(item, 1)
+		return item, nil
+	}
+	var notFound NotFoundError
+	if errors.As(err, &notFound) {
+		return nil, err
+	}
+	source := storedIDSource(id)
+	for i, other := range p.sources[1:] {
+		finder, ok := other.Provider.(Finder)
+		if !ok {
+			continue
+		}
+		found, findErr := finder.Find(source, id)
+		if findErr != nil {
+			continue
+		}
+		if found.ExternalIDs.Get(source) == "" {
+			found.ExternalIDs.Set(source, id)
+		}
+		record(found, other.Name)
+		p.complete(found, i+2)
+		return found, nil
+	}
+	return nil, err
+}
+
+// storedIDSource returns the source of an ID passed to GetByID, IMDb IDs
+// start with "tt" and everything else is a TVDb ID.
+func storedIDSource(id string) model.ExternalIDSource {
+	if strings.HasPrefix(id, "tt") {
+		return model.ExternalIDSourceIMDb
+	}
+	return model.ExternalIDSourceTVDb
+}
+
+// Find fetches an item from the first source which finds it by its ID at the
+// external source.
+func (p *FallbackProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	return p.first(func(p Provider) (*model.Item, error) {
+		finder, ok := p.(Finder)
+		if !ok {
+			return nil, fmt.Errorf("provider can not find items by %s ID", source)
+		}
+		return finder.Find(source, id)
+	})
+}
+
+// Refresh fetches the current metadata of an item from the first source
+// which knows it.
+func (p *FallbackProvider) Refresh(item *model.Item) (*model.Item, error) {
+	return p.first(func(p Provider) (*model.Item, error) {
+		refresher, ok := p.(Refresher)
+		if !ok {
+			return nil, fmt.Errorf("provider can not refresh items")
+		}
+		refreshed, err := refresher.Refresh(item)
+		if err == nil {
+			// the following sources may only know the item by its stored IDs
+			refreshed.ExternalIDs.Merge(item.ExternalIDs)
+		}
+		return refreshed, err
+	})
+}
+
+// Changes lists the changes of the first source which lists them.
+func (p *FallbackProvider) Changes(since time.Time) ([]string, error) {
+	var ids []string
+	err := p.each(func(source Source) (err error) {
+		lister, ok := source.Provider.(ChangeLister)
+		if !ok {
+			return fmt.Errorf("provider can not list changes")
+		}
+		ids, err = lister.Changes(since)
+		return err
+	})
+	return ids, err
+}
+
+// Episodes lists the episodes of the first source which lists them.
+func (p *FallbackProvider) Episodes(item *model.Item) ([]model.Episode, error) {
+	var episodes []model.Episode
+	err := p.each(func(source Source) (err error) {
+		lister, ok := source.Provider.(EpisodeLister)
+		if !ok {
+			return fmt.Errorf("provider can not list episodes")
+		}
+		episodes, err = lister.Episodes(item)
+		return err
+	})
+	return episodes, err
+}
+
+// Discover lists the items of the first source which lists them.
+func (p *FallbackProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	var items []*model.Item
+	err := p.each(func(source Source) (err error) {
+		discoverer, ok := source.Provider.(Discoverer)
+		if !ok {
+			return fmt.Errorf("provider can not discover items")
+		}
+		if items, err = discoverer.Discover(list, page); err == nil {
+			for _, item := range items {
+				record(item, source.Name)
+			}
+		}
+		return err
+	})
+	return items, err
+}
+
+// Related lists the related items of the first source which lists them.
+func (p *FallbackProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	var items []*model.Item
+	err := p.each(func(source Source) (err error) {
+		discoverer, ok := source.Provider.(Discoverer)
+		if !ok {
+			return fmt.Errorf("provider can not list related items")
+		}
+		if items, err = discoverer.Related(item, relation, page); err == nil {
+			for _, item := range items {
+				record(item, source.Name)
+			}
+		}
+		return err
+	})
+	return items, err
+}
+
+// each calls f with every source until it succeeds and returns the error of
+// the first source otherwise.
+func (p *FallbackProvider) each(f func(source Source) error) error {
+	if len(p.sources) == 0 {
+		return fmt.Errorf("no provider is configured")
+	}
+	var first error
+	for _, source := range p.sources {
+		err := f(source)
+		if err == nil {
+			return nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// first fetches an item from the first source which succeeds and completes
+// it with the sources after it.
+func (p *FallbackProvider) first(fetch func(p Provider) (*model.Item, error)) (*model.Item, error) {
+	var item *model.Item
+	index := 0
+	err := p.each(func(source Source) (err error) {
+		index++
+		item, err = fetch(source.Provider)
+		if err == nil {
+			record(item, source.Name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.complete(item, index)
+	return item, nil
+}
+
+// complete fills the missing fields of an item with the sources starting at
+// the index. The sources look the item up by the IDs known so far, failures
+// are ignored since the item is complete enough without them.
+func (p *FallbackProvider) complete(item *model.Item, index int) {
+	for _, source := range p.sources[index:] {
+		if len(missing(item)) == 0 {
+			return
+		}
+		refresher, ok := source.Provider.(Refresher)
+		if !ok {
+			continue
+		}
+		other, err := refresher.Refresh(item)
+		if err != nil {
+			continue
+		}
+		merge(item, other, source.Name)
+	}
+}
+
+// missing returns the metadata fields of an item which are empty.
+func missing(item *model.Item) []string {
+	var fields []string
+	if item.Title == "" {
+		fields = append(fields, model.MetadataFieldTitle)
+	}
+	if item.Description == "" {
+		fields = append(fields, model.MetadataFieldDescription)
+	}
+	if item.ImagePath == "" {
+		fields = append(fields, model.MetadataFieldImagePath)
+	}
+	if item.ReleaseYear == 0 {
+		fields = append(fields, model.MetadataFieldReleaseYear)
+	}
+	if len(item.Genres) == 0 {
+		fields = append(fields, model.MetadataFieldGenres)
+	}
+	if item.Rating == 0 {
+		fields = append(fields, model.MetadataFieldRating)
+	}
+	return fields
+}
+
+// record sets the source of all fields of an item which are not empty.
+func record(item *model.Item, name string) {
+	empty := map[string]bool{}
+	for _, field := range missing(item) {
+		empty[field] = true
+	}
+	item.MetadataSources = model.MetadataSources{}
+	for _, field := range []string{
+		model.MetadataFieldTitle,
+		model.MetadataFieldDescription,
+		model.MetadataFieldImagePath,
+		model.MetadataFieldReleaseYear,
+		model.MetadataFieldGenres,
+		model.MetadataFieldRating,
+	} {
+		if !empty[field] {
+			item.MetadataSources[field] = name
+		}
+	}
+}
+
+// merge fills the empty fields and unknown IDs of an item with the ones of
+// another item and records the source of the filled fields.
+func merge(item, other *model.Item, name string) {
+	empty := missing(item)
+	for _, field := range empty {
+		switch field {
+		case model.MetadataFieldTitle:
+			item.Title = other.Title
+		case model.MetadataFieldDescription:
+			item.Description = other.Description
+		case model.MetadataFieldImagePath:
+			item.ImagePath = other.ImagePath
+		case model.MetadataFieldReleaseYear:
+			item.ReleaseYear = other.ReleaseYear
+		case model.MetadataFieldGenres:
+			item.Genres = other.Genres
+		case model.MetadataFieldRating:
+			item.Rating = other.Rating
+		}
+	}
+	still := map[string]bool{}
+	for _, field := range missing(item) {
+		still[field] = true
+	}
+	for _, field := range empty {
+		if !still[field] {
+			item.MetadataSources[field] = name
+		}
+	}
+	item.ExternalIDs.Merge(other.ExternalIDs)
+}
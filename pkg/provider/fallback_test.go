@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// fallbackSource answers GetByID and Find with its item or its error and
+// records the IDs it was asked for.
+type fallbackSource struct {
+	item  *model.Item
+	err   error
+	found []string
+}
+
+func (s *fallbackSource) ListBySearch(search string) ([]*model.Item, error) {
+	return nil, s.err
+}
+
+func (s *fallbackSource) GetByID(id string) (*model.Item, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	item := *s.item
+	return &item, nil
+}
+
+func (s *fallbackSource) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	s.found = append(s.found, string(source)+":"+id)
+	return s.GetByID(id)
+}
+
+func TestFallbackGetByID(t *testing.T) {
+	for name, c := range map[string]struct {
+		id    string
+		err   error
+		title string
+		found []string
+	}{
+		"first":        {id: "tt0113277", title: "first"},
+		"unreachable":  {id: "tt0113277", err: errors.New("unreachable"), title: "second", found: []string{"imdb:tt0113277"}},
+		"tv series":    {id: "81189", err: errors.New("unreachable"), title: "second", found: []string{"tvdb:81189"}},
+		"unknown item": {id: "tt0113277", err: NotFoundError{"unknown"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			second := &fallbackSource{item: &model.Item{Title: "second"}}
+			p := NewFallbackProvider(
+				Source{Name: "first", Provider: &fallbackSource{item: &model.Item{Title: "first"}, err: c.err}},
+				Source{Name: "second", Provider: second},
+			)
+			item, err := p.GetByID(c.id)
+			if c.title == "" {
+				if err != c.err {
+					t.Errorf("error %v, expected %v", err, c.err)
+				}
+			} else if err != nil || item.Title != c.title || item.MetadataSources[model.MetadataFieldTitle] != c.title {
+				t.Errorf("item %+v (%v), expected the one of %s", item, err, c.title)
+			}
+			if len(second.found) != len(c.found) || (len(c.found) > 0 && second.found[0] != c.found[0]) {
+				t.Errorf("second source found %v, expected %v", second.found, c.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+const (
+	DefaultOMDbBaseURL = "https://www.omdbapi.com"
+	omdbTimeout        = 30 * time.Second
+	// OMDb uses N/A for unknown values
+	omdbNotAvailable = "N/A"
+)
+
+// omdbTypes are the types of OMDb by item kind.
+var omdbTypes = map[model.ItemKind]string{
+	model.ItemKindMovie:    "movie",
+	model.ItemKindTVSeries: "series",
+}
+
+// searchYear matches a release year at the end of a search like "Heat 1995"
+// or "Heat (1995)".
+var searchYear = regexp.MustCompile(`^(.+?)\s+\(?(\d{4})\)?$`)
+
+type OMDbOptions struct {
+	// Base URL of the API, defaults to DefaultOMDbBaseURL
+	BaseURL string
+	APIKey  string
+}
+
+// OMDbProvider provides movies or TV series from the OMDb API, which only
+// knows their IMDb IDs. It is mostly used to fill fields which are missing at
+// other providers.
+type OMDbProvider struct {
+	options OMDbOptions
+	kind    model.ItemKind
+	client  *http.Client
+	logger  logrus.FieldLogger
+}
+
+func NewOMDbProvider(options OMDbOptions, kind model.ItemKind, client *http.Client, logger logrus.FieldLogger) *OMDbProvider {
+	if options.BaseURL == "" {
+		options.BaseURL = DefaultOMDbBaseURL
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	if client == nil {
+		client = &http.Client{
+			Timeout: omdbTimeout,
+		}
+	}
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &OMDbProvider{
+		options: options,
+		kind:    kind,
+		client:  client,
+		logger:  logger.WithField("component", "OMDbProvider"),
+	}
+}
+
+type omdbResponse struct {
+	Response string `json:"Response"`
+	Error    string `json:"Error"`
+}
+
+type omdbSearchResult struct {
+	Title  string `json:"Title"`
+	Year   string `json:"Year"`
+	IMDbID string `json:"imdbID"`
+	Poster string `json:"Poster"`
+}
+
+type omdbTitle struct {
+	omdbSearchResult
+	Genre      string `json:"Genre"`
+	Plot       string `json:"Plot"`
+	IMDbRating string `json:"imdbRating"`
+}
+
+func (p *OMDbProvider) get(query url.Values, v interface{}) error {
+	query.Set("apikey", p.options.APIKey)
+	query.Set("type", omdbTypes[p.kind])
+	resp, err := p.client.Get(p.options.BaseURL + "/?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var res omdbResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return ratelimit.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(data))}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ratelimit.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: res.Error}
+	}
+	if res.Response != "True" {
+		// OMDb answers unknown titles and searches without results with
+		// status 200 and an error
+		return NotFoundError{"OMDb: " + res.Error}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ListBySearch searches titles, a release year at the end of the search
+// narrows the results to that year.
+func (p *OMDbProvider) ListBySearch(search string) ([]*model.Item, error) {
+	query := url.Values{"s": {search}}
+	if match := searchYear.FindStringSubmatch(search); match != nil {
+		query.Set("s", match[1])
+		query.Set("y", match[2])
+	}
+	var res struct {
+		Search []omdbSearchResult `json:"Search"`
+	}
+	if err := p.get(query, &res); err != nil {
+		if _, ok := err.(NotFoundError); ok {
+			return []*model.Item{}, nil
+		}
+		return nil, err
+	}
+	items := make([]*model.Item, 0, len(res.Search))
+	for _, result := range res.Search {
+		items = append(items, p.item(omdbTitle{omdbSearchResult: result}))
+	}
+	return items, nil
+}
+
+// GetByID fetches an item by its IMDb ID.
+func (p *OMDbProvider) GetByID(id string) (*model.Item, error) {
+	var title omdbTitle
+	if err := p.get(url.Values{"i": {id}, "plot": {"full"}}, &title); err != nil {
+		return nil, err
+	}
+	return p.item(title), nil
+}
+
+// Find fetches an item by its IMDb ID, OMDb does not know other sources.
+func (p *OMDbProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	if source != model.ExternalIDSourceIMDb {
+		return nil, NotFoundError{fmt.Sprintf("OMDb can not find items by %s ID", source)}
+	}
+	return p.GetByID(id)
+}
+
+// Refresh fetches the current metadata of an item by its IMDb ID.
+func (p *OMDbProvider) Refresh(item *model.Item) (*model.Item, error) {
+	id := item.ExternalIDs.IMDb
+	if id == "" && item.Kind == model.ItemKindMovie {
+		id = item.ExternalID
+	}
+	if id == "" {
+		return nil, NotFoundError{"OMDb requires the IMDb ID"}
+	}
+	return p.GetByID(id)
+}
+
+func (p *OMDbProvider) item(title omdbTitle) *model.Item {
+	value := func(s string) string {
+		if s == omdbNotAvailable {
+			return ""
+		}
+		return s
+	}
+	item := &model.Item{
+		Kind:        p.kind,
+		Title:       title.Title,
+		Description: value(title.Plot),
+		ImagePath:   value(title.Poster),
+		Status:      model.ItemStatusAdded,
+	}
+	item.ExternalIDs.IMDb = title.IMDbID
+	if p.kind == model.ItemKindMovie {
+		item.ExternalID = title.IMDbID
+	}
+	// years of TV series are ranges like 2008–2013
+	if len(title.Year) >= 4 {
+		item.ReleaseYear, _ = strconv.Atoi(title.Year[:4])
+	}
+	for _, genre := range strings.Split(value(title.Genre), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			item.Genres = append(item.Genres, genre)
+		}
+	}
+	item.Rating, _ = strconv.ParseFloat(value(title.IMDbRating), 64)
+	return item
+}
@@ -1,10 +1,52 @@
 package provider
 
 import (
+	"time"
+
 	"github.com/KnutZuidema/godarr/pkg/model"
 )
 
+// NotFoundError is returned if a provider does not know the requested item.
+type NotFoundError struct {
+	Message string
+}
+
+func (e NotFoundError) Error() string {
+	return e.Message
+}
+
 type Provider interface {
 	ListBySearch(search string) ([]*model.Item, error)
 	GetByID(id string) (*model.Item, error)
 }
+
+// Refresher is implemented by providers which can fetch the current metadata
+// of an item which was added before.
+type Refresher interface {
+	Refresh(item *model.Item) (*model.Item, error)
+}
+
+// ChangeLister is implemented by providers which list the TMDb IDs of the
+// items whose metadata changed since a time.
+type ChangeLister interface {
+	Changes(since time.Time) ([]string, error)
+}
+
+// Finder is implemented by providers which fetch items by their ID at any
+// external source.
+type Finder interface {
+	Find(source model.ExternalIDSource, id string) (*model.Item, error)
+}
+
+// EpisodeLister is implemented by providers which list the episodes of a TV
+// series in the episode order of the item.
+type EpisodeLister interface {
+	Episodes(item *model.Item) ([]model.Episode, error)
+}
+
+// Discoverer is implemented by providers which list curated items, like
+// popular ones, and items related to an item. Pages start at 1.
+type Discoverer interface {
+	Discover(list model.DiscoverList, page int) ([]*model.Item, error)
+	Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error)
+}
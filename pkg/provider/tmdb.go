@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/KnutZuidema/go-tmdb"
 	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
 	"github.com/sirupsen/logrus"
+	"net/http"
 	"strconv"
 	"time"
 )
@@ -19,6 +21,12 @@ var (
 	tmdbTimeFormat = "2006-01-02"
 )
 
+const (
+	// TMDb lists changes of the last 14 days only
+	tmdbChangesMaxAge   = 14 * 24 * time.Hour
+	tmdbChangesMaxPages = 500
+)
+
 func NewTMDBProvider(apiKey string, logger logrus.FieldLogger, kind model.ItemKind) *TMDBProvider {
 	client := tmdb.Init(tmdb.Config{
 		APIKey: apiKey,
@@ -57,14 +65,108 @@ func (p *TMDBProvider) GetByID(id string) (*model.Item, error) {
 	return nil, fmt.Errorf("invalid kind: %v", p.kind)
 }
 
+// Refresh fetches the current metadata of an item, which is identified by its
+// TMDb ID if known and otherwise by its external ID.
+func (p *TMDBProvider) Refresh(item *model.Item) (*model.Item, error) {
+	if item.ExternalIDs.TMDB != "" {
+		return p.GetByID(item.ExternalIDs.TMDB)
+	}
+	return p.Find(model.PrimaryExternalIDSource(item.Kind), item.ExternalID)
+}
+
+// Find fetches an item by its ID at the given source, IDs of other sources
+// than TMDb are resolved via the find endpoint.
+func (p *TMDBProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	if source == model.ExternalIDSourceTMDB {
+		return p.GetByID(id)
+	}
+	res, err := p.client.GetFind(id, string(source)+"_id", nil)
+	if err != nil {
+		return nil, tmdbError(err)
+	}
+	switch p.kind {
+	case model.ItemKindMovie:
+		if len(res.MovieResults) == 0 {
+			return nil, NotFoundError{fmt.Sprintf("no movie with %s ID %s", source, id)}
+		}
+		return p.getMovieByID(res.MovieResults[0].ID)
+	case model.ItemKindTVSeries:
+		if len(res.TvResults) == 0 {
+			return nil, NotFoundError{fmt.Sprintf("no TV series with %s ID %s", source, id)}
+		}
+		return p.getTVByID(res.TvResults[0].ID)
+	}
+	return nil, fmt.Errorf("invalid kind: %v", p.kind)
+}
+
+// Changes lists the IDs of movies or TV series whose metadata changed since
+// the given time. TMDb only keeps the changes of the last 14 days.
+func (p *TMDBProvider) Changes(since time.Time) ([]string, error) {
+	if time.Since(since) > tmdbChangesMaxAge {
+		return nil, fmt.Errorf("changes are only available for the last %s", tmdbChangesMaxAge)
+	}
+	options := map[string]string{
+		"start_date": since.UTC().Format(tmdbTimeFormat),
+	}
+	var ids []string
+	// the response does not contain the number of pages, the pages are
+	// requested until one is empty
+	for page := 1; page <= tmdbChangesMaxPages; page++ {
+		options["page"] = strconv.Itoa(page)
+		var (
+			res *tmdb.Changes
+			err error
+		)
+		switch p.kind {
+		case model.ItemKindMovie:
+			res, err = p.client.GetChangesMovie(options)
+		case model.ItemKindTVSeries:
+			res, err = p.client.GetChangesTv(options)
+		default:
+			return nil, fmt.Errorf("invalid kind: %v", p.kind)
+		}
+		if err != nil {
+			return nil, tmdbError(err)
+		}
+		if len(res.Results) == 0 {
+			break
+		}
+		for _, result := range res.Results {
+			ids = append(ids, strconv.Itoa(result.ID))
+		}
+	}
+	return ids, nil
+}
+
+// tmdbError turns the status codes of TMDb, which the client only reports in
+// the error text, into typed errors. Code 25 means too many requests and code
+// 34 an unknown resource.
+func tmdbError(err error) error {
+	var code int
+	if _, scanErr := fmt.Sscanf(err.Error(), "Code (%d):", &code); scanErr != nil {
+		return err
+	}
+	switch code {
+	case 25:
+		return ratelimit.StatusError{
+			StatusCode: http.StatusTooManyRequests,
+			Status:     "429 Too Many Requests",
+			Message:    err.Error(),
+		}
+	case 34:
+		return NotFoundError{"TMDb: " + err.Error()}
+	}
+	return err
+}
+
 func (p *TMDBProvider) getMovieByID(id int) (*model.Item, error) {
 	res, err := p.client.GetMovieInfo(id, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	externalIDs, err := p.client.GetMovieExternalIds(id, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	res.ExternalIDs = externalIDs
 	item, err := movieItemFromTMDBMovie(res)
@@ -77,11 +179,11 @@ func (p *TMDBProvider) getMovieByID(id int) (*model.Item, error) {
 func (p *TMDBProvider) getTVByID(id int) (*model.Item, error) {
 	res, err := p.client.GetTvInfo(id, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	externalIDs, err := p.client.GetTvExternalIds(id, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	res.ExternalIDs = externalIDs
 	item, err := tvItemFromTMDBTV(res)
@@ -99,7 +201,7 @@ func tvItemFromTMDBTVResult(tv *tmdb.TvSearchResults) ([]*model.Item, error) {
 			release = time.Time{}
 		}
 		items = append(items, &model.Item{
-			ExternalID:  strconv.Itoa(res.ID),
+			ExternalIDs: model.ExternalIDs{TMDB: strconv.Itoa(res.ID)},
 			Kind:        model.ItemKindTVSeries,
 			Title:       res.Name,
 			ImagePath:   res.PosterPath,
@@ -119,7 +221,7 @@ func tvItemfromTMDBMovieResult(movie *tmdb.MovieSearchResults) ([]*model.Item, e
 			release = time.Time{}
 		}
 		items = append(items, &model.Item{
-			ExternalID:  strconv.Itoa(res.ID),
+			ExternalIDs: model.ExternalIDs{TMDB: strconv.Itoa(res.ID)},
 			Kind:        model.ItemKindMovie,
 			Title:       res.Title,
 			ImagePath:   res.PosterPath,
@@ -136,13 +238,22 @@ func movieItemFromTMDBMovie(movie *tmdb.Movie) (*model.Item, error) {
 	if err != nil {
 		release = time.Time{}
 	}
+	var genres []string
+	for _, genre := range movie.Genres {
+		genres = append(genres, genre.Name)
+	}
 	return &model.Item{
-		ExternalID:  movie.ExternalIDs.ImdbID,
+		ExternalID: movie.ExternalIDs.ImdbID,
+		ExternalIDs: model.ExternalIDs{
+			TMDB: strconv.Itoa(movie.ID),
+			IMDb: movie.ExternalIDs.ImdbID,
+		},
 		Kind:        model.ItemKindMovie,
 		Title:       movie.Title,
 		Description: movie.Overview,
 		ImagePath:   movie.PosterPath,
 		ReleaseYear: release.Year(),
+		Genres:      genres,
 		Rating:      float64(movie.VoteAverage),
 		Status:      model.ItemStatusAdded,
 	}, nil
@@ -153,13 +264,32 @@ func tvItemFromTMDBTV(tv *tmdb.TV) (*model.Item, error) {
 	if err != nil {
 		release = time.Time{}
 	}
+	var genres []string
+	for _, genre := range tv.Genres {
+		genres = append(genres, genre.Name)
+	}
+	ids := model.ExternalIDs{
+		TMDB: strconv.Itoa(tv.ID),
+		IMDb: tv.ExternalIDs.ImdbID,
+	}
+	// series unknown at TheTVDB are identified by their TMDb ID
+	externalID := ids.TMDB
+	if tv.ExternalIDs.TvdbID > 0 {
+		ids.TVDb = strconv.Itoa(tv.ExternalIDs.TvdbID)
+		externalID = ids.TVDb
+	}
+	if tv.ExternalIDs.TvrageID > 0 {
+		ids.TVRage = strconv.Itoa(tv.ExternalIDs.TvrageID)
+	}
 	return &model.Item{
-		ExternalID:  strconv.Itoa(tv.ExternalIDs.TvdbID),
+		ExternalID:  externalID,
+		ExternalIDs: ids,
 		Kind:        model.ItemKindTVSeries,
 		Title:       tv.Name,
 		Description: tv.Overview,
 		ImagePath:   tv.PosterPath,
 		ReleaseYear: release.Year(),
+		Genres:      genres,
 		Rating:      float64(tv.VoteAverage),
 		Status:      model.ItemStatusAdded,
 	}, nil
@@ -168,7 +298,7 @@ func tvItemFromTMDBTV(tv *tmdb.TV) (*model.Item, error) {
 func (p *TMDBProvider) listTV(search string) ([]*model.Item, error) {
 	res, err := p.client.SearchTv(search, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	return tvItemFromTMDBTVResult(res)
 }
@@ -176,7 +306,195 @@ func (p *TMDBProvider) listTV(search string) ([]*model.Item, error) {
 func (p *TMDBProvider) listMovie(search string) ([]*model.Item, error) {
 	res, err := p.client.SearchMovie(search, nil)
 	if err != nil {
-		return nil, err
+		return nil, tmdbError(err)
 	}
 	return tvItemfromTMDBMovieResult(res)
 }
+
+// Discover lists a page of popular, top rated, upcoming or now playing
+// movies or TV series. Upcoming TV series are the ones airing in the next
+// week, now playing ones are airing today.
+func (p *TMDBProvider) Discover(list model.DiscoverList, page int) ([]*model.Item, error) {
+	options := map[string]string{
+		"page": strconv.Itoa(page),
+	}
+	switch p.kind {
+	case model.ItemKindMovie:
+		var results []tmdb.MovieShort
+		switch list {
+		case model.DiscoverListPopular:
+			res, err := p.client.GetMoviePopular(options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results = res.Results
+		case model.DiscoverListTopRated:
+			res, err := p.client.GetMovieTopRated(options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results = res.Results
+		case model.DiscoverListUpcoming:
+			res, err := p.client.GetMovieUpcoming(options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results = res.Results
+		case model.DiscoverListNowPlaying:
+			res, err := p.client.GetMovieNowPlaying(options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results = res.Results
+		default:
+			return nil, fmt.Errorf("unknown list %q", list)
+		}
+		return movieItemsFromTMDBMovieShorts(results), nil
+	case model.ItemKindTVSeries:
+		var (
+			res *tmdb.TvPagedResults
+			err error
+		)
+		switch list {
+		case model.DiscoverListPopular:
+			res, err = p.client.GetTvPopular(options)
+		case model.DiscoverListTopRated:
+			res, err = p.client.GetTvTopRated(options)
+		case model.DiscoverListUpcoming:
+			res, err = p.client.GetTvOnTheAir(options)
+		case model.DiscoverListNowPlaying:
+			res, err = p.client.GetTvAiringToday(options)
+		default:
+			return nil, fmt.Errorf("unknown list %q", list)
+		}
+		if err != nil {
+			return nil, tmdbError(err)
+		}
+		return tvItemsFromTMDBTvShorts(res.Results), nil
+	}
+	return nil, fmt.Errorf("invalid kind: %v", p.kind)
+}
+
+// Related lists a page of the recommendations for an item or of items which
+// are similar to it.
+func (p *TMDBProvider) Related(item *model.Item, relation model.Relation, page int) ([]*model.Item, error) {
+	id, err := p.tmdbID(item)
+	if err != nil {
+		return nil, err
+	}
+	options := map[string]string{
+		"page": strconv.Itoa(page),
+	}
+	switch p.kind {
+	case model.ItemKindMovie:
+		switch relation {
+		case model.RelationRecommendations:
+			res, err := p.client.GetMovieRecommendations(id, options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results := make([]tmdb.MovieShort, 0, len(res.Results))
+			for _, result := range res.Results {
+				results = append(results, tmdb.MovieShort{
+					ID:          result.ID,
+					Title:       result.Title,
+					Overview:    result.Overview,
+					PosterPath:  result.PosterPath,
+					ReleaseDate: result.ReleaseDate,
+					VoteAverage: result.VoteAverage,
+				})
+			}
+			return movieItemsFromTMDBMovieShorts(results), nil
+		case model.RelationSimilar:
+			res, err := p.client.GetMovieSimilar(id, options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			return movieItemsFromTMDBMovieShorts(res.Results), nil
+		}
+		return nil, fmt.Errorf("unknown relation %q", relation)
+	case model.ItemKindTVSeries:
+		switch relation {
+		case model.RelationRecommendations:
+			res, err := p.client.GetTvRecommendations(id, options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			results := make([]tmdb.TvShort, 0, len(res.Results))
+			for _, result := range res.Results {
+				results = append(results, tmdb.TvShort{
+					ID:           result.ID,
+					Name:         result.Name,
+					Overview:     result.Overview,
+					PosterPath:   result.PosterPath,
+					FirstAirDate: result.FirstAirDate,
+					VoteAverage:  result.VoteAverage,
+				})
+			}
+			return tvItemsFromTMDBTvShorts(results), nil
+		case model.RelationSimilar:
+			res, err := p.client.GetTvSimilar(id, options)
+			if err != nil {
+				return nil, tmdbError(err)
+			}
+			return tvItemsFromTMDBTvShorts(res.Results), nil
+		}
+		return nil, fmt.Errorf("unknown relation %q", relation)
+	}
+	return nil, fmt.Errorf("invalid kind: %v", p.kind)
+}
+
+// tmdbID returns the TMDb ID of an item, which is looked up by its external
+// ID if it is unknown.
+func (p *TMDBProvider) tmdbID(item *model.Item) (int, error) {
+	if item.ExternalIDs.TMDB == "" {
+		found, err := p.Find(model.PrimaryExternalIDSource(item.Kind), item.ExternalID)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(found.ExternalIDs.TMDB)
+	}
+	return strconv.Atoi(item.ExternalIDs.TMDB)
+}
+
+func movieItemsFromTMDBMovieShorts(movies []tmdb.MovieShort) []*model.Item {
+	items := make([]*model.Item, 0, len(movies))
+	for _, movie := range movies {
+		release, err := time.Parse(tmdbTimeFormat, movie.ReleaseDate)
+		if err != nil {
+			release = time.Time{}
+		}
+		items = append(items, &model.Item{
+			ExternalIDs: model.ExternalIDs{TMDB: strconv.Itoa(movie.ID)},
+			Kind:        model.ItemKindMovie,
+			Title:       movie.Title,
+			Description: movie.Overview,
+			ImagePath:   movie.PosterPath,
+			ReleaseYear: release.Year(),
+			Rating:      float64(movie.VoteAverage),
+			Status:      model.ItemStatusAdded,
+		})
+	}
+	return items
+}
+
+func tvItemsFromTMDBTvShorts(tvs []tmdb.TvShort) []*model.Item {
+	items := make([]*model.Item, 0, len(tvs))
+	for _, tv := range tvs {
+		release, err := time.Parse(tmdbTimeFormat, tv.FirstAirDate)
+		if err != nil {
+			release = time.Time{}
+		}
+		items = append(items, &model.Item{
+			ExternalIDs: model.ExternalIDs{TMDB: strconv.Itoa(tv.ID)},
+			Kind:        model.ItemKindTVSeries,
+			Title:       tv.Name,
+			Description: tv.Overview,
+			ImagePath:   tv.PosterPath,
+			ReleaseYear: release.Year(),
+			Rating:      float64(tv.VoteAverage),
+			Status:      model.ItemStatusAdded,
+		})
+	}
+	return items
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/KnutZuidema/go-tmdb"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+func TestTVItemFromTMDBTV(t *testing.T) {
+	for name, c := range map[string]struct {
+		ids        tmdb.TvExternalIds
+		externalID string
+		expected   model.ExternalIDs
+	}{
+		"known at TheTVDB": {
+			ids:        tmdb.TvExternalIds{TvdbID: 81189, ImdbID: "tt0903747"},
+			externalID: "81189",
+			expected:   model.ExternalIDs{TMDB: "1396", IMDb: "tt0903747", TVDb: "81189"},
+		},
+		"unknown at TheTVDB": {
+			externalID: "1396",
+			expected:   model.ExternalIDs{TMDB: "1396"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ids := c.ids
+			item, err := tvItemFromTMDBTV(&tmdb.TV{ID: 1396, Name: "Breaking Bad", ExternalIDs: &ids})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.ExternalID != c.externalID {
+				t.Errorf("external ID %q, expected %q", item.ExternalID, c.externalID)
+			}
+			if item.ExternalIDs != c.expected {
+				t.Errorf("IDs %+v, expected %+v", item.ExternalIDs, c.expected)
+			}
+			if id := item.SourceID(model.ExternalIDSourceTVDb); id != c.expected.TVDb {
+				t.Errorf("TVDb ID %q, expected %q", id, c.expected.TVDb)
+			}
+		})
+	}
+}
+
+func TestTMDBError(t *testing.T) {
+	for message, c := range map[string]struct {
+		notFound, tooManyRequests bool
+	}{
+		"Code (34): The resource you requested could not be found.":           {notFound: true},
+		"Code (25): Your request count (41) is over the allowed limit of 40.": {tooManyRequests: true},
+		"Code (7): Invalid API key: You must be granted a valid key.":         {},
+		"connection refused": {},
+	} {
+		err := tmdbError(errors.New(message))
+		if _, ok := err.(NotFoundError); ok != c.notFound {
+			t.Errorf("%q: not found %t, expected %t", message, ok, c.notFound)
+		}
+		if ok := ratelimit.IsTooManyRequests(err); ok != c.tooManyRequests {
+			t.Errorf("%q: too many requests %t, expected %t", message, ok, c.tooManyRequests)
+		}
+	}
+}
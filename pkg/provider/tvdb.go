@@ -0,0 +1,355 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+	"github.com/KnutZuidema/godarr/pkg/ratelimit"
+)
+
+const (
+	DefaultTVDBBaseURL = "https://api4.thetvdb.com/v4"
+	tvdbTimeout        = 30 * time.Second
+	// TheTVDB returns at most 500 episodes per page
+	tvdbMaxEpisodePages = 20
+)
+
+// tvdbSeasonTypes are the season types of TheTVDB by episode order.
+var tvdbSeasonTypes = map[model.EpisodeOrder]string{
+	model.EpisodeOrderAired: "official",
+	model.EpisodeOrderDVD:   "dvd",
+}
+
+type TVDBOptions struct {
+	// Base URL of the API, defaults to DefaultTVDBBaseURL
+	BaseURL string
+	APIKey  string
+	// PIN of the subscriber, only required for user supported keys
+	PIN string
+}
+
+// TVDBProvider provides TV series from TheTVDB v4. Its numbering of episodes
+// matches the one of the indexers, which identify series by TVDb ID, and is
+// available in aired and DVD order.
+type TVDBProvider struct {
+	options TVDBOptions
+	client  *http.Client
+	logger  logrus.FieldLogger
+
+	mu    sync.Mutex
+	token string
+}
+
+func NewTVDBProvider(options TVDBOptions, client *http.Client, logger logrus.FieldLogger) *TVDBProvider {
+	if options.BaseURL == "" {
+		options.BaseURL = DefaultTVDBBaseURL
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	if client == nil {
+		client = &http.Client{
+			Timeout: tvdbTimeout,
+		}
+	}
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &TVDBProvider{
+		options: options,
+		client:  client,
+		logger:  logger.WithField("component", "TVDBProvider"),
+	}
+}
+
+// tvdbResponse is the envelope of every response of TheTVDB.
+type tvdbResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Links   struct {
+		Next *string `json:"next"`
+	} `json:"links"`
+}
+
+type tvdbRemoteID struct {
+	ID         string `json:"id"`
+	SourceName string `json:"sourceName"`
+}
+
+type tvdbSearchResult struct {
+	TVDbID    string         `json:"tvdb_id"`
+	Name      string         `json:"name"`
+	Overview  string         `json:"overview"`
+	ImageURL  string         `json:"image_url"`
+	Year      string         `json:"year"`
+	Genres    []string       `json:"genres"`
+	RemoteIDs []tvdbRemoteID `json:"remote_ids"`
+}
+
+type tvdbSeries struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Overview   string `json:"overview"`
+	Image      string `json:"image"`
+	Year       string `json:"year"`
+	FirstAired string `json:"firstAired"`
+	Genres     []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+	RemoteIDs []tvdbRemoteID `json:"remoteIds"`
+}
+
+type tvdbEpisode struct {
+	SeasonNumber int    `json:"seasonNumber"`
+	Number       int    `json:"number"`
+	Name         string `json:"name"`
+	Overview     string `json:"overview"`
+	Aired        string `json:"aired"`
+}
+
+// Login requests a new token with the API key, it is called automatically
+// before the first request and when the token expired.
+func (p *TVDBProvider) Login() error {
+	body, err := json.Marshal(map[string]string{
+		"apikey": p.options.APIKey,
+		"pin":    p.options.PIN,
+	})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if _, err := p.do(http.MethodPost, "/login", bytes.NewReader(body), "", &res); err != nil {
+		return fmt.Errorf("login: %v", err)
+	}
+	p.mu.Lock()
+	p.token = res.Token
+	p.mu.Unlock()
+	return nil
+}
+
+// get requests a path with the current token and logs in again once if the
+// token is missing or expired.
+func (p *TVDBProvider) get(path string, v interface{}) (*tvdbResponse, error) {
+	p.mu.Lock()
+	token := p.token
+	p.mu.Unlock()
+	if token != "" {
+		res, err := p.do(http.MethodGet, path, nil, token, v)
+		if err != errTVDBUnauthorized {
+			return res, err
+		}
+	}
+	if err := p.Login(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	token = p.token
+	p.mu.Unlock()
+	return p.do(http.MethodGet, path, nil, token, v)
+}
+
+var errTVDBUnauthorized = fmt.Errorf("unauthorized")
+
+func (p *TVDBProvider) do(method, path string, body io.Reader, token string, v interface{}) (*tvdbResponse, error) {
+	req, err := http.NewRequest(method, p.options.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized && token != "" {
+		return nil, errTVDBUnauthorized
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var res tvdbResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, ratelimit.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(data))}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, NotFoundError{fmt.Sprintf("TheTVDB: %s", res.Message)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || res.Status != "success" {
+		return nil, ratelimit.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: res.Message}
+	}
+	if v != nil {
+		if err := json.Unmarshal(res.Data, v); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+func (p *TVDBProvider) ListBySearch(search string) ([]*model.Item, error) {
+	var results []tvdbSearchResult
+	if _, err := p.get("/search?type=series&query="+url.QueryEscape(search), &results); err != nil {
+		return nil, err
+	}
+	items := make([]*model.Item, 0, len(results))
+	for _, result := range results {
+		year, _ := strconv.Atoi(result.Year)
+		ids := tvdbExternalIDs(result.RemoteIDs)
+		ids.TVDb = result.TVDbID
+		items = append(items, &model.Item{
+			ExternalID:  result.TVDbID,
+			ExternalIDs: ids,
+			Kind:        model.ItemKindTVSeries,
+			Title:       result.Name,
+			Description: result.Overview,
+			ImagePath:   result.ImageURL,
+			ReleaseYear: year,
+			Genres:      result.Genres,
+			Status:      model.ItemStatusAdded,
+		})
+	}
+	return items, nil
+}
+
+// GetByID fetches a TV series by its TVDb ID.
+func (p *TVDBProvider) GetByID(id string) (*model.Item, error) {
+	var series tvdbSeries
+	if _, err := p.get("/series/"+url.PathEscape(id)+"/extended?short=true", &series); err != nil {
+		return nil, err
+	}
+	year, _ := strconv.Atoi(series.Year)
+	if year == 0 {
+		if aired, err := time.Parse(tmdbTimeFormat, series.FirstAired); err == nil {
+			year = aired.Year()
+		}
+	}
+	var genres []string
+	for _, genre := range series.Genres {
+		genres = append(genres, genre.Name)
+	}
+	ids := tvdbExternalIDs(series.RemoteIDs)
+	ids.TVDb = strconv.Itoa(series.ID)
+	return &model.Item{
+		ExternalID:  ids.TVDb,
+		ExternalIDs: ids,
+		Kind:        model.ItemKindTVSeries,
+		Title:       series.Name,
+		Description: series.Overview,
+		ImagePath:   series.Image,
+		ReleaseYear: year,
+		Genres:      genres,
+		Status:      model.ItemStatusAdded,
+	}, nil
+}
+
+// Find fetches a TV series by its TVDb ID or by its IMDb ID, which is
+// resolved via the remote IDs of TheTVDB. Numeric IDs of other sources are
+// ambiguous among the remote IDs, so they are not supported.
+func (p *TVDBProvider) Find(source model.ExternalIDSource, id string) (*model.Item, error) {
+	switch source {
+	case model.ExternalIDSourceTVDb:
+		return p.GetByID(id)
+	case model.ExternalIDSourceIMDb:
+	default:
+		return nil, NotFoundError{fmt.Sprintf("TheTVDB can not find TV series by %s ID", source)}
+	}
+	var results []struct {
+		Series *struct {
+			ID int `json:"id"`
+		} `json:"series"`
+	}
+	if _, err := p.get("/search/remoteid/"+url.PathEscape(id), &results); err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Series != nil {
+			return p.GetByID(strconv.Itoa(result.Series.ID))
+		}
+	}
+	return nil, NotFoundError{fmt.Sprintf("no TV series with %s ID %s", source, id)}
+}
+
+// Refresh fetches the current metadata of a TV series by its TVDb ID.
+func (p *TVDBProvider) Refresh(item *model.Item) (*model.Item, error) {
+	id := item.SourceID(model.ExternalIDSourceTVDb)
+	if id == "" {
+		return nil, NotFoundError{Message: "TV series has no TVDb ID"}
+	}
+	return p.GetByID(id)
+}
+
+// Episodes lists the episodes of a TV series numbered in the episode order
+// of the item.
+func (p *TVDBProvider) Episodes(item *model.Item) ([]model.Episode, error) {
+	order := item.EpisodeOrder
+	if order == "" {
+		order = model.EpisodeOrderAired
+	}
+	seasonType, ok := tvdbSeasonTypes[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown episode order %q", order)
+	}
+	id := item.SourceID(model.ExternalIDSourceTVDb)
+	if id == "" {
+		return nil, NotFoundError{Message: "TV series has no TVDb ID"}
+	}
+	var episodes []model.Episode
+	for page := 0; page < tvdbMaxEpisodePages; page++ {
+		var data struct {
+			Episodes []tvdbEpisode `json:"episodes"`
+		}
+		res, err := p.get(fmt.Sprintf("/series/%s/episodes/%s?page=%d", url.PathEscape(id), seasonType, page), &data)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range data.Episodes {
+			e := model.Episode{
+				SeasonNumber:  episode.SeasonNumber,
+				EpisodeNumber: episode.Number,
+				Title:         episode.Name,
+				Description:   episode.Overview,
+			}
+			if aired, err := time.Parse(tmdbTimeFormat, episode.Aired); err == nil {
+				e.AirDate = &aired
+			}
+			episodes = append(episodes, e)
+		}
+		if res.Links.Next == nil || len(data.Episodes) == 0 {
+			break
+		}
+	}
+	return episodes, nil
+}
+
+// tvdbExternalIDs returns the IDs of the remote IDs which are known sources.
+func tvdbExternalIDs(remoteIDs []tvdbRemoteID) model.ExternalIDs {
+	var ids model.ExternalIDs
+	for _, remoteID := range remoteIDs {
+		switch remoteID.SourceName {
+		case "IMDB":
+			ids.IMDb = remoteID.ID
+		case "TheMovieDB.com":
+			ids.TMDB = remoteID.ID
+		}
+	}
+	return ids
+}
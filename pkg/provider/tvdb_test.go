@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// tvdbServer serves the recorded responses of TheTVDB in testdata/tvdb.
+// Every login issues a new token, tokens are rejected after they expired.
+type tvdbServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu       sync.Mutex
+	logins   int
+	valid    map[string]bool
+	requests []string
+}
+
+func newTVDBServer(t *testing.T) *tvdbServer {
+	s := &tvdbServer{
+		t:     t,
+		valid: map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// expire invalidates all tokens issued so far.
+func (s *tvdbServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid = map[string]bool{}
+}
+
+func (s *tvdbServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	if r.URL.Path == "/login" {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["apikey"] != "key" || body["pin"] != "pin" {
+			s.fixture(w, http.StatusUnauthorized, "unauthorized.json")
+			return
+		}
+		s.logins++
+		token := fmt.Sprintf("token-%d", s.logins)
+		s.valid[token] = true
+		data := strings.Replace(string(s.read("login.json")), "token-1", token, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(data))
+		return
+	}
+	if !s.valid[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		s.fixture(w, http.StatusUnauthorized, "unauthorized.json")
+		return
+	}
+	var name string
+	switch r.URL.Path {
+	case "/search":
+		name = "search.json"
+	case "/series/81189/extended":
+		name = "series_extended.json"
+	case "/search/remoteid/tt0903747":
+		name = "remoteid.json"
+	case "/series/81189/episodes/official", "/series/81189/episodes/dvd":
+		name = fmt.Sprintf("episodes_%s_%s.json", filepath.Base(r.URL.Path), r.URL.Query().Get("page"))
+	}
+	if name == "" {
+		s.fixture(w, http.StatusNotFound, "not_found.json")
+		return
+	}
+	s.fixture(w, http.StatusOK, name)
+}
+
+func (s *tvdbServer) read(name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "tvdb", name))
+	if err != nil {
+		s.t.Error(err)
+	}
+	return data
+}
+
+func (s *tvdbServer) fixture(w http.ResponseWriter, status int, name string) {
+	data := s.read(name)
+	if data == nil {
+		status = http.StatusNotFound
+		data = s.read("not_found.json")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func (s *tvdbServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newTestTVDBProvider(server *tvdbServer) *TVDBProvider {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return NewTVDBProvider(TVDBOptions{
+		BaseURL: server.URL,
+		APIKey:  "key",
+		PIN:     "pin",
+	}, nil, logger)
+}
+
+var tvdbBreakingBad = &model.Item{
+	ExternalID:  "81189",
+	Kind:        model.ItemKindTVSeries,
+	Title:       "Breaking Bad",
+	Description: "A chemistry teacher diagnosed with inoperable lung cancer turns to manufacturing and selling methamphetamine.",
+	ImagePath:   "https://artworks.thetvdb.com/banners/posters/81189-10.jpg",
+	ReleaseYear: 2008,
+	Status:      model.ItemStatusAdded,
+	ExternalIDs: model.ExternalIDs{TVDb: "81189", IMDb: "tt0903747", TMDB: "1396"},
+}
+
+func TestTVDBListBySearch(t *testing.T) {
+	server := newTVDBServer(t)
+	defer server.Close()
+	items, err := newTestTVDBProvider(server).ListBySearch("breaking bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("%d items, expected 2", len(items))
+	}
+	expected := *tvdbBreakingBad
+	expected.Genres = []string{"Crime", "Drama", "Thriller"}
+	if !reflect.DeepEqual(items[0], &expected) {
+		t.Errorf("got %+v, expected %+v", items[0], &expected)
+	}
+	if items[1].ExternalID != "273181" || items[1].ReleaseYear != 0 || items[1].ExternalIDs.IMDb != "" {
+		t.Errorf("unexpected item without year and remote IDs %+v", items[1])
+	}
+	requests := server.recorded()
+	if requests[1] != "GET /search?type=series&query=breaking+bad" {
+		t.Errorf("unexpected search request %s", requests[1])
+	}
+}
+
+func TestTVDBGetByID(t *testing.T) {
+	server := newTVDBServer(t)
+	defer server.Close()
+	p := newTestTVDBProvider(server)
+	item, err := p.GetByID("81189")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := *tvdbBreakingBad
+	expected.Genres = []string{"Crime", "Drama"}
+	if !reflect.DeepEqual(item, &expected) {
+		t.Errorf("got %+v, expected %+v", item, &expected)
+	}
+	if _, err := p.GetByID("1"); !isNotFound(err) {
+		t.Errorf("unknown series returned %v, expected NotFoundError", err)
+	}
+}
+
+func TestTVDBFind(t *testing.T) {
+	server := newTVDBServer(t)
+	defer server.Close()
+	p := newTestTVDBProvider(server)
+	item, err := p.Find(model.ExternalIDSourceIMDb, "tt0903747")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ExternalIDs.TVDb != "81189" {
+		t.Errorf("found %+v, expected TVDb ID 81189", item)
+	}
+	if _, err := p.Find(model.ExternalIDSourceTMDB, "1396"); !isNotFound(err) {
+		t.Errorf("find by TMDb ID returned %v, expected NotFoundError", err)
+	}
+}
+
+func TestTVDBEpisodes(t *testing.T) {
+	aired := func(date string) *time.Time {
+		t, _ := time.Parse(tmdbTimeFormat, date)
+		return &t
+	}
+	pilot := model.Episode{SeasonNumber: 1, EpisodeNumber: 1, Title: "Pilot", Description: "Walter White begins a new life.", AirDate: aired("2008-01-20")}
+	for order, expected := range map[model.EpisodeOrder][]model.Episode{
+		// the aired order has two pages
+		model.EpisodeOrderAired: {
+			pilot,
+			{SeasonNumber: 1, EpisodeNumber: 2, Title: "Cat's in the Bag...", Description: "Walt and Jesse clean up.", AirDate: aired("2008-01-27")},
+			{SeasonNumber: 0, EpisodeNumber: 1, Title: "Good Cop Bad Cop"},
+		},
+		model.EpisodeOrderDVD: {
+			pilot,
+			{SeasonNumber: 1, EpisodeNumber: 3, Title: "Cat's in the Bag...", Description: "Walt and Jesse clean up.", AirDate: aired("2008-01-27")},
+		},
+	} {
+		t.Run(string(order), func(t *testing.T) {
+			server := newTVDBServer(t)
+			defer server.Close()
+			item := &model.Item{ExternalIDs: model.ExternalIDs{TVDb: "81189"}, EpisodeOrder: order}
+			episodes, err := newTestTVDBProvider(server).Episodes(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(episodes, expected) {
+				t.Errorf("got %+v, expected %+v", episodes, expected)
+			}
+		})
+	}
+}
+
+func TestTVDBRelogin(t *testing.T) {
+	server := newTVDBServer(t)
+	defer server.Close()
+	p := newTestTVDBProvider(server)
+	if _, err := p.GetByID("81189"); err != nil {
+		t.Fatal(err)
+	}
+	server.expire()
+	if _, err := p.GetByID("81189"); err != nil {
+		t.Fatal(err)
+	}
+	// the token is reused until it expires, the request is repeated after
+	// logging in again
+	expected := []string{
+		"POST /login",
+		"GET /series/81189/extended?short=true",
+		"GET /series/81189/extended?short=true",
+		"POST /login",
+		"GET /series/81189/extended?short=true",
+	}
+	if requests := server.recorded(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("requests %v, expected %v", requests, expected)
+	}
+	p.mu.Lock()
+	token := p.token
+	p.mu.Unlock()
+	if token != "token-2" {
+		t.Errorf("token %q, expected token-2", token)
+	}
+}
+
+func TestTVDBInvalidKey(t *testing.T) {
+	server := newTVDBServer(t)
+	defer server.Close()
+	p := NewTVDBProvider(TVDBOptions{BaseURL: server.URL, APIKey: "wrong"}, nil, nil)
+	if _, err := p.GetByID("81189"); err == nil || !strings.HasPrefix(err.Error(), "login:") {
+		t.Errorf("got %v, expected login error", err)
+	}
+	if requests := server.recorded(); len(requests) != 1 {
+		t.Errorf("requests %v, expected only the login", requests)
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(NotFoundError)
+	return ok
+}
@@ -0,0 +1,348 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// ErrDisabled is returned by a guard while its service is disabled after
+// failing repeatedly.
+var ErrDisabled = errors.New("disabled after repeated failures")
+
+type Options struct {
+	// Requests allowed per Per, requests are not limited if zero
+	Requests int
+	Per      time.Duration
+	// Requests which may be made at once, defaults to 1
+	Burst int
+	// Attempts per call, failed attempts are retried after a delay which
+	// starts at MinBackoff and doubles up to MaxBackoff
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Consecutive failed calls after which the service is disabled for
+	// DisableFor, which doubles with every further failure up to MaxDisable
+	DisableAfter int
+	DisableFor   time.Duration
+	MaxDisable   time.Duration
+	// Permanent reports errors which are answers of the service, like unknown
+	// IDs. They are neither retried nor counted as failures.
+	Permanent func(error) bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.Burst <= 0 {
+		o.Burst = 1
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 2 * time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 30 * o.MinBackoff
+	}
+	if o.DisableAfter <= 0 {
+		o.DisableAfter = 5
+	}
+	if o.DisableFor <= 0 {
+		o.DisableFor = 15 * time.Minute
+	}
+	if o.MaxDisable < o.DisableFor {
+		o.MaxDisable = 24 * o.DisableFor
+	}
+	if o.Permanent == nil {
+		o.Permanent = func(error) bool { return false }
+	}
+	return o
+}
+
+// Limiter is a token bucket which allows a number of requests per duration.
+type Limiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter returns a limiter which allows requests per duration with bursts
+// of up to burst requests. It does not limit requests if requests is zero.
+func NewLimiter(requests int, per time.Duration, burst int) *Limiter {
+	if burst <= 0 {
+		burst = 1
+	}
+	l := &Limiter{
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+	if requests > 0 && per > 0 {
+		l.interval = per / time.Duration(requests)
+	}
+	return l
+}
+
+// Wait blocks until a request may be made or the context is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if l.interval > 0 {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			delay = time.Duration(-l.tokens * float64(l.interval))
+		}
+	}
+	if paused := l.pausedUntil.Sub(now); paused > delay {
+		delay = paused
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if l.interval > 0 {
+			// give back the reserved token
+			l.mu.Lock()
+			l.tokens++
+			l.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Pause delays all requests for d, it is used when the service reports too
+// many requests.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// StatusError is returned for a response of a service with an unexpected
+// HTTP status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Message of the service, if the response contained one
+	Message string
+}
+
+func (e StatusError) Error() string {
+	if e.Message == "" {
+		return "unexpected status " + e.Status
+	}
+	return "unexpected status " + e.Status + ": " + e.Message
+}
+
+// IsTooManyRequests reports whether an error is caused by exceeding the rate
+// limit of a service, which answers with status 429 Too Many Requests.
+func IsTooManyRequests(err error) bool {
+	var status StatusError
+	return errors.As(err, &status) && status.StatusCode == http.StatusTooManyRequests
+}
+
+// Transport fails requests which are answered with status 429 Too Many
+// Requests with a StatusError. It is used by the clients of services which
+// only report the status as text.
+type Transport struct {
+	// Base makes the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// Guard limits the requests to a service, retries failed calls and disables
+// the service for a while if it keeps failing. Its health is exposed via the
+// API.
+type Guard struct {
+	limiter *Limiter
+	options Options
+	logger  log.FieldLogger
+
+	mu       sync.Mutex
+	health   model.Health
+	disables int
+}
+
+func NewGuard(options Options, logger log.FieldLogger) *Guard {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	options = options.withDefaults()
+	return &Guard{
+		limiter: NewLimiter(options.Requests, options.Per, options.Burst),
+		options: options,
+		logger:  logger.WithField("component", "Guard"),
+		health:  model.Health{Status: model.HealthStatusHealthy},
+	}
+}
+
+// Do calls f once a request may be made and retries it with exponential
+// backoff if it fails. ErrDisabled is returned without calling f while the
+// service is disabled. Canceled calls do not count as failures.
+func (g *Guard) Do(ctx context.Context, f func() error) error {
+	if g.disabled() {
+		return ErrDisabled
+	}
+	var err error
+	for attempt := 0; attempt < g.options.Attempts; attempt++ {
+		if attempt > 0 {
+			delay := g.backoff(attempt)
+			if IsTooManyRequests(err) {
+				// the whole service has to wait, not only this call
+				delay = g.options.MaxBackoff
+				g.limiter.Pause(delay)
+			}
+			g.logger.WithFields(log.Fields{
+				"attempt": attempt,
+				"delay":   delay,
+			}).Warn("retry: ", err)
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+		if err := g.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		if err = f(); err == nil || g.options.Permanent(err) {
+			g.succeeded()
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	g.failed(err)
+	return err
+}
+
+// backoff returns the delay before an attempt, which doubles with every
+// attempt. Half of it is randomized, so concurrent calls do not retry at once.
+func (g *Guard) backoff(attempt int) time.Duration {
+	delay := g.options.MinBackoff
+	for i := 1; i < attempt && delay < g.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > g.options.MaxBackoff {
+		delay = g.options.MaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *Guard) disabled() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.health.DisabledUntil != nil && time.Now().Before(*g.health.DisabledUntil)
+}
+
+// Available reports whether calls are currently made to the service.
+func (g *Guard) Available() bool {
+	return !g.disabled()
+}
+
+func (g *Guard) succeeded() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now().UTC()
+	g.health.Failures = 0
+	g.health.LastSuccessAt = &now
+	g.health.DisabledUntil = nil
+	g.disables = 0
+}
+
+// failed records a failed call and disables the service once it failed too
+// often in a row. A service which fails again after being disabled is
+// disabled for twice as long.
+func (g *Guard) failed(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now().UTC()
+	g.health.Failures++
+	g.health.LastError = err.Error()
+	g.health.LastFailureAt = &now
+	if g.health.Failures < g.options.DisableAfter {
+		return
+	}
+	duration := g.options.DisableFor
+	for i := 0; i < g.disables && duration < g.options.MaxDisable; i++ {
+		duration *= 2
+	}
+	if duration > g.options.MaxDisable {
+		duration = g.options.MaxDisable
+	}
+	g.disables++
+	until := now.Add(duration)
+	g.health.DisabledUntil = &until
+	g.logger.WithFields(log.Fields{
+		"failures": g.health.Failures,
+		"until":    until,
+	}).Error("disabled: ", err)
+}
+
+// Health returns the current health of the service.
+func (g *Guard) Health() model.Health {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	health := g.health
+	if health.DisabledUntil != nil && !time.Now().Before(*health.DisabledUntil) {
+		health.DisabledUntil = nil
+	}
+	switch {
+	case health.DisabledUntil != nil:
+		health.Status = model.HealthStatusDisabled
+	case health.Failures > 0:
+		health.Status = model.HealthStatusFailing
+	default:
+		health.Status = model.HealthStatusHealthy
+	}
+	return health
+}
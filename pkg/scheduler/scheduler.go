@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// Names of the recurring tasks of godarr.
+const (
+	TaskMetadataRefresh = "metadataRefresh"
+	TaskRSSSync         = "rssSync"
+	TaskMissingSearch   = "missingSearch"
+	TaskLibraryRescan   = "libraryRescan"
+	TaskCleanup         = "cleanup"
+	TaskBackup          = "backup"
+)
+
+var (
+	ErrUnknownTask = errors.New("unknown task")
+	ErrTaskRunning = errors.New("task is already running")
+)
+
+// Func is the work of a task, the context is canceled when the scheduler
+// stops.
+type Func func(ctx context.Context) error
+
+type Options struct {
+	// The task only runs when triggered manually if zero
+	Interval time.Duration
+	// Maximum random delay added to every interval, so tasks with the same
+	// interval do not run at the same time
+	Jitter time.Duration
+}
+
+type task struct {
+	options Options
+	run     Func
+	state   model.Task
+}
+
+// Scheduler runs named tasks in their interval. The time of the last and next
+// run is stored in the database, so intervals continue across restarts. A task
+// never runs more than once at a time.
+type Scheduler struct {
+	db     database.Database
+	logger log.FieldLogger
+
+	mu     sync.Mutex
+	tasks  map[string]*task
+	random *rand.Rand
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(db database.Database, logger log.FieldLogger) *Scheduler {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:     db,
+		logger: logger.WithField("component", "Scheduler"),
+		tasks:  map[string]*task{},
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Register adds a task. Tasks have to be registered before Run is called.
+func (s *Scheduler) Register(name string, options Options, run Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[name] = &task{
+		options: options,
+		run:     run,
+		state: model.Task{
+			Name: name,
+		},
+	}
+}
+
+// SetOptions changes the interval and jitter of a task and reschedules it.
+func (s *Scheduler) SetOptions(name string, options Options) error {
+	s.mu.Lock()
+	t, ok := s.tasks[name]
+	if !ok {
+		s.mu.Unlock()
+		return ErrUnknownTask
+	}
+	t.options = options
+	from := time.Now().UTC()
+	if t.state.LastRunAt != nil {
+		from = *t.state.LastRunAt
+	}
+	t.state.NextRunAt = s.next(t, from)
+	state := t.state
+	s.mu.Unlock()
+	s.notify()
+	return s.db.SaveTask(&state)
+}
+
+// List returns the state of all tasks ordered by name.
+func (s *Scheduler) List() []*model.Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*model.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		state := t.state
+		state.Interval = t.options.Interval.Seconds()
+		res = append(res, &state)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// Trigger runs a task immediately, unless it is already running.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok {
+		return ErrUnknownTask
+	}
+	if t.state.Running {
+		return ErrTaskRunning
+	}
+	s.start(t)
+	return nil
+}
+
+// Run starts tasks when they are due until stop is closed and waits for
+// running tasks afterwards, which are canceled.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	if err := s.load(); err != nil {
+		s.logger.Error("load tasks: ", err)
+	}
+	for {
+		var wait <-chan time.Time
+		if next := s.startDue(time.Now().UTC()); !next.IsZero() {
+			wait = time.After(time.Until(next))
+		}
+		select {
+		case <-wait:
+		case <-s.wake:
+		case <-stop:
+			s.cancel()
+			s.wg.Wait()
+			return
+		}
+	}
+}
+
+// load restores the last and next runs of the tasks from the database. Tasks
+// which never ran are scheduled one interval from now.
+func (s *Scheduler) load() error {
+	stored, err := s.db.ListTasks()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range stored {
+		if t, ok := s.tasks[state.Name]; ok {
+			t.state = *state
+		}
+	}
+	for _, t := range s.tasks {
+		latest := s.next(t, now)
+		// keep the stored next run, unless the interval was shortened since
+		if t.state.NextRunAt == nil || latest == nil || t.state.NextRunAt.After(*latest) {
+			t.state.NextRunAt = latest
+		}
+	}
+	return nil
+}
+
+// startDue starts every task which is due and returns the time of the next
+// run of the remaining tasks, or zero if none is scheduled.
+func (s *Scheduler) startDue(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, t := range s.tasks {
+		if t.state.NextRunAt == nil || t.state.Running {
+			continue
+		}
+		if !t.state.NextRunAt.After(now) {
+			s.start(t)
+			continue
+		}
+		if next.IsZero() || t.state.NextRunAt.Before(next) {
+			next = *t.state.NextRunAt
+		}
+	}
+	return next
+}
+
+// start runs a task in the background, the lock has to be held.
+func (s *Scheduler) start(t *task) {
+	t.state.Running = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		logger := s.logger.WithField("task", t.state.Name)
+		logger.Info("started")
+		start := time.Now().UTC()
+		err := t.run(s.ctx)
+		end := time.Now().UTC()
+		s.mu.Lock()
+		t.state.Running = false
+		t.state.LastRunAt = &start
+		t.state.LastDuration = end.Sub(start).Seconds()
+		t.state.LastError = ""
+		if err != nil {
+			t.state.LastError = err.Error()
+		}
+		t.state.NextRunAt = s.next(t, end)
+		state := t.state
+		s.mu.Unlock()
+		if err != nil {
+			logger.Error("failed: ", err)
+		} else {
+			logger.WithField("duration", end.Sub(start)).Info("finished")
+		}
+		if err := s.db.SaveTask(&state); err != nil {
+			logger.Error("save task: ", err)
+		}
+		s.notify()
+	}()
+}
+
+// next returns the time of the next run after from, or nil if the task only
+// runs when triggered. The lock has to be held.
+func (s *Scheduler) next(t *task, from time.Time) *time.Time {
+	if t.options.Interval <= 0 {
+		return nil
+	}
+	next := from.Add(t.options.Interval)
+	if t.options.Jitter > 0 {
+		next = next.Add(time.Duration(s.random.Int63n(int64(t.options.Jitter))))
+	}
+	return &next
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/event"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+const (
+	SignatureHeader = "X-Godarr-Signature"
+	EventHeader     = "X-Godarr-Event"
+	EventIDHeader   = "X-Godarr-Event-ID"
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the signature of a payload as sent in the signature header,
+// the hex encoded HMAC-SHA256 of the payload keyed with the secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers events of the event bus to the enabled webhooks which
+// want them. Failed deliveries are retried with exponential backoff and every
+// attempt is recorded in the delivery log.
+type Dispatcher struct {
+	db     database.Database
+	client *http.Client
+	logger log.FieldLogger
+	// Maximum number of attempts per delivery
+	MaxAttempts int
+	// Delay before the first retry, it doubles with every further retry
+	Backoff time.Duration
+}
+
+func NewDispatcher(db database.Database, client *http.Client, logger log.FieldLogger) *Dispatcher {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
+	return &Dispatcher{
+		db:          db,
+		client:      client,
+		logger:      logger.WithField("component", "WebhookDispatcher"),
+		MaxAttempts: 5,
+		Backoff:     10 * time.Second,
+	}
+}
+
+// Run delivers the events published on the bus until stop is closed.
+func (d *Dispatcher) Run(bus *event.Bus, stop <-chan struct{}) {
+	subscription, _ := bus.Subscribe(event.Filter{Types: model.WebhookEvents}, 0)
+	defer subscription.Close()
+	for {
+		select {
+		case e, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			d.Dispatch(e, stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Dispatch delivers an event to every enabled webhook which wants it in the
+// background. Retries are abandoned when stop is closed.
+func (d *Dispatcher) Dispatch(e model.Event, stop <-chan struct{}) {
+	webhooks, err := d.db.ListWebhooks()
+	if err != nil {
+		d.logger.Error("list webhooks: ", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		if webhook.Enabled && webhook.Wants(e.Type) {
+			go d.Deliver(webhook, e, d.MaxAttempts, stop)
+		}
+	}
+}
+
+// Test delivers a test event to a webhook once and returns the recorded
+// delivery.
+func (d *Dispatcher) Test(webhook *model.Webhook) *model.WebhookDelivery {
+	return d.Deliver(webhook, model.Event{
+		Type: model.EventWebhookTest,
+		Date: time.Now().UTC(),
+	}, 1, nil)
+}
+
+// Deliver posts an event to a webhook until it succeeds, the attempts are
+// exhausted or stop is closed while waiting for a retry and returns the last
+// delivery.
+func (d *Dispatcher) Deliver(webhook *model.Webhook, e model.Event, attempts int, stop <-chan struct{}) *model.WebhookDelivery {
+	logger := d.logger.WithFields(log.Fields{
+		"webhook": webhook.Name,
+		"event":   e.ID,
+	})
+	payload, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("encode event: ", err)
+		return nil
+	}
+	backoff := d.Backoff
+	var delivery *model.WebhookDelivery
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				logger.Warn("deliver: stopped before attempt ", attempt)
+				return delivery
+			}
+			backoff *= 2
+		}
+		delivery = &model.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventID:   e.ID,
+			EventType: e.Type,
+			Payload:   model.JSON(payload),
+			Attempt:   attempt,
+			Date:      time.Now().UTC(),
+		}
+		retry, err := d.post(webhook, e, payload, delivery)
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Success = true
+		}
+		if err := d.db.AddWebhookDelivery(delivery); err != nil {
+			logger.Error("record delivery: ", err)
+		}
+		if delivery.Success || !retry {
+			break
+		}
+		logger.WithField("attempt", attempt).Warn("deliver: ", delivery.Error)
+	}
+	if !delivery.Success {
+		logger.Error("deliver: giving up after ", delivery.Attempt, " attempts: ", delivery.Error)
+	}
+	return delivery
+}
+
+// post sends a single request and reports whether a failure may be retried.
+func (d *Dispatcher) post(webhook *model.Webhook, e model.Event, payload []byte, delivery *model.WebhookDelivery) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(e.Type))
+	req.Header.Set(EventIDHeader, strconv.FormatInt(e.ID, 10))
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	delivery.StatusCode = res.StatusCode
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	// client errors other than rate limits will not go away by retrying
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status %s", res.Status)
+}
@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/KnutZuidema/godarr/pkg/database"
+	"github.com/KnutZuidema/godarr/pkg/model"
+)
+
+// deliveryDB records the delivery log in memory, other methods of the
+// database are not used by the dispatcher.
+type deliveryDB struct {
+	database.Database
+	webhooks []*model.Webhook
+
+	mu         sync.Mutex
+	deliveries []*model.WebhookDelivery
+}
+
+func (d *deliveryDB) ListWebhooks() ([]*model.Webhook, error) {
+	return d.webhooks, nil
+}
+
+func (d *deliveryDB) AddWebhookDelivery(delivery *model.WebhookDelivery) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries = append(d.deliveries, delivery)
+	return nil
+}
+
+func (d *deliveryDB) recorded() []*model.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*model.WebhookDelivery(nil), d.deliveries...)
+}
+
+// receivedRequest is a request of the dispatcher to the receiver.
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// receiver answers the requests of the dispatcher with the given statuses in
+// order, the last one is repeated.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan receivedRequest) {
+	requests := make(chan receivedRequest, 16)
+	var (
+		mu    sync.Mutex
+		count int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		status := statuses[len(statuses)-1]
+		if count < len(statuses) {
+			status = statuses[count]
+		}
+		count++
+		mu.Unlock()
+		requests <- receivedRequest{header: r.Header, body: body, at: time.Now()}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func newTestDispatcher(db database.Database, backoff time.Duration) *Dispatcher {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	d := NewDispatcher(db, nil, logger)
+	d.Backoff = backoff
+	return d
+}
+
+func testEvent() model.Event {
+	return model.Event{
+		ID:     42,
+		Type:   model.EventImported,
+		ItemID: "5c7a1a4e-2b8f-4d3e-9a61-0f0c7f3b2d11",
+		Date:   time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	server, requests := receiver(t, http.StatusOK)
+	defer server.Close()
+	db := &deliveryDB{}
+	webhook := &model.Webhook{ID: "hook", URL: server.URL, Secret: "secret", Enabled: true}
+	delivery := newTestDispatcher(db, time.Millisecond).Deliver(webhook, testEvent(), 3, nil)
+	if !delivery.Success || delivery.StatusCode != http.StatusOK || delivery.Attempt != 1 {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	request := <-requests
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(request.body)
+	if signature := request.header.Get(SignatureHeader); signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("invalid signature %q", signature)
+	}
+	if eventType := request.header.Get(EventHeader); eventType != string(model.EventImported) {
+		t.Errorf("event header %q", eventType)
+	}
+	if id := request.header.Get(EventIDHeader); id != "42" {
+		t.Errorf("event ID header %q", id)
+	}
+	if contentType := request.header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("content type %q", contentType)
+	}
+	recorded := db.recorded()
+	if len(recorded) != 1 || string(recorded[0].Payload) != string(request.body) {
+		t.Errorf("unexpected delivery log %+v", recorded)
+	}
+}
+
+func TestDeliverUnsigned(t *testing.T) {
+	server, requests := receiver(t, http.StatusNoContent)
+	defer server.Close()
+	webhook := &model.Webhook{ID: "hook", URL: server.URL, Enabled: true}
+	newTestDispatcher(&deliveryDB{}, time.Millisecond).Deliver(webhook, testEvent(), 1, nil)
+	if signature := (<-requests).header.Get(SignatureHeader); signature != "" {
+		t.Errorf("signature %q without secret", signature)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	server, requests := receiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	defer server.Close()
+	db := &deliveryDB{}
+	webhook := &model.Webhook{ID: "hook", URL: server.URL, Enabled: true}
+	backoff := 20 * time.Millisecond
+	delivery := newTestDispatcher(db, backoff).Deliver(webhook, testEvent(), 5, nil)
+	if !delivery.Success || delivery.Attempt != 3 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+	first, second, third := <-requests, <-requests, <-requests
+	if gap := second.at.Sub(first.at); gap < backoff {
+		t.Errorf("first retry after %s, expected at least %s", gap, backoff)
+	}
+	if gap := third.at.Sub(second.at); gap < 2*backoff {
+		t.Errorf("second retry after %s, expected at least %s", gap, 2*backoff)
+	}
+	recorded := db.recorded()
+	if len(recorded) != 3 {
+		t.Fatalf("%d deliveries recorded, expected 3", len(recorded))
+	}
+	for i, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK} {
+		d := recorded[i]
+		if d.Attempt != i+1 || d.StatusCode != status || d.Success != (status == http.StatusOK) || d.EventID != 42 {
+			t.Errorf("unexpected delivery %+v", d)
+		}
+		if !d.Success && d.Error == "" {
+			t.Errorf("failed delivery %d without error", d.Attempt)
+		}
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	for name, c := range map[string]struct {
+		status   int
+		attempts int
+	}{
+		"client error is not retried": {status: http.StatusBadRequest, attempts: 1},
+		"attempts are exhausted":      {status: http.StatusInternalServerError, attempts: 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, _ := receiver(t, c.status)
+			defer server.Close()
+			db := &deliveryDB{}
+			webhook := &model.Webhook{ID: "hook", URL: server.URL, Enabled: true}
+			delivery := newTestDispatcher(db, time.Millisecond).Deliver(webhook, testEvent(), 3, nil)
+			if delivery.Success || delivery.StatusCode != c.status {
+				t.Errorf("unexpected delivery %+v", delivery)
+			}
+			if recorded := db.recorded(); len(recorded) != c.attempts {
+				t.Errorf("%d deliveries recorded, expected %d", len(recorded), c.attempts)
+			}
+		})
+	}
+}
+
+func TestDeliverStops(t *testing.T) {
+	server, requests := receiver(t, http.StatusInternalServerError)
+	defer server.Close()
+	webhook := &model.Webhook{ID: "hook", URL: server.URL, Enabled: true}
+	stop := make(chan struct{})
+	done := make(chan *model.WebhookDelivery)
+	go func() {
+		done <- newTestDispatcher(&deliveryDB{}, time.Hour).Deliver(webhook, testEvent(), 5, stop)
+	}()
+	<-requests
+	close(stop)
+	select {
+	case delivery := <-done:
+		if delivery.Success || delivery.Attempt != 1 {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery did not stop")
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	server, requests := receiver(t, http.StatusOK)
+	defer server.Close()
+	db := &deliveryDB{
+		webhooks: []*model.Webhook{
+			{ID: "all", URL: server.URL, Enabled: true},
+			{ID: "disabled", URL: server.URL},
+			{ID: "grabbed", URL: server.URL, Enabled: true, Events: []string{string(model.EventGrabbed)}},
+		},
+	}
+	newTestDispatcher(db, time.Millisecond).Dispatch(testEvent(), nil)
+	<-requests
+	select {
+	case <-requests:
+		t.Error("event was delivered to a webhook which does not want it")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if recorded := db.recorded(); len(recorded) != 1 || recorded[0].WebhookID != "all" {
+		t.Errorf("unexpected delivery log %+v", recorded)
+	}
+}